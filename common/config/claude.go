@@ -2,22 +2,126 @@ package config
 
 import (
 	"encoding/json"
+	"strings"
 )
 
 type ClaudeSettings struct {
 	DefaultMaxTokens       map[string]int
 	BudgetTokensPercentage float64
+	// 对 strict: true 的工具校验 Claude 返回的输入，不符合 schema 时重试一次
+	StrictToolValidation bool
+	// stop_sequences 数量上限，0 表示不限制
+	StopSequencesLimit int
+	// 超出上限时的处理方式：truncate 截断（优先保留渠道配置的），error 直接报错
+	StopSequencesOverflow string
+	// OpenAI 格式响应中的 model：advertised 返回请求的模型，upstream 返回 Claude 实际返回的模型版本
+	ResponseModel string
+	// 根据上游模型版本生成 system_fingerprint
+	SystemFingerprint bool
+	// stop_reason 为 refusal 时仍将拒绝内容放在 content 中（兼容旧客户端），默认放入 refusal 字段
+	RefusalInContent bool
+	// 允许使用代码执行（容器）工具，存在额外费用和安全风险，默认关闭
+	CodeExecution bool
+	// developer 与 system 消息合并到 system 字段时的先后顺序：developer_first（默认）或 system_first
+	DeveloperRolePrecedence string
+	// 流式请求 n>1 时直接报错，关闭后忽略 n 只返回一个结果
+	RejectStreamMultipleChoices bool
+	// 自动为 system 提示词添加缓存标记，低于模型最小可缓存 token 数时不添加
+	SystemPromptCache bool
+	// system 提示词超过该 token 数时拆分为多个缓存块，0 表示不拆分
+	SystemPromptCacheSplitTokens int
+	// 按模型开启工具定义的自动缓存，达到模型最小可缓存 token 数时在最后一个工具上添加缓存标记，default 为其他模型的设置
+	ToolsCache map[string]bool
+	// 同时设置 temperature 与 top_p 时的处理：both（默认，全部透传）、temperature 或 top_p（仅保留该参数）；渠道配置优先
+	SamplingParamsPrecedence string
+	// 支持 1M 上下文的模型前缀，逗号分隔，提示 token 超出标准上下文时自动添加 context-1m beta 请求头
+	Context1MModels string
+	// 客户端显式传入 max_tokens: 0 时的处理：default 使用默认值、max 使用模型最大输出、error 直接报错
+	ZeroMaxTokens string
+	// 上游未返回提示 token 时，先调用 count_tokens 获取准确值，失败时再使用本地估算
+	CountTokensFallback bool
+	// count_tokens 的超时时间（毫秒）
+	CountTokensTimeout int
+	// 每个模型单次请求的图片数量上限，default 为其他模型的上限，0 或未配置表示不限制；渠道配置优先
+	MaxImages map[string]int
+	// 图片超出上限时的处理方式：error 直接报错，truncate 保留最近的图片，较早的替换为文字说明
+	MaxImagesOverflow string
+	// 请求中包含 input_audio 时直接报错，关闭后忽略音频内容
+	RejectAudioInput bool
+	// tool_result 与对应的 tool_use 不相邻时按 tool_use 的顺序重新排列，关闭后原样发送给上游
+	ReorderToolResults bool
+	// tool_choice 指定的工具不在 tools 中时的处理方式：error 直接报错，auto 改为 auto 并记录警告
+	UndefinedToolChoice string
 }
 
 var ClaudeSettingsInstance = ClaudeSettings{
 	DefaultMaxTokens: map[string]int{
 		"default": 8192,
 	},
-	BudgetTokensPercentage: 0.8,
+	BudgetTokensPercentage:      0.8,
+	StopSequencesOverflow:       StopSequencesOverflowTruncate,
+	ResponseModel:               ResponseModelAdvertised,
+	DeveloperRolePrecedence:     DeveloperRoleFirst,
+	RejectStreamMultipleChoices: true,
+	SamplingParamsPrecedence:    SamplingParamsBoth,
+	Context1MModels:             "claude-sonnet-4",
+	ZeroMaxTokens:               ZeroMaxTokensDefault,
+	CountTokensTimeout:          2000,
+	ToolsCache:                  map[string]bool{},
+	MaxImages:                   map[string]int{},
+	MaxImagesOverflow:           MaxImagesOverflowError,
+	RejectAudioInput:            true,
+	UndefinedToolChoice:         UndefinedToolChoiceError,
+	ReorderToolResults:          true,
 }
 
+const (
+	StopSequencesOverflowTruncate = "truncate"
+	StopSequencesOverflowError    = "error"
+
+	ResponseModelAdvertised = "advertised"
+	ResponseModelUpstream   = "upstream"
+
+	DeveloperRoleFirst = "developer_first"
+	SystemRoleFirst    = "system_first"
+
+	SamplingParamsBoth        = "both"
+	SamplingParamsTemperature = "temperature"
+	SamplingParamsTopP        = "top_p"
+
+	ZeroMaxTokensDefault = "default"
+	ZeroMaxTokensMax     = "max"
+	ZeroMaxTokensError   = "error"
+
+	MaxImagesOverflowError    = "error"
+	MaxImagesOverflowTruncate = "truncate"
+
+	UndefinedToolChoiceError = "error"
+	UndefinedToolChoiceAuto  = "auto"
+)
+
 func init() {
 	GlobalOption.RegisterFloat("ClaudeBudgetTokensPercentage", &ClaudeSettingsInstance.BudgetTokensPercentage)
+	GlobalOption.RegisterBool("ClaudeStrictToolValidation", &ClaudeSettingsInstance.StrictToolValidation)
+	GlobalOption.RegisterInt("ClaudeStopSequencesLimit", &ClaudeSettingsInstance.StopSequencesLimit)
+	GlobalOption.RegisterString("ClaudeStopSequencesOverflow", &ClaudeSettingsInstance.StopSequencesOverflow)
+	GlobalOption.RegisterString("ClaudeResponseModel", &ClaudeSettingsInstance.ResponseModel)
+	GlobalOption.RegisterBool("ClaudeSystemFingerprint", &ClaudeSettingsInstance.SystemFingerprint)
+	GlobalOption.RegisterBool("ClaudeRefusalInContent", &ClaudeSettingsInstance.RefusalInContent)
+	GlobalOption.RegisterBool("ClaudeCodeExecution", &ClaudeSettingsInstance.CodeExecution)
+	GlobalOption.RegisterString("ClaudeDeveloperRolePrecedence", &ClaudeSettingsInstance.DeveloperRolePrecedence)
+	GlobalOption.RegisterBool("ClaudeRejectStreamMultipleChoices", &ClaudeSettingsInstance.RejectStreamMultipleChoices)
+	GlobalOption.RegisterBool("ClaudeSystemPromptCache", &ClaudeSettingsInstance.SystemPromptCache)
+	GlobalOption.RegisterInt("ClaudeSystemPromptCacheSplitTokens", &ClaudeSettingsInstance.SystemPromptCacheSplitTokens)
+	GlobalOption.RegisterString("ClaudeSamplingParamsPrecedence", &ClaudeSettingsInstance.SamplingParamsPrecedence)
+	GlobalOption.RegisterString("ClaudeContext1MModels", &ClaudeSettingsInstance.Context1MModels)
+	GlobalOption.RegisterString("ClaudeZeroMaxTokens", &ClaudeSettingsInstance.ZeroMaxTokens)
+	GlobalOption.RegisterBool("ClaudeCountTokensFallback", &ClaudeSettingsInstance.CountTokensFallback)
+	GlobalOption.RegisterInt("ClaudeCountTokensTimeout", &ClaudeSettingsInstance.CountTokensTimeout)
+	GlobalOption.RegisterString("ClaudeMaxImagesOverflow", &ClaudeSettingsInstance.MaxImagesOverflow)
+	GlobalOption.RegisterBool("ClaudeRejectAudioInput", &ClaudeSettingsInstance.RejectAudioInput)
+	GlobalOption.RegisterString("ClaudeUndefinedToolChoice", &ClaudeSettingsInstance.UndefinedToolChoice)
+	GlobalOption.RegisterBool("ClaudeReorderToolResults", &ClaudeSettingsInstance.ReorderToolResults)
 
 	GlobalOption.RegisterCustom("ClaudeDefaultMaxTokens", func() string {
 		return ClaudeSettingsInstance.GetDefaultMaxTokensJSONString()
@@ -25,6 +129,20 @@ func init() {
 		ClaudeSettingsInstance.SetDefaultMaxTokens(value)
 		return nil
 	}, "")
+
+	GlobalOption.RegisterCustom("ClaudeToolsCache", func() string {
+		return ClaudeSettingsInstance.GetToolsCacheJSONString()
+	}, func(value string) error {
+		ClaudeSettingsInstance.SetToolsCache(value)
+		return nil
+	}, "")
+
+	GlobalOption.RegisterCustom("ClaudeMaxImages", func() string {
+		return ClaudeSettingsInstance.GetMaxImagesJSONString()
+	}, func(value string) error {
+		ClaudeSettingsInstance.SetMaxImages(value)
+		return nil
+	}, "")
 }
 
 func (c *ClaudeSettings) SetDefaultMaxTokens(data string) {
@@ -50,6 +168,77 @@ func (c *ClaudeSettings) GetDefaultMaxTokens(model string) int {
 	return c.DefaultMaxTokens["default"]
 }
 
+func (c *ClaudeSettings) SetMaxImages(data string) {
+	if data == "" {
+		c.MaxImages = map[string]int{}
+		return
+	}
+
+	var maxImages map[string]int
+	err := json.Unmarshal([]byte(data), &maxImages)
+	if err != nil {
+		return
+	}
+	c.MaxImages = maxImages
+}
+
+// GetMaxImages 获取模型单次请求的图片数量上限，0 表示不限制
+func (c *ClaudeSettings) GetMaxImages(model string) int {
+	if maxImages, ok := c.MaxImages[model]; ok {
+		return maxImages
+	}
+	return c.MaxImages["default"]
+}
+
+func (c *ClaudeSettings) GetMaxImagesJSONString() string {
+	str, err := json.Marshal(c.MaxImages)
+	if err != nil {
+		return ""
+	}
+	return string(str)
+}
+
+func (c *ClaudeSettings) SetToolsCache(data string) {
+	if data == "" {
+		c.ToolsCache = map[string]bool{}
+		return
+	}
+
+	var toolsCache map[string]bool
+	err := json.Unmarshal([]byte(data), &toolsCache)
+	if err != nil {
+		return
+	}
+	c.ToolsCache = toolsCache
+}
+
+// IsToolsCacheEnabled 判断模型是否开启工具定义的自动缓存
+func (c *ClaudeSettings) IsToolsCacheEnabled(model string) bool {
+	if enabled, ok := c.ToolsCache[model]; ok {
+		return enabled
+	}
+	return c.ToolsCache["default"]
+}
+
+func (c *ClaudeSettings) GetToolsCacheJSONString() string {
+	str, err := json.Marshal(c.ToolsCache)
+	if err != nil {
+		return ""
+	}
+	return string(str)
+}
+
+// IsContext1MModel 判断模型是否在支持 1M 上下文的模型列表中
+func (c *ClaudeSettings) IsContext1MModel(model string) bool {
+	for _, prefix := range strings.Split(c.Context1MModels, ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix != "" && strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *ClaudeSettings) GetBudgetTokensPercentage() float64 {
 	return c.BudgetTokensPercentage
 }
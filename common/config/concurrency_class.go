@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ConcurrencyClass 模型并发分类，命中分类的模型使用独立的流式并发名额，
+// 避免推理等慢模型占满名额后阻塞同一用户/令牌对其他模型的请求
+type ConcurrencyClass struct {
+	Models   []string `json:"models"`    // 模型名称前缀
+	PerUser  int      `json:"per_user"`  // 每个用户在该分类下的并发上限，0 为不限制
+	PerToken int      `json:"per_token"` // 每个令牌在该分类下的并发上限，0 为不限制
+}
+
+type ConcurrencyClassSettings struct {
+	Classes map[string]ConcurrencyClass
+}
+
+var ConcurrencyClassSettingsInstance = ConcurrencyClassSettings{
+	Classes: map[string]ConcurrencyClass{},
+}
+
+func init() {
+	GlobalOption.RegisterCustom("StreamConcurrencyClasses", func() string {
+		return ConcurrencyClassSettingsInstance.GetClassesJSONString()
+	}, func(value string) error {
+		ConcurrencyClassSettingsInstance.SetClasses(value)
+		return nil
+	}, "")
+}
+
+func (c *ConcurrencyClassSettings) SetClasses(data string) {
+	if data == "" {
+		c.Classes = map[string]ConcurrencyClass{}
+		return
+	}
+
+	var classes map[string]ConcurrencyClass
+	err := json.Unmarshal([]byte(data), &classes)
+	if err != nil {
+		return
+	}
+	c.Classes = classes
+}
+
+// GetClass 获取模型所属的并发分类，多个分类匹配时取前缀最长的，未命中时返回空字符串
+func (c *ConcurrencyClassSettings) GetClass(model string) (string, ConcurrencyClass) {
+	var (
+		matchedName  string
+		matchedClass ConcurrencyClass
+		matchedLen   = -1
+	)
+	for name, class := range c.Classes {
+		for _, prefix := range class.Models {
+			if prefix == "" || !strings.HasPrefix(model, prefix) {
+				continue
+			}
+			if len(prefix) > matchedLen || (len(prefix) == matchedLen && name < matchedName) {
+				matchedName, matchedClass, matchedLen = name, class, len(prefix)
+			}
+		}
+	}
+	return matchedName, matchedClass
+}
+
+func (c *ConcurrencyClassSettings) GetClassesJSONString() string {
+	str, err := json.Marshal(c.Classes)
+	if err != nil {
+		return ""
+	}
+	return string(str)
+}
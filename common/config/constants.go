@@ -161,6 +161,27 @@ var GitHubOldIdCloseEnabled = false
 
 var LinuxDoClientId = ""
 var LinuxDoClientSecret = ""
+var LinuxDoDefaultGroup = "" // 通过 LinuxDo 注册的用户所在的分组，为空时使用默认分组
+var LinuxDoMinTrustLevel = 1 // 通过 LinuxDo 注册所需的最低 trust_level
+var LinuxDoAffHashSecret = "" // 邀请关系日志哈希使用的密钥，首次启动时生成并保存到数据库
+
+// LinuxDo 返回的用户信息缺少 trust_level 或无法解析时的处理策略
+const (
+	LinuxDoTrustLevelIneligible = "ineligible" // 视为不满足注册条件
+	LinuxDoTrustLevelAsLevel1   = "level_1"    // 视为 trust_level=1，仍需满足 LinuxDoMinTrustLevel
+	LinuxDoTrustLevelAllow      = "allow"      // 忽略 LinuxDoMinTrustLevel，允许注册并记录警告
+)
+
+var LinuxDoTrustLevelFallback = LinuxDoTrustLevelIneligible
+
+// LinuxDo 注册时邀请关系（邀请码、邀请人）在系统日志中的记录方式，不影响邀请奖励
+const (
+	AffLogModeNone   = "none"   // 不记录
+	AffLogModeHashed = "hashed" // 记录哈希，可用于关联但无法还原
+	AffLogModePlain  = "plain"  // 明文记录
+)
+
+var LinuxDoAffLogMode = AffLogModeNone
 
 var LarkClientId = ""
 var LarkClientSecret = ""
@@ -191,6 +212,65 @@ var DisableTokenEncoders = false
 var RetryTimes = 0
 var RetryTimeOut = 10
 
+// 流式请求在首字节前连接被重置时的重试次数，0 为关闭
+var StreamResetRetryTimes = 0
+
+// 单个请求所有恢复尝试（渠道重试、流重置重试）共用的预算，耗尽后不再重试，0 为不限制
+var RetryBudgetAttempts = 0
+var RetryBudgetSeconds = 0
+
+// 非流式请求等待上游时客户端断开的计费方式：none 不计费，prompt 只按输入计费
+const (
+	DisconnectBillingNone   = "none"
+	DisconnectBillingPrompt = "prompt"
+)
+
+var DisconnectBilling = DisconnectBillingNone
+
+// 请求中 metadata 记录到日志的上限，超出时不记录
+var RequestMetadataMaxKeys = 16
+var RequestMetadataMaxBytes = 2048
+
+// 渠道 RPM 平滑排队的最长等待时间（秒），超时则换渠道重试
+var ChannelRPMMaxWaitSeconds = 10
+
+// 渠道 RPM 排队时，每排队该秒数优先级提升一级，0 为不提升；实际周期不超过最长等待时间按优先级级数均分的时长
+var RequestPriorityAgingSeconds = 5
+
+// 计费时补全 token 的最低数量及向上取整的块大小，0 为不启用，不影响记录的实际用量
+var BillingMinCompletionTokens = 0
+var BillingCompletionTokenBlock = 0
+
+// 上游返回的请求 ID 记录到日志，及是否通过 X-Upstream-Request-Id 响应头返回给客户端
+var UpstreamRequestIdLogEnabled = true
+var UpstreamRequestIdHeaderEnabled = false
+
+// 每个用户保留的登录记录条数，0 为不限制
+var LoginHistoryMaxRecords = 20
+
+// 每个用户/令牌同时进行的流式请求上限，0 为不限制
+var StreamConcurrencyPerUser = 0
+var StreamConcurrencyPerToken = 0
+
+// 分组禁用流式时对流式请求的处理：non_stream 以非流式请求上游并包装为单个流式块返回，reject 直接拒绝
+var GroupStreamDisabledPolicy = GroupStreamDisabledNonStream
+
+const (
+	GroupStreamDisabledNonStream = "non_stream"
+	GroupStreamDisabledReject    = "reject"
+)
+
+// 响应水印总开关，开启后对启用水印的分组在文本输出中嵌入不可见的令牌标识
+var ResponseWatermarkEnabled = false
+
+// 按请求指纹限流：duration 秒内同一指纹最多 num 次请求，num 为 0 时不启用；指纹由 ip、user_agent、token 中配置的部分组成
+var FingerprintRateLimitNum = 0
+var FingerprintRateLimitDuration = 60
+var FingerprintRateLimitComponents = "ip,user_agent,token"
+
+// 令牌完成回调是否允许发送到内网、回环等非公网地址，默认不允许
+var WebhookAllowPrivateNetwork = false
+
 var DefaultChannelWeight = uint(1)
 var RetryCooldownSeconds = 5
 
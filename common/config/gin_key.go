@@ -1,5 +1,9 @@
 package config
 
 const (
-	GinRequestBodyKey = "cached_request_body"
+	GinRequestBodyKey       = "cached_request_body"
+	GinRequestMetadataKey   = "request_metadata"
+	GinModelDisplayNameKey  = "model_display_name"
+	GinStreamCostLimitKey   = "stream_cost_limit"
+	GinUpstreamRequestIdKey = "upstream_request_id"
 )
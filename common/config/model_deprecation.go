@@ -0,0 +1,55 @@
+package config
+
+import "encoding/json"
+
+// ModelDeprecationSettings 上游废弃模型后，将旧模型透明替换为新模型，迁移期内客户端无需修改
+type ModelDeprecationSettings struct {
+	Rules         map[string]string // 废弃模型 -> 替代模型
+	WarningHeader bool              // 是否通过响应头提示客户端模型已废弃
+}
+
+var ModelDeprecationSettingsInstance = ModelDeprecationSettings{
+	Rules:         map[string]string{},
+	WarningHeader: true,
+}
+
+func init() {
+	GlobalOption.RegisterCustom("ModelDeprecation", func() string {
+		return ModelDeprecationSettingsInstance.GetRulesJSONString()
+	}, func(value string) error {
+		ModelDeprecationSettingsInstance.SetRules(value)
+		return nil
+	}, "")
+	GlobalOption.RegisterBool("ModelDeprecationWarningHeader", &ModelDeprecationSettingsInstance.WarningHeader)
+}
+
+func (c *ModelDeprecationSettings) SetRules(data string) {
+	if data == "" {
+		c.Rules = map[string]string{}
+		return
+	}
+
+	var rules map[string]string
+	err := json.Unmarshal([]byte(data), &rules)
+	if err != nil {
+		return
+	}
+	c.Rules = rules
+}
+
+// GetReplacementModel 获取废弃模型的替代模型，未废弃时返回空字符串
+func (c *ModelDeprecationSettings) GetReplacementModel(model string) string {
+	replacement, ok := c.Rules[model]
+	if !ok || replacement == model {
+		return ""
+	}
+	return replacement
+}
+
+func (c *ModelDeprecationSettings) GetRulesJSONString() string {
+	str, err := json.Marshal(c.Rules)
+	if err != nil {
+		return ""
+	}
+	return string(str)
+}
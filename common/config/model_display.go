@@ -0,0 +1,49 @@
+package config
+
+import "encoding/json"
+
+// ModelDisplayNameSettings 分组的模型展示名称，分组 => 真实模型 => 对外展示的名称
+// 模型列表、响应中的 model 字段及错误信息使用展示名称，路由与计费仍使用真实模型
+type ModelDisplayNameSettings struct {
+	Groups map[string]map[string]string
+}
+
+var ModelDisplayNameSettingsInstance = ModelDisplayNameSettings{
+	Groups: map[string]map[string]string{},
+}
+
+func init() {
+	GlobalOption.RegisterCustom("GroupModelDisplayNames", func() string {
+		return ModelDisplayNameSettingsInstance.GetGroupsJSONString()
+	}, func(value string) error {
+		ModelDisplayNameSettingsInstance.SetGroups(value)
+		return nil
+	}, "")
+}
+
+func (c *ModelDisplayNameSettings) SetGroups(data string) {
+	if data == "" {
+		c.Groups = map[string]map[string]string{}
+		return
+	}
+
+	var groups map[string]map[string]string
+	err := json.Unmarshal([]byte(data), &groups)
+	if err != nil {
+		return
+	}
+	c.Groups = groups
+}
+
+// GetDisplayNames 获取分组的模型展示名称
+func (c *ModelDisplayNameSettings) GetDisplayNames(group string) map[string]string {
+	return c.Groups[group]
+}
+
+func (c *ModelDisplayNameSettings) GetGroupsJSONString() string {
+	str, err := json.Marshal(c.Groups)
+	if err != nil {
+		return ""
+	}
+	return string(str)
+}
@@ -0,0 +1,57 @@
+package config
+
+import "encoding/json"
+
+// ModelDowngradeRule 用户余额低于阈值时，将请求模型降级为更便宜的模型
+type ModelDowngradeRule struct {
+	Model     string `json:"model"`
+	Threshold int    `json:"threshold"` // 用户剩余额度低于该值时触发降级
+}
+
+type ModelDowngradeSettings struct {
+	Rules map[string]ModelDowngradeRule
+}
+
+var ModelDowngradeSettingsInstance = ModelDowngradeSettings{
+	Rules: map[string]ModelDowngradeRule{},
+}
+
+func init() {
+	GlobalOption.RegisterCustom("ModelDowngrade", func() string {
+		return ModelDowngradeSettingsInstance.GetRulesJSONString()
+	}, func(value string) error {
+		ModelDowngradeSettingsInstance.SetRules(value)
+		return nil
+	}, "")
+}
+
+func (c *ModelDowngradeSettings) SetRules(data string) {
+	if data == "" {
+		c.Rules = map[string]ModelDowngradeRule{}
+		return
+	}
+
+	var rules map[string]ModelDowngradeRule
+	err := json.Unmarshal([]byte(data), &rules)
+	if err != nil {
+		return
+	}
+	c.Rules = rules
+}
+
+// GetDowngradeModel 根据用户剩余额度获取降级后的模型，不需要降级时返回空字符串
+func (c *ModelDowngradeSettings) GetDowngradeModel(model string, userQuota int) string {
+	rule, ok := c.Rules[model]
+	if !ok || rule.Model == "" || rule.Model == model || userQuota >= rule.Threshold {
+		return ""
+	}
+	return rule.Model
+}
+
+func (c *ModelDowngradeSettings) GetRulesJSONString() string {
+	str, err := json.Marshal(c.Rules)
+	if err != nil {
+		return ""
+	}
+	return string(str)
+}
@@ -0,0 +1,80 @@
+package image
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	ImageDetailLow  = "low"
+	ImageDetailHigh = "high"
+	ImageDetailAuto = "auto"
+
+	// detail 为 low 时图片最长边的最大像素
+	LowDetailMaxSide = 512
+)
+
+// ResizeImageForDetail 按 OpenAI image_url.detail 调整 base64 图片：low 时缩小到 LowDetailMaxSide 以内，其余情况原样返回
+// 无法解码的图片（如 PDF）原样返回，交由上游处理
+func ResizeImageForDetail(mimeType, data, detail string) (string, string, error) {
+	if detail != ImageDetailLow || !strings.HasPrefix(mimeType, "image/") {
+		return mimeType, data, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return mimeType, data, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return mimeType, data, nil
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= LowDetailMaxSide && height <= LowDetailMaxSide {
+		return mimeType, data, nil
+	}
+
+	if width >= height {
+		height = max(1, height*LowDetailMaxSide/width)
+		width = LowDetailMaxSide
+	} else {
+		width = max(1, width*LowDetailMaxSide/height)
+		height = LowDetailMaxSide
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	// png 保持原格式以保留透明度，其余格式统一输出 jpeg
+	buffer := bytes.NewBuffer(nil)
+	if mimeType == "image/png" {
+		err = png.Encode(buffer, dst)
+	} else {
+		mimeType = "image/jpeg"
+		err = jpeg.Encode(buffer, dst, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// 校验缩放后的图片
+	resized, _, err := image.DecodeConfig(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		return "", "", err
+	}
+	if resized.Width != width || resized.Height != height {
+		return "", "", errors.New("resized image size mismatch")
+	}
+
+	return mimeType, base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
@@ -0,0 +1,63 @@
+package image_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	img "one-api/common/image"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestPNG(t *testing.T, width, height int) string {
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x + y), 255})
+		}
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	assert.Nil(t, png.Encode(buffer, src))
+	return base64.StdEncoding.EncodeToString(buffer.Bytes())
+}
+
+func TestResizeImageForDetailLow(t *testing.T) {
+	data := getTestPNG(t, 1024, 768)
+
+	mimeType, resized, err := img.ResizeImageForDetail("image/png", data, img.ImageDetailLow)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", mimeType)
+	assert.Less(t, len(resized), len(data))
+
+	width, height, err := img.GetImageSizeFromBase64(resized)
+	assert.Nil(t, err)
+	assert.Equal(t, 512, width)
+	assert.Equal(t, 384, height)
+}
+
+func TestResizeImageForDetailPassThrough(t *testing.T) {
+	data := getTestPNG(t, 1024, 768)
+
+	for _, detail := range []string{img.ImageDetailHigh, img.ImageDetailAuto, ""} {
+		mimeType, resized, err := img.ResizeImageForDetail("image/png", data, detail)
+		assert.Nil(t, err)
+		assert.Equal(t, "image/png", mimeType)
+		assert.Equal(t, data, resized)
+	}
+
+	// 小图和非图片内容不处理
+	small := getTestPNG(t, 100, 100)
+	_, resized, err := img.ResizeImageForDetail("image/png", small, img.ImageDetailLow)
+	assert.Nil(t, err)
+	assert.Equal(t, small, resized)
+
+	mimeType, resized, err := img.ResizeImageForDetail("application/pdf", "JVBERi0=", img.ImageDetailLow)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/pdf", mimeType)
+	assert.Equal(t, "JVBERi0=", resized)
+}
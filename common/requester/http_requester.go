@@ -26,6 +26,22 @@ type HTTPRequester struct {
 	proxyAddr         string
 	Context           context.Context
 	IsOpenAI          bool
+	// 收到上游响应后回调，错误响应也会回调
+	OnResponse func(*http.Response)
+}
+
+// 上游返回请求 ID 的响应头，按顺序查找
+var UpstreamRequestIdHeaders = []string{"request-id", "x-request-id", "apim-request-id"}
+
+// GetUpstreamRequestId 从上游响应头中获取请求 ID
+func GetUpstreamRequestId(header http.Header) string {
+	for _, key := range UpstreamRequestIdHeaders {
+		if id := header.Get(key); id != "" {
+			return id
+		}
+	}
+
+	return ""
 }
 
 // NewHTTPRequester 创建一个新的 HTTPRequester 实例。
@@ -79,6 +95,10 @@ func (r *HTTPRequester) SendRequest(req *http.Request, response any, outputResp
 		return nil, common.ErrorWrapper(err, "http_request_failed", http.StatusInternalServerError)
 	}
 
+	if r.OnResponse != nil {
+		r.OnResponse(resp)
+	}
+
 	if !outputResp {
 		defer resp.Body.Close()
 	}
@@ -119,6 +139,10 @@ func (r *HTTPRequester) SendRequestRaw(req *http.Request) (*http.Response, *type
 		return nil, common.ErrorWrapper(err, "http_request_failed", http.StatusInternalServerError)
 	}
 
+	if r.OnResponse != nil {
+		r.OnResponse(resp)
+	}
+
 	// 处理响应
 	if r.IsFailureStatusCode(resp) {
 		return nil, HandleErrorResp(resp, r.ErrorHandler, r.IsOpenAI)
@@ -197,6 +221,7 @@ func HandleErrorResp(resp *http.Response, toOpenAIError HttpErrorHandler, isPref
 			Code:    "bad_response_status_code",
 			Param:   strconv.Itoa(resp.StatusCode),
 		},
+		RawUpstreamRequestId: GetUpstreamRequestId(resp.Header),
 	}
 
 	defer resp.Body.Close()
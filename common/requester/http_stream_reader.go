@@ -3,11 +3,17 @@ package requester
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"one-api/common/logger"
 	"one-api/types"
 	"runtime/debug"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
@@ -96,3 +102,23 @@ func (stream *streamReader[T]) processLines() {
 func (stream *streamReader[T]) Close() {
 	stream.response.Body.Close()
 }
+
+// IsStreamResetError 判断流读取错误是否为连接被重置或意外断开，超时、DNS 解析失败等其他网络错误不属于此类
+func IsStreamResetError(err error) bool {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+
+	// HTTP/2 的 RST_STREAM 以 "stream error: stream ID" 开头
+	errMsg := err.Error()
+	return strings.Contains(errMsg, "connection reset by peer") || strings.Contains(errMsg, "stream error: stream ID")
+}
@@ -0,0 +1,66 @@
+package retry
+
+import (
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	retryBudgetKey = "retry_budget"
+	attemptsKey    = "retry_attempts"
+)
+
+// retryBudget 单个请求内渠道重试、流重置重试、strict 工具重试等所有重新发送共用的次数与时间预算
+type retryBudget struct {
+	maxAttempts int
+	deadline    time.Time
+	attempts    int
+}
+
+// getRetryBudget 获取请求的重试预算，首次调用时按当前配置创建
+func getRetryBudget(c *gin.Context) *retryBudget {
+	if budget, ok := utils.GetGinValue[*retryBudget](c, retryBudgetKey); ok && budget != nil {
+		return budget
+	}
+
+	budget := &retryBudget{maxAttempts: config.RetryBudgetAttempts}
+	if config.RetryBudgetSeconds > 0 {
+		startTime := c.GetTime("requestStartTime")
+		if startTime.IsZero() {
+			startTime = time.Now()
+		}
+		budget.deadline = startTime.Add(time.Duration(config.RetryBudgetSeconds) * time.Second)
+	}
+	c.Set(retryBudgetKey, budget)
+
+	return budget
+}
+
+// Acquire 消耗一次恢复尝试，预算耗尽时返回 false
+func Acquire(c *gin.Context) bool {
+	budget := getRetryBudget(c)
+	if budget.maxAttempts > 0 && budget.attempts >= budget.maxAttempts {
+		logger.LogWarn(c.Request.Context(), fmt.Sprintf("retry budget exhausted after %d attempts", budget.attempts))
+		return false
+	}
+
+	if !budget.deadline.IsZero() && time.Now().After(budget.deadline) {
+		logger.LogWarn(c.Request.Context(), fmt.Sprintf("retry budget exhausted after %d attempts, time limit reached", budget.attempts))
+		return false
+	}
+
+	budget.attempts++
+	c.Set(attemptsKey, budget.attempts)
+
+	return true
+}
+
+// Attempts 返回请求已消耗的恢复尝试次数
+func Attempts(c *gin.Context) int {
+	return c.GetInt(attemptsKey)
+}
@@ -0,0 +1,42 @@
+package retry
+
+import (
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/test"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Logger = zap.NewNop()
+}
+
+func TestAcquireAttempts(t *testing.T) {
+	config.RetryBudgetAttempts = 2
+	defer func() { config.RetryBudgetAttempts = 0 }()
+
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	assert.True(t, Acquire(c))
+	assert.True(t, Acquire(c))
+	assert.False(t, Acquire(c))
+	assert.Equal(t, 2, Attempts(c))
+}
+
+func TestAcquireSeconds(t *testing.T) {
+	config.RetryBudgetSeconds = 5
+	defer func() { config.RetryBudgetSeconds = 0 }()
+
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("requestStartTime", time.Now())
+	assert.True(t, Acquire(c))
+
+	// 超过时间预算后不再重试
+	c, _ = test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("requestStartTime", time.Now().Add(-10*time.Second))
+	assert.False(t, Acquire(c))
+	assert.Zero(t, Attempts(c))
+}
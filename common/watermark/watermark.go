@@ -0,0 +1,114 @@
+package watermark
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+)
+
+// 水印由零宽字符组成：以 marker 包裹，中间每个字符表示 1 bit
+// 内容为加密后的 4 字节令牌 ID + 6 字节签名，签名使用服务端密钥的 HMAC，无法伪造，也不暴露令牌 ID
+const (
+	marker = '\u2060'
+	bit0   = '\u200b'
+	bit1   = '\u200c'
+
+	idBytes      = 4
+	tagBytes     = 6
+	payloadBytes = idBytes + tagBytes
+	payloadBits  = payloadBytes * 8
+)
+
+var secret []byte
+
+// InitSecret 设置签名密钥，修改后之前的水印将无法解析
+func InitSecret(key string) {
+	secret = []byte(key)
+}
+
+// Encode 将令牌 ID 编码为不可见的水印
+func Encode(tokenId int) string {
+	id := make([]byte, idBytes)
+	binary.BigEndian.PutUint32(id, uint32(tokenId))
+	tag := sign("tag", id)[:tagBytes]
+
+	payload := make([]byte, 0, payloadBytes)
+	payload = append(payload, mask(id, tag)...)
+	payload = append(payload, tag...)
+
+	var builder strings.Builder
+	builder.WriteRune(marker)
+	for _, b := range payload {
+		for i := 7; i >= 0; i-- {
+			if b&(1<<i) != 0 {
+				builder.WriteRune(bit1)
+			} else {
+				builder.WriteRune(bit0)
+			}
+		}
+	}
+	builder.WriteRune(marker)
+
+	return builder.String()
+}
+
+// Decode 从文本中找出第一个有效的水印，返回其中的令牌 ID
+func Decode(text string) (tokenId int, ok bool) {
+	runes := []rune(text)
+	for start := 0; start < len(runes); start++ {
+		if runes[start] != marker {
+			continue
+		}
+
+		end := start + payloadBits + 1
+		if end >= len(runes) || runes[end] != marker {
+			continue
+		}
+
+		if tokenId, ok = decodePayload(runes[start+1 : end]); ok {
+			return tokenId, true
+		}
+	}
+
+	return 0, false
+}
+
+func decodePayload(bits []rune) (int, bool) {
+	payload := make([]byte, payloadBytes)
+	for i, r := range bits {
+		switch r {
+		case bit1:
+			payload[i/8] |= 1 << (7 - i%8)
+		case bit0:
+		default:
+			return 0, false
+		}
+	}
+
+	tag := payload[idBytes:]
+	id := mask(payload[:idBytes], tag)
+	if !hmac.Equal(sign("tag", id)[:tagBytes], tag) {
+		return 0, false
+	}
+
+	return int(binary.BigEndian.Uint32(id)), true
+}
+
+// mask 用由签名派生的密钥流加密或解密令牌 ID
+func mask(id, tag []byte) []byte {
+	stream := sign("mask", tag)
+	masked := make([]byte, len(id))
+	for i := range id {
+		masked[i] = id[i] ^ stream[i]
+	}
+
+	return masked
+}
+
+func sign(purpose string, data []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte("one-hub-watermark-" + purpose + ":"))
+	h.Write(data)
+	return h.Sum(nil)
+}
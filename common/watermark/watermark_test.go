@@ -0,0 +1,60 @@
+package watermark
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, tokenId := range []int{1, 42, 65535, 1 << 30} {
+		mark := Encode(tokenId)
+		assert.Len(t, []rune(mark), payloadBits+2)
+		// 只包含零宽字符
+		assert.Empty(t, strings.Trim(mark, string([]rune{marker, bit0, bit1})))
+
+		decoded, ok := Decode("Hello" + mark + ", world")
+		assert.True(t, ok)
+		assert.Equal(t, tokenId, decoded)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, ok := Decode("plain text")
+	assert.False(t, ok)
+
+	// 校验不通过的零宽字符序列不是水印
+	runes := []rune(Encode(42))
+	if runes[1] == bit0 {
+		runes[1] = bit1
+	} else {
+		runes[1] = bit0
+	}
+	_, ok = Decode("Hello" + string(runes))
+	assert.False(t, ok)
+
+	// 截断的水印
+	_, ok = Decode(string([]rune(Encode(42))[:20]))
+	assert.False(t, ok)
+
+	// 前面有无效序列时仍能找到后面的有效水印
+	tokenId, ok := Decode(string(runes) + "Hi" + Encode(7))
+	assert.True(t, ok)
+	assert.Equal(t, 7, tokenId)
+}
+
+func TestDecodeWithSecret(t *testing.T) {
+	InitSecret("secret-a")
+	defer InitSecret("")
+
+	mark := Encode(42)
+	tokenId, ok := Decode(mark)
+	assert.True(t, ok)
+	assert.Equal(t, 42, tokenId)
+
+	// 不知道密钥无法解析或伪造水印
+	InitSecret("secret-b")
+	_, ok = Decode(mark)
+	assert.False(t, ok)
+}
@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"syscall"
+	"time"
+)
+
+// errNotRetriable 标记无需重试的失败：目标地址被拒绝或对端返回 4xx
+var errNotRetriable = errors.New("not retriable")
+
+// deniedPrefixes IANA 特殊用途地址段，标准库的 Is* 判断未覆盖这些地址
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT，部分云厂商的元数据服务在此段（如 100.100.100.200）
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+var (
+	// MaxRetries 发送失败后的最大重试次数
+	MaxRetries = 3
+	// RetryInterval 首次重试的等待时间，之后每次翻倍
+	RetryInterval = 1 * time.Second
+
+	// 不使用代理，每次建立连接时再次检查实际连接的地址，防止 DNS 重绑定或重定向到内网
+	client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: checkDialAddress}).DialContext,
+		},
+	}
+)
+
+// ValidateURL 校验回调地址：只允许 http/https，且域名解析结果必须是公网地址
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook url must be an http or https url")
+	}
+
+	if config.WebhookAllowPrivateNetwork {
+		return nil
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return fmt.Errorf("webhook host %s cannot be resolved", u.Hostname())
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return fmt.Errorf("webhook host %s resolves to a non-public address", u.Hostname())
+		}
+	}
+
+	return nil
+}
+
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if config.WebhookAllowPrivateNetwork {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("webhook address %s is not a public address: %w", host, errNotRetriable)
+	}
+
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	if addr.Is4() && addr == netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+		return false
+	}
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Deliver 异步将 payload 以 JSON 格式 POST 到 url，失败时按退避重试
+func Deliver(ctx context.Context, url string, payload any) {
+	go func() {
+		if err := DeliverSync(url, payload); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("webhook delivery to %s failed: %s", url, err.Error()))
+		}
+	}()
+}
+
+// DeliverSync 同步发送，仅在网络错误或 5xx 时重试，返回最后一次失败的原因
+func DeliverSync(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	interval := RetryInterval
+	for attempt := 0; ; attempt++ {
+		err = send(url, body)
+		if err == nil || errors.Is(err, errNotRetriable) || attempt >= MaxRetries {
+			return err
+		}
+
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+func send(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d: %w", resp.StatusCode, errNotRetriable)
+	}
+
+	return nil
+}
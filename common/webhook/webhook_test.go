@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliverSyncRetry(t *testing.T) {
+	RetryInterval = time.Millisecond
+	config.WebhookAllowPrivateNetwork = true
+	defer func() {
+		RetryInterval = time.Second
+		config.WebhookAllowPrivateNetwork = false
+	}()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	assert.Nil(t, DeliverSync(server.URL, map[string]any{"ok": true}))
+	assert.Equal(t, 3, calls)
+
+	// 超出重试次数后返回错误
+	calls = -10
+	assert.NotNil(t, DeliverSync(server.URL, map[string]any{"ok": true}))
+	assert.Equal(t, -10+MaxRetries+1, calls)
+}
+
+func TestDeliverSyncNoRetryOnClientError(t *testing.T) {
+	RetryInterval = time.Millisecond
+	config.WebhookAllowPrivateNetwork = true
+	defer func() {
+		RetryInterval = time.Second
+		config.WebhookAllowPrivateNetwork = false
+	}()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	assert.NotNil(t, DeliverSync(server.URL, map[string]any{"ok": true}))
+	assert.Equal(t, 1, calls)
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, rawURL := range []string{
+		"ftp://8.8.8.8/hook",
+		"not a url",
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://100.100.100.200/latest/meta-data",
+		"http://198.18.0.1/hook",
+		"http://192.0.0.1/hook",
+		"http://240.0.0.1/hook",
+		"http://[fd00::1]/hook",
+		"http://[::ffff:100.100.100.200]/hook",
+	} {
+		assert.NotNil(t, ValidateURL(rawURL), rawURL)
+	}
+
+	assert.Nil(t, ValidateURL("https://8.8.8.8/hook"))
+
+	// 允许内网地址时只检查协议
+	config.WebhookAllowPrivateNetwork = true
+	defer func() { config.WebhookAllowPrivateNetwork = false }()
+	assert.Nil(t, ValidateURL("http://127.0.0.1/hook"))
+	assert.NotNil(t, ValidateURL("ftp://127.0.0.1/hook"))
+}
+
+func TestDeliverSyncRejectPrivateAddress(t *testing.T) {
+	RetryInterval = time.Millisecond
+	defer func() { RetryInterval = time.Second }()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	// 连接时检查目标地址，未通过保存时校验的地址同样被拒绝
+	assert.NotNil(t, DeliverSync(server.URL, map[string]any{"ok": true}))
+	assert.Equal(t, 0, calls)
+}
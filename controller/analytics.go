@@ -2,7 +2,9 @@ package controller
 
 import (
 	"net/http"
+	"one-api/common"
 	"one-api/model"
+	"one-api/relay/relay_util"
 	"strconv"
 	"time"
 
@@ -91,3 +93,32 @@ func GetStatisticsDetail(c *gin.Context) {
 		"data":    statisticsDetail,
 	})
 }
+
+// GetChannelLabelStatistics 按渠道标签统计指定时间段内的用量
+func GetChannelLabelStatistics(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+
+	startDate := time.Unix(startTimestamp, 0).Format("2006-01-02")
+	endDate := time.Unix(endTimestamp, 0).Format("2006-01-02")
+	statistics, err := model.GetChannelLabelStatisticsByPeriod(startDate, endDate)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    statistics,
+	})
+}
+
+// GetStreamingStatistics 获取当前实例中各用户和令牌的流式请求数
+func GetStreamingStatistics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    relay_util.GetStreamingStatistics(),
+	})
+}
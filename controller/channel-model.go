@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"one-api/model"
 	"one-api/providers"
@@ -42,25 +43,7 @@ func GetModelList(c *gin.Context) {
 		}
 	}
 
-	provider := providers.GetProvider(channel, c)
-	if provider == nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": "provider not found",
-		})
-		return
-	}
-
-	modelProvider, ok := provider.(providersBase.ModelListInterface)
-	if !ok {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": "channel not implemented",
-		})
-		return
-	}
-
-	modelList, err := modelProvider.GetModelList()
+	modelList, err := fetchChannelModelList(c, channel)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -79,6 +62,35 @@ func GetModelList(c *gin.Context) {
 	})
 }
 
+var (
+	errModelListProviderNotFound = errors.New("provider not found")
+	errModelListNotImplemented   = errors.New("channel not implemented")
+)
+
+// fetchChannelModelList 使用渠道配置请求上游的模型列表
+func fetchChannelModelList(c *gin.Context, channel *model.Channel) ([]string, error) {
+	modelProvider, err := getModelListProvider(c, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return modelProvider.GetModelList()
+}
+
+func getModelListProvider(c *gin.Context, channel *model.Channel) (providersBase.ModelListInterface, error) {
+	provider := providers.GetProvider(channel, c)
+	if provider == nil {
+		return nil, errModelListProviderNotFound
+	}
+
+	modelProvider, ok := provider.(providersBase.ModelListInterface)
+	if !ok {
+		return nil, errModelListNotImplemented
+	}
+
+	return modelProvider, nil
+}
+
 // 辅助函数：去除切片中的重复元素
 func removeDuplicates(slice []string) []string {
 	keys := make(map[string]bool)
@@ -13,6 +13,7 @@ import (
 	"one-api/model"
 	"one-api/providers"
 	providers_base "one-api/providers/base"
+	"one-api/relay/relay_util"
 	"one-api/types"
 	"regexp"
 	"strconv"
@@ -331,3 +332,123 @@ func AutomaticallyTestChannels(frequency int) {
 		logger.SysLog("channel test finished")
 	}
 }
+
+// runChannelTestPrompt 使用渠道保存的测试提示词走完整的对话流程（请求转换、发送、响应转换）
+func runChannelTestPrompt(c *gin.Context, channel *model.Channel, testModel string) (*types.ChatCompletionResponse, *types.Usage, error) {
+	if channel.TestPrompt == "" {
+		return nil, nil, errors.New("请填写测试提示词后再试")
+	}
+	if testModel == "" {
+		return nil, nil, errors.New("请填写测速模型后再试")
+	}
+	channel.SetProxy()
+
+	testContext, _ := gin.CreateTestContext(httptest.NewRecorder())
+	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", "/v1/chat/completions", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	testContext.Request = req
+
+	provider := providers.GetProvider(channel, testContext)
+	if provider == nil {
+		return nil, nil, errors.New("channel not implemented")
+	}
+	chatProvider, ok := provider.(providers_base.ChatInterface)
+	if !ok {
+		return nil, nil, errors.New("channel not implemented")
+	}
+
+	newModelName, err := provider.ModelMappingHandler(testModel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usage := &types.Usage{}
+	provider.SetUsage(usage)
+
+	response, openAIErrorWithStatusCode := chatProvider.CreateChatCompletion(&types.ChatCompletionRequest{
+		Messages: []types.ChatCompletionMessage{
+			{
+				Role:    types.ChatMessageRoleUser,
+				Content: channel.TestPrompt,
+			},
+		},
+		Model:  strings.TrimPrefix(newModelName, "+"),
+		Stream: false,
+	})
+	if openAIErrorWithStatusCode != nil {
+		return nil, usage, errors.New(openAIErrorWithStatusCode.Message)
+	}
+
+	return response, usage, nil
+}
+
+// TestChannelPrompt 使用渠道的测试提示词测试渠道，billing=true 时按当前管理员的分组倍率计费
+func TestChannelPrompt(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	channel, err := model.GetChannelById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	testModel := c.DefaultQuery("model", channel.TestModel)
+	tik := time.Now()
+	response, usage, err := runChannelTestPrompt(c, channel, testModel)
+	consumedTime := float64(time.Since(tik).Milliseconds()) / 1000.0
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("测试失败，原因：%s", err.Error()),
+			"time":    consumedTime,
+		})
+		return
+	}
+
+	if c.Query("billing") == "true" {
+		billChannelTestPrompt(c, channel, testModel, usage, tik)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"time":    consumedTime,
+		"data":    response,
+	})
+}
+
+// billChannelTestPrompt 将测试请求按正常请求记入当前管理员的用量
+// 测试请求没有令牌，只扣除用户额度，日志以 channel_test 标记并记录渠道，便于追溯
+func billChannelTestPrompt(c *gin.Context, channel *model.Channel, modelName string, usage *types.Usage, startTime time.Time) {
+	group, err := model.CacheGetUserGroup(c.GetInt("id"))
+	if err != nil {
+		logger.LogError(c.Request.Context(), "failed to get user group: "+err.Error())
+		return
+	}
+	groupRatio := model.GlobalUserGroupRatio.GetBySymbol(group)
+	if groupRatio == nil {
+		logger.LogError(c.Request.Context(), "user group not found: "+group)
+		return
+	}
+
+	c.Set("channel_id", channel.Id)
+	c.Set("token_name", "channel_test")
+	c.Set("token_unlimited_quota", true)
+	c.Set("token_group", group)
+	c.Set("group_ratio", groupRatio.Ratio)
+	c.Set("requestStartTime", startTime)
+	c.Set("channel_test", true)
+	relay_util.NewQuota(c, modelName, usage.PromptTokens).Consume(c, usage, false)
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/model"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestChannelTestPromptHandler(t *testing.T) {
+	requester.InitHttpClient()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.Channel{}))
+	model.DB = db
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+
+		// 请求已转换为 Claude 格式
+		body, _ := io.ReadAll(r.Body)
+		var claudeRequest map[string]any
+		assert.Nil(t, json.Unmarshal(body, &claudeRequest))
+		assert.Equal(t, "claude-3-5-haiku-20241022", claudeRequest["model"])
+		assert.NotZero(t, claudeRequest["max_tokens"])
+		assert.Contains(t, string(body), `"text":"Reply with the word pong."`)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"pong"}],"usage":{"input_tokens":12,"output_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	baseURL, proxy := server.URL, ""
+	channel := &model.Channel{
+		Type:       config.ChannelTypeAnthropic,
+		Key:        "sk-test",
+		Name:       "claude",
+		BaseURL:    &baseURL,
+		Proxy:      &proxy,
+		TestModel:  "claude-3-5-haiku-20241022",
+		TestPrompt: "Reply with the word pong.",
+	}
+	assert.Nil(t, db.Create(channel).Error)
+
+	router := gin.New()
+	router.GET("/test_prompt/:id", TestChannelPrompt)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test_prompt/"+strconv.Itoa(channel.Id), nil))
+
+	var response struct {
+		Success bool    `json:"success"`
+		Message string  `json:"message"`
+		Time    float64 `json:"time"`
+		Data    struct {
+			Object  string `json:"object"`
+			Choices []struct {
+				Message      map[string]any `json:"message"`
+				FinishReason string         `json:"finish_reason"`
+			} `json:"choices"`
+			Usage struct {
+				PromptTokens int `json:"prompt_tokens"`
+			} `json:"usage"`
+		} `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success, response.Message)
+
+	// 响应已转换为 OpenAI 格式
+	assert.Equal(t, "chat.completion", response.Data.Object)
+	assert.Equal(t, "pong", response.Data.Choices[0].Message["content"])
+	assert.Equal(t, "stop", response.Data.Choices[0].FinishReason)
+	assert.Equal(t, 12, response.Data.Usage.PromptTokens)
+}
+
+func TestChannelTestPromptBilling(t *testing.T) {
+	requester.InitHttpClient()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.Channel{}, &model.User{}, &model.Token{}, &model.Log{}))
+	model.DB = db
+
+	logConsumeEnabled := config.LogConsumeEnabled
+	config.LogConsumeEnabled = true
+	model.PricingInstance = &model.Pricing{Prices: map[string]*model.Price{
+		"claude-3-5-haiku-20241022": {Model: "claude-3-5-haiku-20241022", Type: model.TokensPriceType, Input: 1, Output: 2},
+	}}
+	model.GlobalUserGroupRatio.UserGroup = map[string]*model.UserGroup{
+		"default": {Symbol: "default", Name: "default", Ratio: 1},
+	}
+	defer func() {
+		config.LogConsumeEnabled = logConsumeEnabled
+		model.PricingInstance = nil
+		model.GlobalUserGroupRatio.UserGroup = nil
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"pong"}],"usage":{"input_tokens":12,"output_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	admin := &model.User{Username: "admin", Group: "default", Quota: 1000, Status: config.UserStatusEnabled}
+	assert.Nil(t, db.Create(admin).Error)
+
+	baseURL, proxy := server.URL, ""
+	channel := &model.Channel{
+		Type:       config.ChannelTypeAnthropic,
+		Key:        "sk-test",
+		Name:       "claude",
+		BaseURL:    &baseURL,
+		Proxy:      &proxy,
+		TestModel:  "claude-3-5-haiku-20241022",
+		TestPrompt: "Reply with the word pong.",
+	}
+	assert.Nil(t, db.Create(channel).Error)
+
+	router := gin.New()
+	router.GET("/test_prompt/:id", func(c *gin.Context) {
+		c.Set("id", admin.Id)
+		TestChannelPrompt(c)
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test_prompt/"+strconv.Itoa(channel.Id)+"?billing=true", nil))
+	assert.Contains(t, w.Body.String(), `"success":true`)
+
+	// 计费在后台完成：12 * 1 + 2 * 2
+	var log model.Log
+	assert.Eventually(t, func() bool {
+		return db.Where("type = ?", model.LogTypeConsume).First(&log).Error == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, admin.Id, log.UserId)
+	assert.Equal(t, channel.Id, log.ChannelId)
+	assert.Equal(t, "channel_test", log.TokenName)
+	assert.Equal(t, 16, log.Quota)
+	assert.Equal(t, true, log.Metadata.Data()["channel_test"])
+
+	quota, err := model.GetUserQuota(admin.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000-16, quota)
+}
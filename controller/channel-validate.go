@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common/logger"
+	"one-api/model"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 校验失败的原因分类，前端据此区分密钥问题和上游问题
+const (
+	validateKeyRejected            = "key_rejected"
+	validateKeyUpstreamUnreachable = "upstream_unreachable"
+	validateKeyUpstreamError       = "upstream_error"
+)
+
+var validateKeyErrorMessages = map[string]string{
+	validateKeyRejected:            "密钥无效或没有权限，上游拒绝了请求",
+	validateKeyUpstreamUnreachable: "无法连接上游服务或请求超时",
+	validateKeyUpstreamError:       "上游服务返回错误，无法获取模型列表",
+}
+
+type validateChannelKeyRequest struct {
+	Type    int    `json:"type" binding:"required"`
+	BaseURL string `json:"base_url"`
+	Proxy   string `json:"proxy"`
+	Key     string `json:"key" binding:"required"`
+}
+
+// ValidateChannelKey 使用临时渠道请求上游模型列表，校验密钥是否可用，不会保存任何数据
+func ValidateChannelKey(c *gin.Context) {
+	var request validateChannelKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	channel := &model.Channel{
+		Type:    request.Type,
+		Key:     strings.TrimSpace(strings.Split(request.Key, "\n")[0]),
+		BaseURL: &request.BaseURL,
+		Proxy:   &request.Proxy,
+	}
+
+	modelProvider, err := getModelListProvider(c, channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 记录上游的响应状态码，没有收到响应说明无法连接或超时
+	statusCode := 0
+	httpRequester := modelProvider.GetRequester()
+	onResponse := httpRequester.OnResponse
+	httpRequester.OnResponse = func(resp *http.Response) {
+		statusCode = resp.StatusCode
+		if onResponse != nil {
+			onResponse(resp)
+		}
+	}
+
+	data := gin.H{
+		"valid":  true,
+		"reason": "",
+		"error":  "",
+	}
+	if _, err := modelProvider.GetModelList(); err != nil {
+		// 上游的原始错误可能包含地址等内部信息，只记录到日志
+		logger.LogError(c.Request.Context(), fmt.Sprintf("validate channel key failed, status code %d: %s", statusCode, err.Error()))
+
+		reason := classifyValidateKeyError(statusCode)
+		data["valid"] = false
+		data["reason"] = reason
+		data["error"] = validateKeyErrorMessages[reason]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+func classifyValidateKeyError(statusCode int) string {
+	switch {
+	case statusCode == 0:
+		return validateKeyUpstreamUnreachable
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return validateKeyRejected
+	default:
+		return validateKeyUpstreamError
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func validateChannelKey(t *testing.T, baseURL, key string) gin.H {
+	body, _ := json.Marshal(gin.H{"type": config.ChannelTypeAnthropic, "base_url": baseURL, "key": key})
+	router := gin.New()
+	router.POST("/validate_key", ValidateChannelKey)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate_key", bytes.NewReader(body)))
+
+	var response struct {
+		Success bool  `json:"success"`
+		Data    gin.H `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+
+	return response.Data
+}
+
+func TestValidateChannelKey(t *testing.T) {
+	requester.InitHttpClient()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Header.Get("x-api-key") {
+		case "valid-key":
+			w.Write([]byte(`{"data":[{"type":"model","id":"claude-3-5-sonnet-20241022"}],"has_more":false}`))
+		case "forbidden-key":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"type":"error","error":{"type":"permission_error","message":"forbidden"}}`))
+		case "overloaded":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"overloaded"}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+		}
+	}))
+	defer server.Close()
+
+	data := validateChannelKey(t, server.URL, "valid-key")
+	assert.Equal(t, true, data["valid"])
+	assert.Equal(t, "", data["reason"])
+	assert.Equal(t, "", data["error"])
+
+	for key, reason := range map[string]string{
+		"invalid-key":   validateKeyRejected,
+		"forbidden-key": validateKeyRejected,
+		"overloaded":    validateKeyUpstreamError,
+	} {
+		data = validateChannelKey(t, server.URL, key)
+		assert.Equal(t, false, data["valid"], key)
+		assert.Equal(t, reason, data["reason"], key)
+		assert.Equal(t, validateKeyErrorMessages[reason], data["error"], key)
+	}
+}
+
+func TestValidateChannelKeyUnreachable(t *testing.T) {
+	requester.InitHttpClient()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	baseURL := server.URL
+	server.Close()
+
+	data := validateChannelKey(t, baseURL, "valid-key")
+	assert.Equal(t, false, data["valid"])
+	assert.Equal(t, validateKeyUpstreamUnreachable, data["reason"])
+	assert.Equal(t, validateKeyErrorMessages[validateKeyUpstreamUnreachable], data["error"])
+}
@@ -65,6 +65,13 @@ func AddChannel(c *gin.Context) {
 		})
 		return
 	}
+	if err = channel.ValidateHeaders(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	channel.CreatedTime = utils.GetTimestamp()
 	keys := strings.Split(channel.Key, "\n")
 
@@ -164,6 +171,13 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	if err = channel.ValidateHeaders(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	if channel.Models == "" {
 		err = channel.Update(false)
 	} else {
@@ -327,7 +327,7 @@ func GitHubOAuth(c *gin.Context) {
 		return
 	}
 
-	setupLogin(user, c)
+	setupLogin(user, c, model.LoginMethodGitHub)
 }
 
 func GitHubBind(c *gin.Context) {
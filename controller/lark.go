@@ -224,7 +224,7 @@ func LarkOAuth(c *gin.Context) {
 		})
 		return
 	}
-	setupLogin(&user, c)
+	setupLogin(&user, c, model.LoginMethodLark)
 }
 
 func LarkBind(c *gin.Context) {
@@ -1,6 +1,9 @@
 package controller
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,7 +20,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const (
+var (
 	linuxDoTokenEndpoint = "https://connect.linux.do/oauth2/token"
 	linuxDoUserEndpoint  = "https://connect.linux.do/api/user"
 )
@@ -29,12 +32,61 @@ type LinuxDoOAuthResponse struct {
 }
 
 type LinuxDoUser struct {
-	Id             int    `json:"id"`
-	Username       string `json:"username"`
-	Name           string `json:"name"`
-	AvatarTemplate string `json:"avatar_template"`
-	Active         bool   `json:"active"`
-	TrustLevel     int    `json:"trust_level"`
+	Id             int               `json:"id"`
+	Username       string            `json:"username"`
+	Name           string            `json:"name"`
+	AvatarTemplate string            `json:"avatar_template"`
+	Active         bool              `json:"active"`
+	TrustLevel     LinuxDoTrustLevel `json:"trust_level"`
+}
+
+// LinuxDoTrustLevel 区分 trust_level 字段缺失（或无法解析）与显式的 0
+type LinuxDoTrustLevel struct {
+	Level   int
+	Present bool
+}
+
+func (t *LinuxDoTrustLevel) UnmarshalJSON(data []byte) error {
+	var level any
+	if err := json.Unmarshal(data, &level); err != nil {
+		return nil
+	}
+
+	switch value := level.(type) {
+	case float64:
+		t.Level, t.Present = int(value), true
+	case string:
+		if parsed, err := strconv.Atoi(value); err == nil {
+			t.Level, t.Present = parsed, true
+		}
+	}
+
+	return nil
+}
+
+// isLinuxDoUserEligible 判断 LinuxDo 用户是否满足注册条件（active=true 且 trust_level>=LinuxDoMinTrustLevel），
+// trust_level 缺失或无法解析时按 LinuxDoTrustLevelFallback 处理
+func isLinuxDoUserEligible(linuxDoUser *LinuxDoUser) bool {
+	if !linuxDoUser.Active {
+		return false
+	}
+
+	level := linuxDoUser.TrustLevel.Level
+	if !linuxDoUser.TrustLevel.Present {
+		switch config.LinuxDoTrustLevelFallback {
+		case config.LinuxDoTrustLevelAsLevel1:
+			logger.SysLog(fmt.Sprintf("LinuxDo 用户 %d 的 trust_level 缺失或无法解析，按 trust_level=1 处理", linuxDoUser.Id))
+			level = 1
+		case config.LinuxDoTrustLevelAllow:
+			logger.SysError(fmt.Sprintf("LinuxDo 用户 %d 的 trust_level 缺失或无法解析，仍允许注册", linuxDoUser.Id))
+			return true
+		default:
+			logger.SysError(fmt.Sprintf("LinuxDo 用户 %d 的 trust_level 缺失或无法解析，视为不满足注册条件", linuxDoUser.Id))
+			return false
+		}
+	}
+
+	return level >= config.LinuxDoMinTrustLevel
 }
 
 func getLinuxDoUserInfoByCode(code string) (*LinuxDoUser, error) {
@@ -107,6 +159,42 @@ func getLinuxDoUserInfoByCode(code string) (*LinuxDoUser, error) {
 	return &linuxDoUser, nil
 }
 
+// getLinuxDoRegisterGroup 获取 LinuxDo 注册用户的分组，未配置或分组不存在时返回空，使用默认分组
+func getLinuxDoRegisterGroup() string {
+	group := config.LinuxDoDefaultGroup
+	if group == "" {
+		return ""
+	}
+
+	if model.GlobalUserGroupRatio.GetBySymbol(group) == nil {
+		logger.SysError("LinuxDo 注册分组不存在: " + group)
+		return ""
+	}
+
+	return group
+}
+
+// logLinuxDoInvitation 按 LinuxDoAffLogMode 记录邀请关系，邀请关系本身已随用户保存
+func logLinuxDoInvitation(user *model.User, affCode string, inviterId int) {
+	if inviterId == 0 {
+		return
+	}
+
+	switch config.LinuxDoAffLogMode {
+	case config.AffLogModePlain:
+		logger.SysLog(fmt.Sprintf("LinuxDo 用户 %s 通过邀请码 %s 注册，邀请人 #%d", user.Username, affCode, inviterId))
+	case config.AffLogModeHashed:
+		logger.SysLog(fmt.Sprintf("LinuxDo 用户 %s 通过邀请码 %s 注册，邀请人 %s", user.Username, hashAffValue(affCode), hashAffValue(strconv.Itoa(inviterId))))
+	}
+}
+
+// hashAffValue 使用数据库中保存的 LinuxDoAffHashSecret 计算 HMAC，避免通过枚举用户 id 还原，重启后哈希保持不变
+func hashAffValue(value string) string {
+	mac := hmac.New(sha256.New, []byte(config.LinuxDoAffHashSecret))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
 func getUserByLinuxDo(linuxDoUser *LinuxDoUser) (user *model.User, err error) {
 	linuxDoId := strconv.Itoa(linuxDoUser.Id)
 	if model.IsLinuxDoIdAlreadyTaken(linuxDoId) {
@@ -165,10 +253,10 @@ func LinuxDoOAuth(c *gin.Context) {
 
 	linuxDoId := strconv.Itoa(linuxDoUser.Id)
 	if user == nil {
-		if !linuxDoUser.Active || linuxDoUser.TrustLevel < 1 {
+		if !isLinuxDoUserEligible(linuxDoUser) {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
-				"message": "LinuxDo 账户未满足注册条件（active=true 且 trust_level>=1）",
+				"message": fmt.Sprintf("LinuxDo 账户未满足注册条件（active=true 且 trust_level>=%d）", config.LinuxDoMinTrustLevel),
 			})
 			return
 		}
@@ -184,6 +272,7 @@ func LinuxDoOAuth(c *gin.Context) {
 			LinuxDoId: linuxDoId,
 			Role:      config.RoleCommonUser,
 			Status:    config.UserStatusEnabled,
+			Group:     getLinuxDoRegisterGroup(),
 		}
 
 		var inviterId int
@@ -203,12 +292,18 @@ func LinuxDoOAuth(c *gin.Context) {
 		}
 
 		if err := user.Insert(inviterId); err != nil {
+			message := err.Error()
+			// 同一个 LinuxDo 账户并发注册时，由唯一约束保证只有一个成功
+			if model.IsLinuxDoIdConflictError(err) {
+				message = "该 LinuxDo 账户已被绑定"
+			}
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
-				"message": err.Error(),
+				"message": message,
 			})
 			return
 		}
+		logLinuxDoInvitation(user, affCode, inviterId)
 	} else {
 		user.LinuxDoId = linuxDoId
 	}
@@ -221,7 +316,7 @@ func LinuxDoOAuth(c *gin.Context) {
 		return
 	}
 
-	setupLogin(user, c)
+	setupLogin(user, c, model.LoginMethodLinuxDo)
 }
 
 func LinuxDoBind(c *gin.Context) {
@@ -271,9 +366,14 @@ func LinuxDoBind(c *gin.Context) {
 
 	err = user.Update(false)
 	if err != nil {
+		message := err.Error()
+		// 上面的检查与更新之间可能有其他用户绑定了同一个账户，由唯一约束兜底
+		if model.IsLinuxDoIdConflictError(err) {
+			message = "该 LinuxDo 账户已被绑定"
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
-			"message": err.Error(),
+			"message": message,
 		})
 		return
 	}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getLinuxDoCheck(checks []*LinuxDoDiagnosticCheck, name string) *LinuxDoDiagnosticCheck {
+	for _, check := range checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	return nil
+}
+
+func setLinuxDoEndpoints(tokenEndpoint, userEndpoint string) func() {
+	oldToken, oldUser := linuxDoTokenEndpoint, linuxDoUserEndpoint
+	linuxDoTokenEndpoint, linuxDoUserEndpoint = tokenEndpoint, userEndpoint
+	return func() {
+		linuxDoTokenEndpoint, linuxDoUserEndpoint = oldToken, oldUser
+		config.LinuxDoClientId, config.LinuxDoClientSecret = "", ""
+	}
+}
+
+func TestDiagnoseLinuxDoOAuthMissingConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	defer setLinuxDoEndpoints(server.URL+"/oauth2/token", server.URL+"/api/user")()
+
+	checks := diagnoseLinuxDoOAuth()
+	assert.False(t, getLinuxDoCheck(checks, "client_id").Success)
+	assert.False(t, getLinuxDoCheck(checks, "client_secret").Success)
+
+	config.LinuxDoClientId = "bad id"
+	config.LinuxDoClientSecret = "secret\n"
+	checks = diagnoseLinuxDoOAuth()
+	assert.Contains(t, getLinuxDoCheck(checks, "client_id").Message, "格式错误")
+	assert.Contains(t, getLinuxDoCheck(checks, "client_secret").Message, "空白字符")
+}
+
+func TestDiagnoseLinuxDoOAuthEndpoints(t *testing.T) {
+	config.LinuxDoClientId = "client_id-1"
+	config.LinuxDoClientSecret = "client_secret"
+
+	// 返回 4xx 视为可以连通
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+	}))
+	defer setLinuxDoEndpoints(server.URL+"/oauth2/token", server.URL+"/api/user")()
+
+	checks := diagnoseLinuxDoOAuth()
+	for _, check := range checks {
+		assert.True(t, check.Success, check.Name+": "+check.Message)
+	}
+
+	// 服务器无法连接
+	server.Close()
+	checks = diagnoseLinuxDoOAuth()
+	assert.True(t, getLinuxDoCheck(checks, "client_id").Success)
+	assert.False(t, getLinuxDoCheck(checks, "token_endpoint").Success)
+	assert.Contains(t, getLinuxDoCheck(checks, "token_endpoint").Message, "无法连接")
+	assert.False(t, getLinuxDoCheck(checks, "user_endpoint").Success)
+}
+
+func TestDiagnoseLinuxDoOAuthInvalidClient(t *testing.T) {
+	config.LinuxDoClientId = "client_id"
+	config.LinuxDoClientSecret = "wrong_secret"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_client"}`))
+	}))
+	defer server.Close()
+	defer setLinuxDoEndpoints(server.URL+"/oauth2/token", server.URL+"/api/user")()
+
+	checks := diagnoseLinuxDoOAuth()
+	assert.False(t, getLinuxDoCheck(checks, "token_endpoint").Success)
+	assert.Contains(t, getLinuxDoCheck(checks, "token_endpoint").Message, "invalid_client")
+}
@@ -0,0 +1,293 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+}
+
+// registerByLinuxDo 模拟一次完整的 LinuxDo 登录回调，返回注册的用户
+func registerByLinuxDo(t *testing.T, linuxDoId, affCode string) *model.User {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/oauth2/token" {
+			w.Write([]byte(`{"access_token":"access-token"}`))
+			return
+		}
+		w.Write([]byte(`{"id":` + linuxDoId + `,"username":"tester","name":"Tester","active":true,"trust_level":2}`))
+	}))
+	defer server.Close()
+	defer setLinuxDoEndpoints(server.URL+"/oauth2/token", server.URL+"/api/user")()
+	config.LinuxDoClientId = "client_id"
+	config.LinuxDoClientSecret = "client_secret"
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+	router.GET("/state", func(c *gin.Context) {
+		session := sessions.Default(c)
+		session.Set("oauth_state", "state-1")
+		session.Save()
+	})
+	router.GET("/oauth/linuxdo", LinuxDoOAuth)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/state", nil))
+
+	req := httptest.NewRequest("GET", "/oauth/linuxdo?state=state-1&code=code-1&aff="+affCode, nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"success":true`)
+
+	user, err := model.FindUserByField("linuxdo_id", linuxDoId)
+	assert.Nil(t, err)
+	return user
+}
+
+func TestLinuxDoOAuthRegisterGroup(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.User{}))
+	model.DB = db
+
+	config.LinuxDoOAuthEnabled = true
+	model.GlobalUserGroupRatio.UserGroup = map[string]*model.UserGroup{
+		"trial": {Symbol: "trial", Name: "trial", Ratio: 1},
+	}
+	defer func() {
+		config.LinuxDoOAuthEnabled = false
+		config.LinuxDoDefaultGroup = ""
+		model.GlobalUserGroupRatio.UserGroup = nil
+	}()
+
+	// 未配置时使用默认分组
+	assert.Equal(t, "default", registerByLinuxDo(t, "101", "").Group)
+
+	// 配置后 LinuxDo 注册用户进入指定分组
+	config.LinuxDoDefaultGroup = "trial"
+	assert.Equal(t, "trial", registerByLinuxDo(t, "102", "").Group)
+
+	// 分组不存在时回退到默认分组
+	config.LinuxDoDefaultGroup = "missing"
+	assert.Equal(t, "default", registerByLinuxDo(t, "103", "").Group)
+}
+
+func TestIsLinuxDoUserEligibleTrustLevel(t *testing.T) {
+	defer func() { config.LinuxDoTrustLevelFallback = config.LinuxDoTrustLevelIneligible }()
+
+	users := map[string]string{
+		"absent":      `{"id":1,"active":true}`,
+		"unparseable": `{"id":1,"active":true,"trust_level":{"level":2}}`,
+		"zero":        `{"id":1,"active":true,"trust_level":0}`,
+		"nonzero":     `{"id":1,"active":true,"trust_level":2}`,
+	}
+	expected := map[string]map[string]bool{
+		config.LinuxDoTrustLevelIneligible: {"absent": false, "unparseable": false, "zero": false, "nonzero": true},
+		config.LinuxDoTrustLevelAsLevel1:   {"absent": true, "unparseable": true, "zero": false, "nonzero": true},
+		config.LinuxDoTrustLevelAllow:      {"absent": true, "unparseable": true, "zero": false, "nonzero": true},
+	}
+
+	for policy, results := range expected {
+		config.LinuxDoTrustLevelFallback = policy
+		for name, body := range users {
+			var linuxDoUser LinuxDoUser
+			assert.Nil(t, json.Unmarshal([]byte(body), &linuxDoUser))
+			assert.Equal(t, results[name], isLinuxDoUserEligible(&linuxDoUser), policy+"/"+name)
+		}
+	}
+}
+
+func TestIsLinuxDoUserEligibleMinTrustLevel(t *testing.T) {
+	config.LinuxDoMinTrustLevel = 2
+	defer func() {
+		config.LinuxDoMinTrustLevel = 1
+		config.LinuxDoTrustLevelFallback = config.LinuxDoTrustLevelIneligible
+	}()
+
+	absent := &LinuxDoUser{Id: 1, Active: true}
+	level1 := &LinuxDoUser{Id: 1, Active: true, TrustLevel: LinuxDoTrustLevel{Level: 1, Present: true}}
+	level2 := &LinuxDoUser{Id: 1, Active: true, TrustLevel: LinuxDoTrustLevel{Level: 2, Present: true}}
+
+	assert.False(t, isLinuxDoUserEligible(level1))
+	assert.True(t, isLinuxDoUserEligible(level2))
+
+	// level_1 按 trust_level=1 参与最低等级判断，allow 直接放行
+	config.LinuxDoTrustLevelFallback = config.LinuxDoTrustLevelAsLevel1
+	assert.False(t, isLinuxDoUserEligible(absent))
+	config.LinuxDoTrustLevelFallback = config.LinuxDoTrustLevelAllow
+	assert.True(t, isLinuxDoUserEligible(absent))
+}
+
+func TestLinuxDoOAuthAffLogMode(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.User{}, &model.Log{}))
+	model.DB = db
+
+	inviter := &model.User{Username: "inviter", AffCode: "qxyz", Status: config.UserStatusEnabled}
+	assert.Nil(t, db.Create(inviter).Error)
+
+	config.LinuxDoOAuthEnabled = true
+	config.QuotaForInviter = 100
+	defer func() {
+		config.LinuxDoOAuthEnabled = false
+		config.QuotaForInviter = 0
+		config.LinuxDoAffLogMode = config.AffLogModeNone
+	}()
+
+	inviterLog := fmt.Sprintf("邀请人 #%d", inviter.Id)
+	modes := []struct {
+		mode      string
+		linuxDoId string
+		contains  []string
+		excludes  []string
+	}{
+		{config.AffLogModeNone, "201", nil, []string{"linuxdo_201"}},
+		{config.AffLogModeHashed, "202", []string{"linuxdo_202", hashAffValue("qxyz")}, []string{"qxyz", inviterLog}},
+		{config.AffLogModePlain, "203", []string{"linuxdo_203", "qxyz", inviterLog}, nil},
+	}
+
+	for index, item := range modes {
+		config.LinuxDoAffLogMode = item.mode
+		user := registerByLinuxDo(t, item.linuxDoId, "qxyz")
+
+		// 无论日志如何记录，邀请关系与奖励都保持不变
+		assert.Equal(t, inviter.Id, user.InviterId, item.mode)
+		quota, err := model.GetUserQuota(inviter.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, 100*(index+1), quota, item.mode)
+
+		entries, _ := logger.GetLatestLogs(10)
+		logs := ""
+		for _, entry := range entries {
+			logs += entry.Message + "\n"
+		}
+		for _, text := range item.contains {
+			assert.Contains(t, logs, text, item.mode)
+		}
+		for _, text := range item.excludes {
+			assert.NotContains(t, logs, text, item.mode)
+		}
+	}
+}
+
+func TestLinuxDoBindConcurrent(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	// 内存数据库每个连接是独立的库，共用一个连接
+	sqlDB.SetMaxOpenConns(1)
+	assert.Nil(t, db.AutoMigrate(&model.User{}))
+	assert.Nil(t, model.CreateLinuxDoIdUniqueIndex(db))
+	model.DB = db
+
+	const users = 8
+	// 所有请求都完成绑定前的检查后再继续，使检查与更新之间的竞争必然发生
+	var checked sync.WaitGroup
+	checked.Add(users)
+	var checks atomic.Int32
+	db.Callback().Query().After("gorm:query").Register("test:linuxdo_check", func(tx *gorm.DB) {
+		if strings.Contains(tx.Statement.SQL.String(), "linuxdo_id") && checks.Add(1) <= users {
+			checked.Done()
+			checked.Wait()
+		}
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/oauth2/token" {
+			w.Write([]byte(`{"access_token":"access-token"}`))
+			return
+		}
+		w.Write([]byte(`{"id":301,"username":"tester","name":"Tester","active":true,"trust_level":2}`))
+	}))
+	defer server.Close()
+	defer setLinuxDoEndpoints(server.URL+"/oauth2/token", server.URL+"/api/user")()
+	config.LinuxDoClientId = "client_id"
+	config.LinuxDoClientSecret = "client_secret"
+	config.LinuxDoOAuthEnabled = true
+	defer func() { config.LinuxDoOAuthEnabled = false }()
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+	router.GET("/login/:id", func(c *gin.Context) {
+		id, _ := strconv.Atoi(c.Param("id"))
+		session := sessions.Default(c)
+		session.Set("id", id)
+		session.Save()
+	})
+	router.GET("/bind", LinuxDoBind)
+
+	requests := make([]*http.Request, 0, users)
+	for i := 0; i < users; i++ {
+		user := &model.User{Username: fmt.Sprintf("bind%d", i), AccessToken: fmt.Sprintf("bind%d", i), AffCode: fmt.Sprintf("bind%d", i), Status: config.UserStatusEnabled}
+		assert.Nil(t, db.Create(user).Error)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/login/%d", user.Id), nil))
+		req := httptest.NewRequest("GET", "/bind?code=code-1", nil)
+		for _, cookie := range w.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		requests = append(requests, req)
+	}
+
+	var wg sync.WaitGroup
+	responses := make([]string, users)
+	for i, req := range requests {
+		wg.Add(1)
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			responses[i] = w.Body.String()
+		}(i, req)
+	}
+	wg.Wait()
+
+	// 只有一个用户绑定成功，其余返回已被绑定
+	success := 0
+	for _, response := range responses {
+		if strings.Contains(response, `"success":true`) {
+			success++
+			continue
+		}
+		assert.Contains(t, response, "该 LinuxDo 账户已被绑定")
+	}
+	assert.Equal(t, 1, success)
+
+	var count int64
+	db.Model(&model.User{}).Where("linuxdo_id = ?", "301").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// 删除后可以重新绑定
+	bound, err := model.FindUserByField("linuxdo_id", "301")
+	assert.Nil(t, err)
+	assert.Nil(t, model.DeleteUserById(bound.Id))
+	assert.False(t, model.IsLinuxDoIdAlreadyTaken("301"))
+}
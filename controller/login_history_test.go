@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupLoginHistoryDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.User{}, &model.LoginHistory{}))
+	model.DB = db
+}
+
+func loginByPassword(t *testing.T, username, password, userAgent string) {
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+	router.POST("/login", Login)
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"`+username+`","password":"`+password+`"}`))
+	req.Header.Set("User-Agent", userAgent)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"success":true`)
+}
+
+func TestLoginHistoryMethod(t *testing.T) {
+	setupLoginHistoryDB(t)
+
+	hashedPassword, err := common.Password2Hash("password123")
+	assert.Nil(t, err)
+	user := &model.User{Username: "history", Password: hashedPassword, Status: config.UserStatusEnabled, AccessToken: "history", AffCode: "history"}
+	assert.Nil(t, model.DB.Create(user).Error)
+
+	loginByPassword(t, "history", "password123", "test-agent/1.0")
+
+	histories, err := model.GetUserLoginHistory(user.Id)
+	assert.Nil(t, err)
+	assert.Len(t, histories, 1)
+	assert.Equal(t, model.LoginMethodPassword, histories[0].Method)
+	assert.Equal(t, "test-agent/1.0", histories[0].UserAgent)
+	assert.NotEmpty(t, histories[0].Ip)
+	assert.NotZero(t, histories[0].CreatedAt)
+
+	// LinuxDo 登录
+	config.LinuxDoOAuthEnabled = true
+	defer func() { config.LinuxDoOAuthEnabled = false }()
+	linuxDoUser := registerByLinuxDo(t, "201", "")
+
+	histories, err = model.GetUserLoginHistory(linuxDoUser.Id)
+	assert.Nil(t, err)
+	assert.Len(t, histories, 1)
+	assert.Equal(t, model.LoginMethodLinuxDo, histories[0].Method)
+}
+
+func TestLoginHistoryCapped(t *testing.T) {
+	setupLoginHistoryDB(t)
+	config.LoginHistoryMaxRecords = 3
+	defer func() { config.LoginHistoryMaxRecords = 20 }()
+
+	hashedPassword, err := common.Password2Hash("password123")
+	assert.Nil(t, err)
+	user := &model.User{Username: "capped", Password: hashedPassword, Status: config.UserStatusEnabled, AccessToken: "capped", AffCode: "capped"}
+	assert.Nil(t, model.DB.Create(user).Error)
+	other := &model.User{Username: "other", Password: hashedPassword, Status: config.UserStatusEnabled, AccessToken: "other", AffCode: "other"}
+	assert.Nil(t, model.DB.Create(other).Error)
+
+	loginByPassword(t, "other", "password123", "other-agent")
+	for _, agent := range []string{"agent-1", "agent-2", "agent-3", "agent-4", "agent-5"} {
+		loginByPassword(t, "capped", "password123", agent)
+	}
+
+	// 只保留最近的记录，最近的在前
+	histories, err := model.GetUserLoginHistory(user.Id)
+	assert.Nil(t, err)
+	assert.Len(t, histories, 3)
+	assert.Equal(t, "agent-5", histories[0].UserAgent)
+	assert.Equal(t, "agent-3", histories[2].UserAgent)
+
+	// 不影响其他用户的记录
+	histories, err = model.GetUserLoginHistory(other.Id)
+	assert.Nil(t, err)
+	assert.Len(t, histories, 1)
+}
@@ -135,7 +135,7 @@ func OIDCAuth(c *gin.Context) {
 	// 尝试通过OIDCid查询用户
 	if err = user.FillUserByOidcId(); err == nil {
 		if user.Status == config.UserStatusEnabled {
-			setupLogin(&user, c)
+			setupLogin(&user, c, model.LoginMethodOIDC)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
@@ -167,7 +167,7 @@ func OIDCAuth(c *gin.Context) {
 				})
 				return
 			}
-			setupLogin(&user, c)
+			setupLogin(&user, c, model.LoginMethodOIDC)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
@@ -228,5 +228,5 @@ func OIDCAuth(c *gin.Context) {
 		return
 	}
 
-	setupLogin(&user, c)
+	setupLogin(&user, c, model.LoginMethodOIDC)
 }
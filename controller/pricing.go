@@ -33,6 +33,32 @@ func GetPricesList(c *gin.Context) {
 	}
 }
 
+// GetUserEffectivePrices 获取当前用户所在分组（或指定的公开分组）应用倍率后的模型价格
+func GetUserEffectivePrices(c *gin.Context) {
+	userGroup, err := model.CacheGetUserGroup(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	group := c.DefaultQuery("group", userGroup)
+	groupRatio := model.GlobalUserGroupRatio.GetBySymbol(group)
+	if groupRatio == nil || (group != userGroup && !groupRatio.Public) {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("group not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"group":       group,
+			"group_ratio": groupRatio.Ratio,
+			"prices":      model.GetEffectivePricesList(groupRatio.Ratio),
+		},
+	})
+}
+
 func GetAllModelList(c *gin.Context) {
 	prices := model.PricingInstance.GetAllPrices()
 	channelModel := model.ChannelGroup.Rule
@@ -205,3 +231,50 @@ func GetUpdatePriceService(c *gin.Context) {
 		"message": "",
 	})
 }
+
+// ExportPricing 导出模型价格、模型信息及相关配置，用于迁移到其他实例
+func ExportPricing(c *gin.Context) {
+	bundle, err := model.ExportPricingBundle()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    bundle,
+	})
+}
+
+// ImportPricing 导入 ExportPricing 导出的配置，dry_run=true 时只返回变更不写入
+func ImportPricing(c *gin.Context) {
+	bundle := &model.PricingBundle{}
+	if err := c.ShouldBindJSON(bundle); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := bundle.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var diff *model.PricingBundleDiff
+	var err error
+	if c.Query("dry_run") == "true" {
+		diff, err = model.DiffPricingBundle(bundle)
+	} else {
+		diff, err = model.ImportPricingBundle(bundle)
+	}
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    diff,
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/utils"
+	"one-api/common/watermark"
+	"one-api/common/webhook"
 	"one-api/model"
 	"strconv"
 
@@ -179,9 +181,11 @@ func AddToken(c *gin.Context) {
 		return
 	}
 
-	// 非可信用户不能设置 BillingTag
+	// 非可信用户不能设置 BillingTag、特权令牌和最高优先级
 	if userRole < config.RoleReliableUser {
 		setting.BillingTag = nil
+		setting.Privileged = false
+		setting.MaxPriority = ""
 	}
 
 	cleanToken := model.Token{
@@ -313,11 +317,13 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.Group = token.Group
 		cleanToken.BackupGroup = token.BackupGroup
 
-		// 处理 BillingTag: 非可信用户保持原值不变
+		// 处理 BillingTag、特权令牌、最高优先级: 非可信用户保持原值不变
 		oldSetting := cleanToken.Setting.Data()
 		if userRole < config.RoleReliableUser {
-			// 非可信用户：保持原来的 BillingTag，忽略前端传入的值
+			// 非可信用户：保持原来的值，忽略前端传入的值
 			newSetting.BillingTag = oldSetting.BillingTag
+			newSetting.Privileged = oldSetting.Privileged
+			newSetting.MaxPriority = oldSetting.MaxPriority
 		}
 		// 可信用户：直接使用前端传入的值（包括空值，用于清除 BillingTag）
 
@@ -471,6 +477,43 @@ func UpdateTokenByAdmin(c *gin.Context) {
 	})
 }
 
+type decodeWatermarkRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// DecodeWatermark 从响应文本中解析水印，返回签发该响应的令牌及所属用户
+func DecodeWatermark(c *gin.Context) {
+	var request decodeWatermarkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	tokenId, ok := watermark.Decode(request.Text)
+	if !ok {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("未找到有效的水印"))
+		return
+	}
+
+	token, err := model.GetTokenByIdUnscoped(tokenId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"token_id":   token.Id,
+			"token_name": token.Name,
+			"user_id":    token.UserId,
+			"username":   model.GetUsernameById(token.UserId),
+			"deleted":    token.DeletedAt.Valid,
+		},
+	})
+}
+
 // validateTokenGroupForUser 验证用户组是否对指定用户有效
 func validateTokenGroupForUser(tokenGroup string, userId int) error {
 	userGroup, _ := model.CacheGetUserGroup(userId)
@@ -519,5 +562,11 @@ func validateTokenSetting(setting *model.TokenSetting) error {
 		}
 	}
 
+	if setting.CompletionWebhook != nil && setting.CompletionWebhook.URL != "" {
+		if err := webhook.ValidateURL(setting.CompletionWebhook.URL); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
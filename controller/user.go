@@ -9,6 +9,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/limit"
+	"one-api/common/logger"
 	"one-api/common/utils"
 	"one-api/model"
 	"strconv"
@@ -61,11 +62,11 @@ func Login(c *gin.Context) {
 		})
 		return
 	}
-	setupLogin(&user, c)
+	setupLogin(&user, c, model.LoginMethodPassword)
 }
 
-// setup session & cookies and then return user info
-func setupLogin(user *model.User, c *gin.Context) {
+// setupLogin 登录成功后保存会话，并记录登录方式、IP 和 User-Agent
+func setupLogin(user *model.User, c *gin.Context, method string) {
 	session := sessions.Default(c)
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
@@ -84,6 +85,10 @@ func setupLogin(user *model.User, c *gin.Context) {
 
 	user.Update(false)
 
+	if err := model.RecordLoginHistory(user.Id, user.LastLoginIp, c.Request.UserAgent(), method); err != nil {
+		logger.SysError(fmt.Sprintf("failed to record login history for user %d: %s", user.Id, err.Error()))
+	}
+
 	cleanUser := model.User{
 		Id:          user.Id,
 		AvatarUrl:   user.AvatarUrl,
@@ -238,6 +243,55 @@ func GetUser(c *gin.Context) {
 	})
 }
 
+// GetSelfLoginHistory 获取当前用户的登录记录
+func GetSelfLoginHistory(c *gin.Context) {
+	histories, err := model.GetUserLoginHistory(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    histories,
+	})
+}
+
+// GetUserLoginHistory 管理员获取指定用户的登录记录
+func GetUserLoginHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != config.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权获取同级或更高等级用户的信息",
+		})
+		return
+	}
+
+	histories, err := model.GetUserLoginHistory(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    histories,
+	})
+}
+
 const API_LIMIT_KEY = "api-limiter:%d"
 
 func GetRateRealtime(c *gin.Context) {
@@ -363,7 +363,7 @@ func WebauthnFinishLogin(c *gin.Context) {
 	sess.Save()
 
 	// 设置用户登录状态
-	setupLogin(user, c)
+	setupLogin(user, c, model.LoginMethodWebAuthn)
 }
 
 // 获取用户的WebAuthn凭据列表
@@ -109,7 +109,7 @@ func WeChatAuth(c *gin.Context) {
 		})
 		return
 	}
-	setupLogin(&user, c)
+	setupLogin(&user, c, model.LoginMethodWeChat)
 }
 
 func WeChatBind(c *gin.Context) {
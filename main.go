@@ -16,6 +16,7 @@ import (
 	"one-api/common/search"
 	"one-api/common/storage"
 	"one-api/common/telegram"
+	"one-api/common/watermark"
 	"one-api/common/webauthn"
 	"one-api/controller"
 	"one-api/cron"
@@ -53,6 +54,8 @@ func main() {
 	if err != nil {
 		logger.FatalLog("failed to initialize user token: " + err.Error())
 	}
+	// 响应水印使用令牌密钥签名
+	watermark.InitSecret(viper.GetString("user_token_secret"))
 
 	// Initialize SQL Database
 	model.SetupDB()
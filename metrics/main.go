@@ -14,6 +14,7 @@ var (
 	httpRequestDuration *prometheus.HistogramVec
 	providerCounter     *prometheus.CounterVec
 	panicCounter        *prometheus.CounterVec
+	channelQueueWait    *prometheus.HistogramVec
 )
 
 func init() {
@@ -43,6 +44,16 @@ func init() {
 		[]string{"channel_type", "channel_id", "model", "type"},
 	)
 
+	// 渠道 RPM 平滑排队等待时间
+	channelQueueWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "channel_rpm_queue_wait_seconds",
+			Help:    "Time requests wait in the channel RPM smoothing queue.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10},
+		},
+		[]string{"channel_id"},
+	)
+
 	// 3. 监控 panic
 	panicCounter = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -94,6 +105,13 @@ func RecordProvider(c *gin.Context, statusCode int) {
 	})
 }
 
+// 记录渠道 RPM 平滑排队等待时间
+func RecordChannelQueueWait(channelId int, wait time.Duration) {
+	go SafelyRecordMetric(func() {
+		channelQueueWait.WithLabelValues(strconv.Itoa(channelId)).Observe(wait.Seconds())
+	})
+}
+
 // 记录 panic
 func RecordPanic(panicType string) {
 	panicCounter.WithLabelValues(panicType).Inc()
@@ -6,6 +6,7 @@ import (
 	"one-api/common/config"
 	"one-api/common/utils"
 	"one-api/model"
+	"slices"
 	"strings"
 
 	"github.com/gin-contrib/sessions"
@@ -141,6 +142,10 @@ func tokenAuth(c *gin.Context, key string) {
 		abortWithMessage(c, http.StatusForbidden, err.Error())
 		return
 	}
+	if err := checkLimitEndpoint(c); err != nil {
+		abortWithMessage(c, http.StatusForbidden, err.Error())
+		return
+	}
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			if strings.HasPrefix(parts[1], "!") {
@@ -206,6 +211,22 @@ func checkLimitIP(c *gin.Context) (error error) {
 	return fmt.Errorf("IP %s is not allowed to access", ip)
 }
 
+// 检测令牌是否允许调用当前接口
+func checkLimitEndpoint(c *gin.Context) error {
+	setting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting")
+	if !ok || setting == nil || len(setting.AllowedEndpoints) == 0 {
+		return nil
+	}
+
+	// 未知接口同样拒绝，避免白名单被绕过
+	endpoint := model.GetRequestEndpoint(c.Request.URL.Path)
+	if endpoint != "" && slices.Contains(setting.AllowedEndpoints, endpoint) {
+		return nil
+	}
+
+	return fmt.Errorf("endpoint %s is not allowed for this token", c.Request.URL.Path)
+}
+
 func OpenaiAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		isWebSocket := c.GetHeader("Upgrade") == "websocket"
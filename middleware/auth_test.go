@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/logger"
+	"one-api/model"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func getLimitEndpointTestRouter(setting *model.TokenSetting) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("token_setting", setting)
+		if err := checkLimitEndpoint(c); err != nil {
+			abortWithMessage(c, http.StatusForbidden, err.Error())
+		}
+	})
+	handler := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+	router.POST("/v1/chat/completions", handler)
+	router.POST("/v1/embeddings", handler)
+	router.GET("/v1/models", handler)
+	router.POST("/mj/submit/imagine", handler)
+	return router
+}
+
+func sendLimitEndpointRequest(router *gin.Engine, method, path string) int {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code
+}
+
+func TestCheckLimitEndpoint(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	router := getLimitEndpointTestRouter(&model.TokenSetting{AllowedEndpoints: []string{"chat_completions"}})
+	assert.Equal(t, http.StatusOK, sendLimitEndpointRequest(router, http.MethodPost, "/v1/chat/completions"))
+	assert.Equal(t, http.StatusForbidden, sendLimitEndpointRequest(router, http.MethodGet, "/v1/models"))
+	assert.Equal(t, http.StatusForbidden, sendLimitEndpointRequest(router, http.MethodPost, "/v1/embeddings"))
+	assert.Equal(t, http.StatusForbidden, sendLimitEndpointRequest(router, http.MethodPost, "/mj/submit/imagine"))
+
+	// 未设置白名单时不限制
+	router = getLimitEndpointTestRouter(&model.TokenSetting{})
+	assert.Equal(t, http.StatusOK, sendLimitEndpointRequest(router, http.MethodGet, "/v1/models"))
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func getFingerprintTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		tokenId, _ := strconv.Atoi(c.GetHeader("X-Token-Id"))
+		c.Set("token_id", tokenId)
+	}, FingerprintRateLimit())
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func sendFingerprintRequest(router *gin.Engine, ip, userAgent string, tokenId int) int {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.RemoteAddr = ip + ":12345"
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Token-Id", strconv.Itoa(tokenId))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestFingerprintRateLimit(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	config.FingerprintRateLimitNum = 2
+	defer func() { config.FingerprintRateLimitNum = 0 }()
+
+	router := getFingerprintTestRouter()
+
+	// 相同指纹共享限额
+	assert.Equal(t, http.StatusOK, sendFingerprintRequest(router, "10.0.0.1", "bot/1.0", 1))
+	assert.Equal(t, http.StatusOK, sendFingerprintRequest(router, "10.0.0.1", "bot/1.0", 1))
+	assert.Equal(t, http.StatusTooManyRequests, sendFingerprintRequest(router, "10.0.0.1", "bot/1.0", 1))
+
+	// 任一组成部分不同即为独立的指纹
+	assert.Equal(t, http.StatusOK, sendFingerprintRequest(router, "10.0.0.2", "bot/1.0", 1))
+	assert.Equal(t, http.StatusOK, sendFingerprintRequest(router, "10.0.0.1", "bot/2.0", 1))
+	assert.Equal(t, http.StatusOK, sendFingerprintRequest(router, "10.0.0.1", "bot/1.0", 2))
+}
+
+func TestFingerprintRateLimitComponents(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	config.FingerprintRateLimitNum = 1
+	config.FingerprintRateLimitComponents = "user_agent"
+	defer func() {
+		config.FingerprintRateLimitNum = 0
+		config.FingerprintRateLimitComponents = "ip,user_agent,token"
+	}()
+
+	router := getFingerprintTestRouter()
+
+	// 只按 user-agent 计算指纹时，不同 IP 和令牌一起限流
+	assert.Equal(t, http.StatusOK, sendFingerprintRequest(router, "10.0.1.1", "crawler/1.0", 1))
+	assert.Equal(t, http.StatusTooManyRequests, sendFingerprintRequest(router, "10.0.1.2", "crawler/1.0", 2))
+	assert.Equal(t, http.StatusOK, sendFingerprintRequest(router, "10.0.1.2", "crawler/2.0", 2))
+}
@@ -36,9 +36,13 @@ var (
 )
 
 func redisRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark string) {
+	redisKeyRateLimiter(c, maxRequestNum, duration, mark+c.ClientIP())
+}
+
+func redisKeyRateLimiter(c *gin.Context, maxRequestNum int, duration int64, limitKey string) {
 	ctx := context.Background()
 	rdb := redis.RDB
-	key := "rateLimit:" + mark + c.ClientIP()
+	key := "rateLimit:" + limitKey
 	listLength, err := rdb.LLen(ctx, key).Result()
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
@@ -79,7 +83,10 @@ func redisRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark st
 }
 
 func memoryRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark string) {
-	key := mark + c.ClientIP()
+	memoryKeyRateLimiter(c, maxRequestNum, duration, mark+c.ClientIP())
+}
+
+func memoryKeyRateLimiter(c *gin.Context, maxRequestNum int, duration int64, key string) {
 	if !inMemoryRateLimiter.Request(key, maxRequestNum, duration) {
 		c.Status(http.StatusTooManyRequests)
 		c.Abort()
@@ -29,6 +29,23 @@ type ChannelsChooser struct {
 	ModelGroup map[string]map[string]bool
 }
 
+// ErrModelNotFound 分组中没有为该模型配置任何渠道
+var ErrModelNotFound = errors.New("model not found")
+
+// NoAvailableChannelError 模型已配置渠道，但渠道均已被禁用或处于冷却中
+type NoAvailableChannelError struct {
+	Model      string
+	RetryAfter int64 // 距最早结束的冷却还有多少秒，0 表示无法预估
+}
+
+func (e *NoAvailableChannelError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("no available upstream for model %s, retry after %d seconds", e.Model, e.RetryAfter)
+	}
+
+	return fmt.Sprintf("no available upstream for model %s", e.Model)
+}
+
 type ChannelsFilterFunc func(channelId int, choice *ChannelChoice) bool
 
 func FilterChannelId(skipChannelIds []int) ChannelsFilterFunc {
@@ -55,6 +72,18 @@ func FilterDisabledStream(modelName string) ChannelsFilterFunc {
 	}
 }
 
+func FilterDisabledEndpoint(endpoint string) ChannelsFilterFunc {
+	return func(_ int, choice *ChannelChoice) bool {
+		return !choice.Channel.AllowEndpoint(endpoint)
+	}
+}
+
+func FilterChannelLabels(labels []string) ChannelsFilterFunc {
+	return func(_ int, choice *ChannelChoice) bool {
+		return !choice.Channel.HasLabels(labels)
+	}
+}
+
 func init() {
 	// 每小时清理一次过期的冷却时间
 	go func() {
@@ -83,14 +112,19 @@ func (cc *ChannelsChooser) SetCooldowns(channelId int, modelName string) bool {
 }
 
 func (cc *ChannelsChooser) IsInCooldown(channelId int, modelName string) bool {
+	return cc.getCooldownRemaining(channelId, modelName) > 0
+}
+
+// getCooldownRemaining 获取渠道冷却剩余的秒数，未冷却时返回 0
+func (cc *ChannelsChooser) getCooldownRemaining(channelId int, modelName string) int64 {
 	key := fmt.Sprintf("%d:%s", channelId, modelName)
 
 	cooldownTime, exists := cc.Cooldowns.Load(key)
 	if !exists {
-		return false
+		return 0
 	}
 
-	return time.Now().Unix() < cooldownTime.(int64)
+	return max(cooldownTime.(int64)-time.Now().Unix(), 0)
 }
 
 func (cc *ChannelsChooser) CleanupExpiredCooldowns() {
@@ -193,7 +227,7 @@ func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilt
 		matchModel := utils.GetModelsWithMatch(&cc.Match, modelName)
 		channelsPriority, ok = cc.Rule[group][matchModel]
 		if !ok {
-			return nil, errors.New("model not found")
+			return nil, ErrModelNotFound
 		}
 	}
 
@@ -208,9 +242,42 @@ func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilt
 		}
 	}
 
+	if err := cc.unavailableError(channelsPriority, modelName); err != nil {
+		return nil, err
+	}
+
 	return nil, errors.New("channel not found")
 }
 
+// unavailableError 存在被禁用或冷却中的渠道时返回 NoAvailableChannelError，重试时间取最早结束的冷却
+func (cc *ChannelsChooser) unavailableError(channelsPriority [][]int, modelName string) error {
+	unavailable := false
+	retryAfter := int64(0)
+	for _, priority := range channelsPriority {
+		for _, channelId := range priority {
+			choice, ok := cc.Channels[channelId]
+			if !ok {
+				continue
+			}
+
+			if remaining := cc.getCooldownRemaining(channelId, modelName); remaining > 0 {
+				unavailable = true
+				if retryAfter == 0 || remaining < retryAfter {
+					retryAfter = remaining
+				}
+			} else if choice.Disable {
+				unavailable = true
+			}
+		}
+	}
+
+	if !unavailable {
+		return nil
+	}
+
+	return &NoAvailableChannelError{Model: modelName, RetryAfter: retryAfter}
+}
+
 func (cc *ChannelsChooser) GetGroupModels(group string) ([]string, error) {
 	cc.RLock()
 	defer cc.RUnlock()
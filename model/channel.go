@@ -3,6 +3,8 @@ package model
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
+	"math/rand"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/utils"
@@ -34,15 +36,27 @@ type Channel struct {
 	ModelMapping       *string `json:"model_mapping" gorm:"type:text"`
 	ModelHeaders       *string `json:"model_headers" gorm:"type:varchar(1024);default:''"`
 	CustomParameter    *string `json:"custom_parameter" gorm:"type:varchar(1024);default:''"`
+	ContextWindows     *string `json:"context_windows" gorm:"type:varchar(1024);default:''"` // 各模型的上下文长度，JSON 格式，覆盖模型默认值
 	Priority           *int64  `json:"priority" gorm:"bigint;default:0"`
 	Proxy              *string `json:"proxy" gorm:"type:varchar(255);default:''"`
 	TestModel          string  `json:"test_model" form:"test_model" gorm:"type:varchar(50);default:''"`
+	TestPrompt         string  `json:"test_prompt" form:"test_prompt" gorm:"type:text"`
 	OnlyChat           bool    `json:"only_chat" form:"only_chat" gorm:"default:false"`
 	PreCost            int     `json:"pre_cost" form:"pre_cost" gorm:"default:1"`
 	CompatibleResponse bool    `json:"compatible_response" gorm:"default:false"`
 	AllowExtraBody     bool    `json:"allow_extra_body" form:"allow_extra_body" gorm:"default:false"`
+	RPM                int     `json:"rpm" form:"rpm" gorm:"default:0"`                                  // 每分钟最大请求数，超出时排队平滑发送，0 为不限制
+	BufferToolCalls    bool    `json:"buffer_tool_calls" form:"buffer_tool_calls" gorm:"default:false"`  // 流式响应中缓冲工具调用参数，完整后一次性输出
+	UserAgent          string  `json:"user_agent" form:"user_agent" gorm:"type:varchar(255);default:''"` // 请求上游时的 User-Agent，为空时使用 one-hub 标识
+	ClientId           string  `json:"client_id" form:"client_id" gorm:"type:varchar(255);default:''"`   // 可选，以 X-Client-Id 请求头发送给上游
+	LogSampleRate      float64 `json:"log_sample_rate" form:"log_sample_rate" gorm:"default:0"`          // 详细日志的采样率 0~1，0 为全部记录，计费记录不受影响
+	MaxImages          int     `json:"max_images" form:"max_images" gorm:"default:0"`                    // 单次请求的图片数量上限，0 为使用全局配置
+	SamplingPrecedence string  `json:"sampling_precedence" gorm:"type:varchar(32);default:''"`           // 同时设置 temperature 与 top_p 时保留的参数，为空时使用全局配置
 
 	DisabledStream *datatypes.JSONSlice[string] `json:"disabled_stream,omitempty" gorm:"type:json"`
+	Labels         *datatypes.JSONSlice[string] `json:"labels,omitempty" gorm:"type:json"` // 自由标签，如 region:us、tier:prod，用于路由筛选和统计
+	// 禁用的接口，如 chat_completions、messages、batches，请求这些接口时跳过该渠道
+	DisabledEndpoints *datatypes.JSONSlice[string] `json:"disabled_endpoints,omitempty" gorm:"type:json"`
 
 	Plugin    *datatypes.JSONType[PluginType] `json:"plugin" form:"plugin" gorm:"type:json"`
 	DeletedAt gorm.DeletedAt                  `json:"-" gorm:"index"`
@@ -56,6 +70,55 @@ func (c *Channel) AllowStream(modelName string) bool {
 	return !slices.Contains(*c.DisabledStream, modelName)
 }
 
+// AllowEndpoint 判断渠道是否开放指定接口
+func (c *Channel) AllowEndpoint(endpoint string) bool {
+	if c.DisabledEndpoints == nil || endpoint == "" {
+		return true
+	}
+
+	return !slices.Contains(*c.DisabledEndpoints, endpoint)
+}
+
+// SampleVerboseLog 按渠道的采样率决定本次请求是否记录详细日志
+func (c *Channel) SampleVerboseLog() bool {
+	if c.LogSampleRate <= 0 || c.LogSampleRate >= 1 {
+		return true
+	}
+
+	return rand.Float64() < c.LogSampleRate
+}
+
+// ValidateHeaders 校验渠道配置的请求头的值，避免保存无法发送的请求头
+func (c *Channel) ValidateHeaders() error {
+	headers := map[string]string{
+		"user_agent": c.UserAgent,
+		"client_id":  c.ClientId,
+	}
+	for name, value := range headers {
+		if len(value) > 255 {
+			return fmt.Errorf("%s 长度不能超过 255", name)
+		}
+		for _, char := range value {
+			if char < ' ' || char > '~' {
+				return fmt.Errorf("%s 只能包含可见的 ASCII 字符", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// HasLabels 判断渠道是否包含全部指定标签
+func (c *Channel) HasLabels(labels []string) bool {
+	for _, label := range labels {
+		if c.Labels == nil || !slices.Contains(*c.Labels, label) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type PluginType map[string]map[string]interface{}
 
 var allowedChannelOrderFields = map[string]bool{
@@ -262,6 +325,20 @@ func (channel *Channel) GetModelMapping() string {
 	return *channel.ModelMapping
 }
 
+// GetContextWindow 获取渠道为模型配置的上下文长度，未配置时返回 0，使用模型默认值
+func (channel *Channel) GetContextWindow(modelName string) int {
+	if channel.ContextWindows == nil || *channel.ContextWindows == "" {
+		return 0
+	}
+
+	contextWindows, err := utils.UnmarshalString[map[string]int](*channel.ContextWindows)
+	if err != nil {
+		return 0
+	}
+
+	return contextWindows[modelName]
+}
+
 func (channel *Channel) GetCustomParameter() string {
 	if channel.CustomParameter == nil {
 		return ""
@@ -152,12 +152,23 @@ func UpdateChannelsTag(tag string, channel *Channel) error {
 			ModelMapping:       channel.ModelMapping,
 			ModelHeaders:       channel.ModelHeaders,
 			CustomParameter:    channel.CustomParameter,
+			ContextWindows:     channel.ContextWindows,
 			Proxy:              channel.Proxy,
 			TestModel:          channel.TestModel,
+			TestPrompt:         channel.TestPrompt,
 			OnlyChat:           channel.OnlyChat,
 			Plugin:             channel.Plugin,
 			PreCost:            channel.PreCost,
 			DisabledStream:     channel.DisabledStream,
+			Labels:             channel.Labels,
+			DisabledEndpoints:  channel.DisabledEndpoints,
+			RPM:                channel.RPM,
+			BufferToolCalls:    channel.BufferToolCalls,
+			UserAgent:          channel.UserAgent,
+			ClientId:           channel.ClientId,
+			LogSampleRate:      channel.LogSampleRate,
+			MaxImages:          channel.MaxImages,
+			SamplingPrecedence: channel.SamplingPrecedence,
 			CompatibleResponse: channel.CompatibleResponse,
 		}).Error
 
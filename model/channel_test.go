@@ -0,0 +1,153 @@
+package model
+
+import (
+	"one-api/common/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func newLabeledChannel(id int, labels ...string) *Channel {
+	weight := uint(1)
+	channelLabels := datatypes.JSONSlice[string](labels)
+	return &Channel{Id: id, Weight: &weight, Status: config.ChannelStatusEnabled, Labels: &channelLabels}
+}
+
+func TestChannelsChooserFilterLabels(t *testing.T) {
+	chooser := &ChannelsChooser{
+		Channels: map[int]*ChannelChoice{
+			1: {Channel: newLabeledChannel(1, "region:us", "tier:prod")},
+			2: {Channel: newLabeledChannel(2, "region:eu", "tier:prod")},
+			3: {Channel: &Channel{Id: 3, Weight: new(uint)}},
+		},
+		Rule: map[string]map[string][][]int{
+			"default": {"claude-3-5-sonnet": {{1, 2, 3}}},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		channel, err := chooser.Next("default", "claude-3-5-sonnet", FilterChannelLabels([]string{"region:eu"}))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, channel.Id)
+	}
+
+	channel, err := chooser.Next("default", "claude-3-5-sonnet", FilterChannelLabels([]string{"region:us", "tier:prod"}))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, channel.Id)
+
+	_, err = chooser.Next("default", "claude-3-5-sonnet", FilterChannelLabels([]string{"region:ap"}))
+	assert.NotNil(t, err)
+}
+
+func TestAggregateChannelLabelStatistics(t *testing.T) {
+	channels := []*Channel{
+		newLabeledChannel(1, "region:us", "tier:prod"),
+		newLabeledChannel(2, "region:eu", "tier:prod"),
+		{Id: 3},
+	}
+	usages := []*channelUsageStatistic{
+		{ChannelId: 1, RequestCount: 2, Quota: 100, PromptTokens: 10, CompletionTokens: 20},
+		{ChannelId: 2, RequestCount: 3, Quota: 50, PromptTokens: 5, CompletionTokens: 5},
+		{ChannelId: 3, RequestCount: 7, Quota: 70},
+	}
+
+	statistics := aggregateChannelLabelStatistics(usages, channels)
+
+	assert.Len(t, statistics, 3)
+	assert.Equal(t, "region:eu", statistics[0].Label)
+	assert.Equal(t, int64(50), statistics[0].Quota)
+	assert.Equal(t, "region:us", statistics[1].Label)
+	assert.Equal(t, int64(100), statistics[1].Quota)
+	assert.Equal(t, "tier:prod", statistics[2].Label)
+	assert.Equal(t, 2, statistics[2].ChannelCount)
+	assert.Equal(t, int64(5), statistics[2].RequestCount)
+	assert.Equal(t, int64(150), statistics[2].Quota)
+	assert.Equal(t, int64(25), statistics[2].CompletionTokens)
+}
+
+func TestChannelsChooserFilterDisabledEndpoint(t *testing.T) {
+	disabled := datatypes.JSONSlice[string]{"messages"}
+	channel := newLabeledChannel(1)
+	channel.DisabledEndpoints = &disabled
+	chooser := &ChannelsChooser{
+		Channels: map[int]*ChannelChoice{
+			1: {Channel: channel},
+			2: {Channel: newLabeledChannel(2)},
+		},
+		Rule: map[string]map[string][][]int{
+			"default": {"claude-3-5-sonnet": {{1, 2}}},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		channel, err := chooser.Next("default", "claude-3-5-sonnet", FilterDisabledEndpoint("messages"))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, channel.Id)
+	}
+	assert.True(t, chooser.Channels[1].Channel.AllowEndpoint("chat_completions"))
+}
+
+func TestChannelValidateHeaders(t *testing.T) {
+	assert.Nil(t, (&Channel{UserAgent: "partner-gateway/1.0 (one-hub)", ClientId: "team-a"}).ValidateHeaders())
+	assert.NotNil(t, (&Channel{UserAgent: "agent\r\nX-Injected: 1"}).ValidateHeaders())
+	assert.NotNil(t, (&Channel{ClientId: "客户端"}).ValidateHeaders())
+}
+
+func TestChannelSampleVerboseLog(t *testing.T) {
+	// 未配置或配置为 1 时全部记录
+	assert.True(t, (&Channel{}).SampleVerboseLog())
+	assert.True(t, (&Channel{LogSampleRate: 1}).SampleVerboseLog())
+
+	channel := &Channel{LogSampleRate: 0.1}
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		if channel.SampleVerboseLog() {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 1000, sampled, 150)
+}
+
+func TestChannelsChooserUnavailableError(t *testing.T) {
+	chooser := &ChannelsChooser{
+		Channels: map[int]*ChannelChoice{
+			1: {Channel: newLabeledChannel(1)},
+			2: {Channel: newLabeledChannel(2), Disable: true},
+		},
+		Rule: map[string]map[string][][]int{
+			"default": {"claude-3-5-sonnet": {{1}, {2}}},
+		},
+	}
+
+	// 未配置的模型
+	_, err := chooser.Next("default", "gpt-4o")
+	assert.ErrorIs(t, err, ErrModelNotFound)
+
+	// 渠道均被禁用或冷却中，重试时间取最早结束的冷却
+	chooser.Cooldowns.Store("1:claude-3-5-sonnet", time.Now().Unix()+30)
+	_, err = chooser.Next("default", "claude-3-5-sonnet")
+	var unavailableErr *NoAvailableChannelError
+	assert.ErrorAs(t, err, &unavailableErr)
+	assert.Equal(t, "claude-3-5-sonnet", unavailableErr.Model)
+	assert.InDelta(t, 30, unavailableErr.RetryAfter, 1)
+
+	// 冷却结束后恢复
+	chooser.Cooldowns.Delete("1:claude-3-5-sonnet")
+	channel, err := chooser.Next("default", "claude-3-5-sonnet")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, channel.Id)
+}
+
+func TestChannelGetContextWindow(t *testing.T) {
+	assert.Zero(t, (&Channel{}).GetContextWindow("claude-sonnet-4-20250514"))
+
+	contextWindows := `{"claude-sonnet-4-20250514":100000}`
+	channel := &Channel{ContextWindows: &contextWindows}
+	assert.Equal(t, 100000, channel.GetContextWindow("claude-sonnet-4-20250514"))
+	assert.Zero(t, channel.GetContextWindow("claude-3-5-haiku-20241022"))
+
+	invalid := `not json`
+	assert.Zero(t, (&Channel{ContextWindows: &invalid}).GetContextWindow("claude-sonnet-4-20250514"))
+}
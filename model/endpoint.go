@@ -0,0 +1,40 @@
+package model
+
+import "strings"
+
+// 请求路径前缀与接口名称的对应关系，按顺序匹配，用于渠道禁用接口及令牌接口白名单
+var requestEndpoints = []struct {
+	prefix   string
+	endpoint string
+}{
+	{"/v1/chat/completions", "chat_completions"},
+	{"/v1/completions", "completions"},
+	{"/v1/responses", "responses"},
+	{"/v1/embeddings", "embeddings"},
+	{"/v1/images/", "images"},
+	{"/v1/audio/", "audio"},
+	{"/v1/moderations", "moderations"},
+	{"/v1/rerank", "rerank"},
+	{"/v1/realtime", "realtime"},
+	{"/v1/files", "files"},
+	{"/v1/fine_tuning/", "fine_tuning"},
+	{"/v1/assistants", "assistants"},
+	{"/v1/threads", "threads"},
+	{"/v1/batches", "batches"},
+	{"/v1/vector_stores", "vector_stores"},
+	{"/v1/models", "models"},
+	{"/claude/v1/messages", "messages"},
+	{"/claude/v1/models", "models"},
+	{"/gemini/", "gemini"},
+}
+
+// GetRequestEndpoint 根据请求路径获取接口名称，未知路径返回空字符串
+func GetRequestEndpoint(path string) string {
+	for _, item := range requestEndpoints {
+		if strings.HasPrefix(path, item.prefix) {
+			return item.endpoint
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"one-api/common/config"
+	"one-api/common/utils"
+)
+
+const (
+	LoginMethodPassword = "password"
+	LoginMethodLinuxDo  = "linuxdo"
+	LoginMethodGitHub   = "github"
+	LoginMethodWeChat   = "wechat"
+	LoginMethodLark     = "lark"
+	LoginMethodOIDC     = "oidc"
+	LoginMethodWebAuthn = "webauthn"
+)
+
+// LoginHistory 用户成功登录的记录，每个用户只保留最近 LoginHistoryMaxRecords 条
+type LoginHistory struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	Ip        string `json:"ip" gorm:"type:varchar(128);default:''"`
+	UserAgent string `json:"user_agent" gorm:"type:varchar(512);default:''"`
+	Method    string `json:"method" gorm:"type:varchar(32);default:''"`
+}
+
+// RecordLoginHistory 记录一次登录，并删除超出上限的旧记录
+func RecordLoginHistory(userId int, ip, userAgent, method string) error {
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	history := &LoginHistory{
+		UserId:    userId,
+		CreatedAt: utils.GetTimestamp(),
+		Ip:        ip,
+		UserAgent: userAgent,
+		Method:    method,
+	}
+	if err := DB.Create(history).Error; err != nil {
+		return err
+	}
+
+	maxRecords := config.LoginHistoryMaxRecords
+	if maxRecords <= 0 {
+		return nil
+	}
+
+	var keepIds []int
+	err := DB.Model(&LoginHistory{}).Where("user_id = ?", userId).Order("id desc").Limit(maxRecords).Pluck("id", &keepIds).Error
+	if err != nil {
+		return err
+	}
+
+	return DB.Where("user_id = ? AND id NOT IN ?", userId, keepIds).Delete(&LoginHistory{}).Error
+}
+
+// GetUserLoginHistory 获取用户的登录记录，最近的在前
+func GetUserLoginHistory(userId int) ([]*LoginHistory, error) {
+	var histories []*LoginHistory
+	err := DB.Where("user_id = ?", userId).Order("id desc").Find(&histories).Error
+	return histories, err
+}
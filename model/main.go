@@ -194,6 +194,11 @@ func InitDB() (err error) {
 			return err
 		}
 
+		err = db.AutoMigrate(&LoginHistory{})
+		if err != nil {
+			return err
+		}
+
 		if config.UserInvoiceMonth {
 			err = db.AutoMigrate(&StatisticsMonthGeneratedHistory{})
 			if err != nil {
@@ -206,7 +211,10 @@ func InitDB() (err error) {
 			}
 		}
 
-		migrationAfter(DB)
+		err = migrationAfter(DB)
+		if err != nil {
+			return err
+		}
 
 		logger.SysLog("database migrated")
 		err = createRootAccountIfNeed()
@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"strconv"
@@ -449,6 +450,12 @@ func migrationAfter(db *gorm.DB) error {
 		logger.SysLog("从库不执行迁移后操作")
 		return nil
 	}
+
+	// 没有唯一索引时无法防止并发重复绑定，不能静默忽略
+	if err := CreateLinuxDoIdUniqueIndex(db); err != nil {
+		return fmt.Errorf("创建 linuxdo_id 唯一索引失败，请先处理重复绑定的用户: %w", err)
+	}
+
 	m := gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
 		addStatistics(),
 		changeChannelApiVersion(),
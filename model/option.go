@@ -4,6 +4,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/utils"
 	"strings"
 	"time"
 )
@@ -68,6 +69,11 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterString("GitHubClientSecret", &config.GitHubClientSecret)
 	config.GlobalOption.RegisterString("LinuxDoClientId", &config.LinuxDoClientId)
 	config.GlobalOption.RegisterString("LinuxDoClientSecret", &config.LinuxDoClientSecret)
+	config.GlobalOption.RegisterString("LinuxDoDefaultGroup", &config.LinuxDoDefaultGroup)
+	config.GlobalOption.RegisterInt("LinuxDoMinTrustLevel", &config.LinuxDoMinTrustLevel)
+	config.GlobalOption.RegisterString("LinuxDoTrustLevelFallback", &config.LinuxDoTrustLevelFallback)
+	config.GlobalOption.RegisterString("LinuxDoAffLogMode", &config.LinuxDoAffLogMode)
+	config.GlobalOption.RegisterString("LinuxDoAffHashSecret", &config.LinuxDoAffHashSecret)
 
 	config.GlobalOption.RegisterString("OIDCClientId", &config.OIDCClientId)
 	config.GlobalOption.RegisterString("OIDCClientSecret", &config.OIDCClientSecret)
@@ -122,6 +128,27 @@ func InitOptionMap() {
 	}, common.GetDefaultDisableChannelKeywords())
 
 	config.GlobalOption.RegisterInt("RetryTimeOut", &config.RetryTimeOut)
+	config.GlobalOption.RegisterInt("StreamResetRetryTimes", &config.StreamResetRetryTimes)
+	config.GlobalOption.RegisterInt("RetryBudgetAttempts", &config.RetryBudgetAttempts)
+	config.GlobalOption.RegisterInt("RetryBudgetSeconds", &config.RetryBudgetSeconds)
+	config.GlobalOption.RegisterString("DisconnectBilling", &config.DisconnectBilling)
+	config.GlobalOption.RegisterInt("RequestMetadataMaxKeys", &config.RequestMetadataMaxKeys)
+	config.GlobalOption.RegisterInt("RequestMetadataMaxBytes", &config.RequestMetadataMaxBytes)
+	config.GlobalOption.RegisterInt("ChannelRPMMaxWaitSeconds", &config.ChannelRPMMaxWaitSeconds)
+	config.GlobalOption.RegisterInt("RequestPriorityAgingSeconds", &config.RequestPriorityAgingSeconds)
+	config.GlobalOption.RegisterInt("BillingMinCompletionTokens", &config.BillingMinCompletionTokens)
+	config.GlobalOption.RegisterInt("BillingCompletionTokenBlock", &config.BillingCompletionTokenBlock)
+	config.GlobalOption.RegisterBool("UpstreamRequestIdLogEnabled", &config.UpstreamRequestIdLogEnabled)
+	config.GlobalOption.RegisterBool("UpstreamRequestIdHeaderEnabled", &config.UpstreamRequestIdHeaderEnabled)
+	config.GlobalOption.RegisterInt("LoginHistoryMaxRecords", &config.LoginHistoryMaxRecords)
+	config.GlobalOption.RegisterInt("StreamConcurrencyPerUser", &config.StreamConcurrencyPerUser)
+	config.GlobalOption.RegisterInt("StreamConcurrencyPerToken", &config.StreamConcurrencyPerToken)
+	config.GlobalOption.RegisterString("GroupStreamDisabledPolicy", &config.GroupStreamDisabledPolicy)
+	config.GlobalOption.RegisterBool("ResponseWatermarkEnabled", &config.ResponseWatermarkEnabled)
+	config.GlobalOption.RegisterInt("FingerprintRateLimitNum", &config.FingerprintRateLimitNum)
+	config.GlobalOption.RegisterInt("FingerprintRateLimitDuration", &config.FingerprintRateLimitDuration)
+	config.GlobalOption.RegisterString("FingerprintRateLimitComponents", &config.FingerprintRateLimitComponents)
+	config.GlobalOption.RegisterBool("WebhookAllowPrivateNetwork", &config.WebhookAllowPrivateNetwork)
 
 	config.GlobalOption.RegisterBool("EnableSafe", &config.EnableSafe)
 	config.GlobalOption.RegisterString("SafeToolName", &config.SafeToolName)
@@ -133,6 +160,27 @@ func InitOptionMap() {
 	}, "")
 
 	loadOptionsFromDatabase()
+	initLinuxDoAffHashSecret()
+}
+
+// initLinuxDoAffHashSecret 密钥未保存时生成一个并写入数据库，保证重启后哈希不变
+func initLinuxDoAffHashSecret() {
+	if config.LinuxDoAffHashSecret != "" {
+		return
+	}
+
+	// 多个节点同时启动时只有一个能写入，其余读取已保存的值
+	option := Option{Key: "LinuxDoAffHashSecret"}
+	err := DB.Where(Option{Key: option.Key}).Attrs(Option{Value: utils.GetUUID()}).FirstOrCreate(&option).Error
+	if err != nil {
+		option, err = GetOption(option.Key)
+	}
+	if err != nil {
+		logger.SysError("failed to init LinuxDoAffHashSecret: " + err.Error())
+		return
+	}
+
+	config.LinuxDoAffHashSecret = option.Value
 }
 
 func loadOptionsFromDatabase() {
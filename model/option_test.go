@@ -0,0 +1,28 @@
+package model
+
+import (
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitLinuxDoAffHashSecret(t *testing.T) {
+	setupTestDB(t)
+	assert.Nil(t, DB.AutoMigrate(&Option{}))
+	defer func() { config.LinuxDoAffHashSecret = "" }()
+
+	config.LinuxDoAffHashSecret = ""
+	initLinuxDoAffHashSecret()
+	secret := config.LinuxDoAffHashSecret
+	assert.NotEmpty(t, secret)
+
+	// 模拟重启：内存中的值丢失后从数据库读取同一个密钥
+	config.LinuxDoAffHashSecret = ""
+	initLinuxDoAffHashSecret()
+	assert.Equal(t, secret, config.LinuxDoAffHashSecret)
+
+	option, err := GetOption("LinuxDoAffHashSecret")
+	assert.Nil(t, err)
+	assert.Equal(t, secret, option.Value)
+}
@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/spf13/viper"
 )
 
@@ -544,6 +545,83 @@ func GetPricesList(pricingType string) []*Price {
 	return prices
 }
 
+// EffectivePrice 应用分组倍率后的模型实际价格
+type EffectivePrice struct {
+	Model       string             `json:"model"`
+	Type        string             `json:"type"`
+	ChannelType int                `json:"channel_type"`
+	GroupRatio  float64            `json:"group_ratio"`
+	Input       float64            `json:"input"`               // 输入倍率（已乘分组倍率）
+	Output      float64            `json:"output"`              // 输出倍率（已乘分组倍率）
+	InputUSD    float64            `json:"input_usd"`           // tokens 类型为每百万 token 美元价格，times 类型为每次请求美元价格
+	OutputUSD   float64            `json:"output_usd"`          // 每百万 token 美元价格
+	ExtraUSD    map[string]float64 `json:"extra_usd,omitempty"` // 缓存等额外计费项的每百万 token 美元价格
+}
+
+var effectiveExtraPriceKeys = []string{
+	config.UsageExtraCache,
+	config.UsageExtraCachedWrite,
+	config.UsageExtraCachedRead,
+}
+
+// GetEffectivePricesList 获取指定分组倍率下所有模型的实际价格
+func GetEffectivePricesList(groupRatio float64) []*EffectivePrice {
+	prices := GetPricesList("db")
+	effectivePrices := make([]*EffectivePrice, 0, len(prices))
+	for _, price := range prices {
+		effectivePrices = append(effectivePrices, price.GetEffectivePrice(groupRatio))
+	}
+
+	return effectivePrices
+}
+
+func (price *Price) GetEffectivePrice(groupRatio float64) *EffectivePrice {
+	input := price.GetInput() * groupRatio
+	output := price.GetOutput() * groupRatio
+
+	effectivePrice := &EffectivePrice{
+		Model:       price.Model,
+		Type:        price.Type,
+		ChannelType: price.ChannelType,
+		GroupRatio:  groupRatio,
+		Input:       input,
+		Output:      output,
+	}
+
+	if price.Type == TimesPriceType {
+		effectivePrice.InputUSD = ratioToUSD(input * 1000)
+		return effectivePrice
+	}
+
+	effectivePrice.InputUSD = ratioToUSD(input * 1000000)
+	effectivePrice.OutputUSD = ratioToUSD(output * 1000000)
+
+	extraKeys := append([]string{}, effectiveExtraPriceKeys...)
+	if price.ExtraRatios != nil {
+		for key := range price.ExtraRatios.Data() {
+			if !utils.Contains(key, extraKeys) {
+				extraKeys = append(extraKeys, key)
+			}
+		}
+	}
+
+	effectivePrice.ExtraUSD = make(map[string]float64, len(extraKeys))
+	for _, key := range extraKeys {
+		base := output
+		if GetExtraPriceIsPrompt(key) {
+			base = input
+		}
+		effectivePrice.ExtraUSD[key] = ratioToUSD(base * price.GetExtraRatio(key) * 1000000)
+	}
+
+	return effectivePrice
+}
+
+// ratioToUSD 将额度换算为美元，保留6位小数
+func ratioToUSD(quota float64) float64 {
+	return decimal.NewFromFloat(quota).Div(decimal.NewFromFloat(config.QuotaPerUnit)).Round(6).InexactFloat64()
+}
+
 func GetOldPricesList() []*Price {
 	oldDataJson, err := GetOption("ModelRatio")
 	if err != nil || oldDataJson.Value == "" {
@@ -0,0 +1,250 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"slices"
+	"sort"
+)
+
+const PricingBundleVersion = 1
+
+// 随价格一起导出的模型相关配置项
+var pricingBundleOptions = []string{
+	"ClaudeDefaultMaxTokens",
+	"ModelDowngrade",
+}
+
+// PricingBundle 模型列表、价格（含缓存等额外倍率）、模型信息及模型级默认配置的导出包，用于在实例间迁移
+type PricingBundle struct {
+	Version    int               `json:"version"`
+	Prices     []*Price          `json:"prices"`
+	ModelInfos []*ModelInfo      `json:"model_infos"`
+	Options    map[string]string `json:"options"`
+}
+
+// PricingBundleDiff 导入时相对当前配置的变化，导入不会删除已有配置
+type PricingBundleDiff struct {
+	AddedPrices       []string `json:"added_prices"`
+	UpdatedPrices     []string `json:"updated_prices"`
+	AddedModelInfos   []string `json:"added_model_infos"`
+	UpdatedModelInfos []string `json:"updated_model_infos"`
+	UpdatedOptions    []string `json:"updated_options"`
+}
+
+// ExportPricingBundle 导出当前的价格配置
+func ExportPricingBundle() (*PricingBundle, error) {
+	prices, err := GetAllPrices()
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range prices {
+		price.ModelInfo = nil
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Model < prices[j].Model })
+
+	modelInfos, err := GetAllModelInfo()
+	if err != nil {
+		return nil, err
+	}
+	for _, modelInfo := range modelInfos {
+		modelInfo.Id, modelInfo.CreatedAt, modelInfo.UpdatedAt = 0, 0, 0
+	}
+	sort.Slice(modelInfos, func(i, j int) bool { return modelInfos[i].Model < modelInfos[j].Model })
+
+	options := make(map[string]string, len(pricingBundleOptions))
+	for _, key := range pricingBundleOptions {
+		options[key] = config.GlobalOption.Get(key)
+	}
+
+	return &PricingBundle{
+		Version:    PricingBundleVersion,
+		Prices:     prices,
+		ModelInfos: modelInfos,
+		Options:    options,
+	}, nil
+}
+
+// Validate 校验导入包，任何一项不合法都拒绝整个导入
+func (b *PricingBundle) Validate() error {
+	if b.Version != PricingBundleVersion {
+		return fmt.Errorf("unsupported bundle version: %d", b.Version)
+	}
+
+	models := make(map[string]bool, len(b.Prices))
+	for _, price := range b.Prices {
+		if price == nil || price.Model == "" {
+			return errors.New("price model is required")
+		}
+		if models[price.Model] {
+			return fmt.Errorf("duplicate price for model %s", price.Model)
+		}
+		models[price.Model] = true
+
+		if price.Type != TokensPriceType && price.Type != TimesPriceType {
+			return fmt.Errorf("invalid price type %q for model %s", price.Type, price.Model)
+		}
+		if price.Input < 0 || price.Output < 0 || price.ChannelType < 0 {
+			return fmt.Errorf("invalid price for model %s", price.Model)
+		}
+		if price.ExtraRatios != nil {
+			for key, ratio := range price.ExtraRatios.Data() {
+				if ratio < 0 {
+					return fmt.Errorf("invalid extra ratio %s for model %s", key, price.Model)
+				}
+			}
+		}
+	}
+
+	modelInfos := make(map[string]bool, len(b.ModelInfos))
+	for _, modelInfo := range b.ModelInfos {
+		if modelInfo == nil || modelInfo.Model == "" {
+			return errors.New("model info model is required")
+		}
+		if modelInfos[modelInfo.Model] {
+			return fmt.Errorf("duplicate model info for model %s", modelInfo.Model)
+		}
+		modelInfos[modelInfo.Model] = true
+	}
+
+	for key, value := range b.Options {
+		if !slices.Contains(pricingBundleOptions, key) {
+			return fmt.Errorf("option %s is not allowed in a pricing bundle", key)
+		}
+		if value == "" {
+			continue
+		}
+		var data map[string]any
+		if err := json.Unmarshal([]byte(value), &data); err != nil {
+			return fmt.Errorf("invalid option %s: %s", key, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// DiffPricingBundle 计算导入包相对当前配置的变化
+func DiffPricingBundle(b *PricingBundle) (*PricingBundleDiff, error) {
+	current, err := ExportPricingBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &PricingBundleDiff{
+		AddedPrices:       []string{},
+		UpdatedPrices:     []string{},
+		AddedModelInfos:   []string{},
+		UpdatedModelInfos: []string{},
+		UpdatedOptions:    []string{},
+	}
+
+	currentPrices := make(map[string]*Price, len(current.Prices))
+	for _, price := range current.Prices {
+		currentPrices[price.Model] = price
+	}
+	for _, price := range b.Prices {
+		if old, ok := currentPrices[price.Model]; !ok {
+			diff.AddedPrices = append(diff.AddedPrices, price.Model)
+		} else if !jsonEqual(old, price) {
+			diff.UpdatedPrices = append(diff.UpdatedPrices, price.Model)
+		}
+	}
+
+	currentModelInfos := make(map[string]*ModelInfo, len(current.ModelInfos))
+	for _, modelInfo := range current.ModelInfos {
+		currentModelInfos[modelInfo.Model] = modelInfo
+	}
+	for _, modelInfo := range b.ModelInfos {
+		if old, ok := currentModelInfos[modelInfo.Model]; !ok {
+			diff.AddedModelInfos = append(diff.AddedModelInfos, modelInfo.Model)
+		} else if !jsonEqual(old, portableModelInfo(modelInfo)) {
+			diff.UpdatedModelInfos = append(diff.UpdatedModelInfos, modelInfo.Model)
+		}
+	}
+
+	for key, value := range b.Options {
+		if current.Options[key] != value {
+			diff.UpdatedOptions = append(diff.UpdatedOptions, key)
+		}
+	}
+	sort.Strings(diff.UpdatedOptions)
+
+	return diff, nil
+}
+
+// ImportPricingBundle 导入价格配置，已存在的模型价格与模型信息会被覆盖
+func ImportPricingBundle(b *PricingBundle) (*PricingBundleDiff, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	diff, err := DiffPricingBundle(b)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := DB.Begin()
+	if len(b.Prices) > 0 {
+		models := make([]string, 0, len(b.Prices))
+		for _, price := range b.Prices {
+			price.ModelInfo = nil
+			models = append(models, price.Model)
+		}
+		if err = DeletePrices(tx, models); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err = InsertPrices(tx, b.Prices); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	for _, modelInfo := range b.ModelInfos {
+		modelInfo = portableModelInfo(modelInfo)
+		existing := &ModelInfo{}
+		if tx.Where("model = ?", modelInfo.Model).Limit(1).Find(existing).RowsAffected > 0 {
+			modelInfo.Id = existing.Id
+			err = tx.Omit("id", "created_at").Save(modelInfo).Error
+		} else {
+			err = tx.Create(modelInfo).Error
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	for _, key := range diff.UpdatedOptions {
+		if err = UpdateOption(key, b.Options[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	if PricingInstance != nil {
+		if err = PricingInstance.Init(); err != nil {
+			return nil, err
+		}
+	}
+
+	return diff, nil
+}
+
+// portableModelInfo 去掉与实例相关的字段
+func portableModelInfo(modelInfo *ModelInfo) *ModelInfo {
+	portable := *modelInfo
+	portable.Id, portable.CreatedAt, portable.UpdatedAt = 0, 0, 0
+	return &portable
+}
+
+func jsonEqual(a, b any) bool {
+	aJson, aErr := json.Marshal(a)
+	bJson, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJson) == string(bJson)
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"encoding/json"
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupPricingBundleDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&Price{}, &ModelInfo{}, &Option{}))
+	DB = db
+	PricingInstance = &Pricing{Prices: map[string]*Price{}}
+}
+
+func TestPricingBundleRoundTrip(t *testing.T) {
+	defer func() {
+		PricingInstance = nil
+		config.GlobalOption.Set("ClaudeDefaultMaxTokens", "")
+		config.GlobalOption.Set("ModelDowngrade", "")
+	}()
+
+	setupPricingBundleDB(t)
+	extraRatios := datatypes.NewJSONType(map[string]float64{config.UsageExtraCachedRead: 0.1, config.UsageExtraCachedWrite: 1.25})
+	assert.Nil(t, InsertPrices(DB, []*Price{
+		{Model: "claude-3-5-sonnet", Type: TokensPriceType, ChannelType: config.ChannelTypeAnthropic, Input: 1.5, Output: 7.5, ExtraRatios: &extraRatios},
+		{Model: "midjourney", Type: TimesPriceType, Input: 25, Locked: true},
+	}))
+	assert.Nil(t, CreateModelInfo(&ModelInfo{Model: "claude-3-5-sonnet", Name: "Claude 3.5 Sonnet", ContextLength: 200000, MaxTokens: 8192, Tags: `["chat"]`}))
+	assert.Nil(t, UpdateOption("ClaudeDefaultMaxTokens", `{"claude-3-5-sonnet":4096,"default":8192}`))
+	assert.Nil(t, UpdateOption("ModelDowngrade", `{"claude-3-5-sonnet":{"model":"claude-3-5-haiku","threshold":1000}}`))
+
+	exported, err := ExportPricingBundle()
+	assert.Nil(t, err)
+	exportedJson, err := json.Marshal(exported)
+	assert.Nil(t, err)
+
+	// 导入到一个新的实例
+	setupPricingBundleDB(t)
+	config.GlobalOption.Set("ClaudeDefaultMaxTokens", "")
+	config.GlobalOption.Set("ModelDowngrade", "")
+
+	bundle := &PricingBundle{}
+	assert.Nil(t, json.Unmarshal(exportedJson, bundle))
+	assert.Nil(t, bundle.Validate())
+
+	diff, err := DiffPricingBundle(bundle)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"claude-3-5-sonnet", "midjourney"}, diff.AddedPrices)
+	assert.Equal(t, []string{"claude-3-5-sonnet"}, diff.AddedModelInfos)
+	assert.Equal(t, []string{"ClaudeDefaultMaxTokens", "ModelDowngrade"}, diff.UpdatedOptions)
+
+	// dry-run 不写入
+	prices, _ := GetAllPrices()
+	assert.Empty(t, prices)
+
+	_, err = ImportPricingBundle(bundle)
+	assert.Nil(t, err)
+	assert.Equal(t, 4096, config.ClaudeSettingsInstance.GetDefaultMaxTokens("claude-3-5-sonnet"))
+	assert.Equal(t, 25.0, PricingInstance.GetPrice("midjourney").Input)
+
+	reexported, err := ExportPricingBundle()
+	assert.Nil(t, err)
+	reexportedJson, err := json.Marshal(reexported)
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(exportedJson), string(reexportedJson))
+
+	// 再次导入相同内容没有变化
+	diff, err = DiffPricingBundle(bundle)
+	assert.Nil(t, err)
+	assert.Empty(t, diff.AddedPrices)
+	assert.Empty(t, diff.UpdatedPrices)
+	assert.Empty(t, diff.UpdatedModelInfos)
+	assert.Empty(t, diff.UpdatedOptions)
+}
+
+func TestPricingBundleValidate(t *testing.T) {
+	malformed := map[string]string{
+		"version":         `{"version":2}`,
+		"empty model":     `{"version":1,"prices":[{"model":"","type":"tokens"}]}`,
+		"duplicate":       `{"version":1,"prices":[{"model":"gpt-4","type":"tokens"},{"model":"gpt-4","type":"tokens"}]}`,
+		"type":            `{"version":1,"prices":[{"model":"gpt-4","type":"free"}]}`,
+		"negative":        `{"version":1,"prices":[{"model":"gpt-4","type":"tokens","input":-1}]}`,
+		"extra ratio":     `{"version":1,"prices":[{"model":"gpt-4","type":"tokens","extra_ratios":{"cached_read":-0.1}}]}`,
+		"model info":      `{"version":1,"model_infos":[{"name":"GPT-4"}]}`,
+		"unknown option":  `{"version":1,"options":{"RootUserEmail":"a@b.c"}}`,
+		"malformed value": `{"version":1,"options":{"ModelDowngrade":"not json"}}`,
+	}
+
+	for name, body := range malformed {
+		bundle := &PricingBundle{}
+		assert.Nil(t, json.Unmarshal([]byte(body), bundle), name)
+		assert.NotNil(t, bundle.Validate(), name)
+	}
+
+	bundle := &PricingBundle{Version: PricingBundleVersion, Prices: []*Price{{Model: "gpt-4", Type: TokensPriceType, Input: 15, Output: 30}}}
+	assert.Nil(t, bundle.Validate())
+}
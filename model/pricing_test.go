@@ -0,0 +1,41 @@
+package model
+
+import (
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEffectivePricesList(t *testing.T) {
+	PricingInstance = &Pricing{
+		Prices: map[string]*Price{
+			"claude-3-5-sonnet": {Model: "claude-3-5-sonnet", Type: TokensPriceType, ChannelType: config.ChannelTypeAnthropic, Input: 1.5, Output: 7.5},
+			"midjourney":        {Model: "midjourney", Type: TimesPriceType, Input: 25},
+		},
+	}
+	defer func() { PricingInstance = nil }()
+
+	prices := GetEffectivePricesList(2)
+	assert.Len(t, prices, 2)
+
+	times := prices[0]
+	assert.Equal(t, "midjourney", times.Model)
+	assert.Equal(t, float64(50), times.Input)
+	assert.Equal(t, 0.1, times.InputUSD)
+	assert.Nil(t, times.ExtraUSD)
+
+	tokens := prices[1]
+	assert.Equal(t, "claude-3-5-sonnet", tokens.Model)
+	assert.Equal(t, float64(2), tokens.GroupRatio)
+	assert.Equal(t, float64(3), tokens.Input)
+	assert.Equal(t, float64(15), tokens.Output)
+	assert.Equal(t, float64(6), tokens.InputUSD)
+	assert.Equal(t, float64(30), tokens.OutputUSD)
+	assert.Equal(t, 0.6, tokens.ExtraUSD[config.UsageExtraCachedRead])
+	assert.Equal(t, 7.5, tokens.ExtraUSD[config.UsageExtraCachedWrite])
+
+	discounted := GetEffectivePricesList(0.5)
+	assert.Equal(t, 0.75, discounted[1].Input)
+	assert.Equal(t, 1.5, discounted[1].InputUSD)
+}
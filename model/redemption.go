@@ -86,6 +86,7 @@ func Redeem(key string, userId int, ip string) (quota int, err error) {
 	if err != nil {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
+	ResetUserQuotaRemind(userId)
 
 	// Try to upgrade user group based on cumulative recharge amount
 	err = CheckAndUpgradeUserGroup(userId, redemption.Quota)
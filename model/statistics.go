@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"one-api/common"
+	"sort"
 	"strings"
 	"time"
 )
@@ -230,6 +231,89 @@ func GetChannelExpensesStatisticsByPeriod(startTime, endTime, groupType string,
 	return LogStatistics, nil
 }
 
+// ChannelLabelStatistic 按渠道标签分组的统计数据
+type ChannelLabelStatistic struct {
+	Label            string `json:"label"`
+	ChannelCount     int    `json:"channel_count"`
+	RequestCount     int64  `json:"request_count"`
+	Quota            int64  `json:"quota"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	RequestTime      int64  `json:"request_time"`
+}
+
+type channelUsageStatistic struct {
+	ChannelId        int   `gorm:"column:channel_id"`
+	RequestCount     int64 `gorm:"column:request_count"`
+	Quota            int64 `gorm:"column:quota"`
+	PromptTokens     int64 `gorm:"column:prompt_tokens"`
+	CompletionTokens int64 `gorm:"column:completion_tokens"`
+	RequestTime      int64 `gorm:"column:request_time"`
+}
+
+// GetChannelLabelStatisticsByPeriod 获取指定时间段内按渠道标签分组的统计数据
+// 一个渠道有多个标签时，其用量会分别计入每个标签
+func GetChannelLabelStatisticsByPeriod(startTime, endTime string) ([]*ChannelLabelStatistic, error) {
+	var usages []*channelUsageStatistic
+	err := DB.Raw(`
+		SELECT channel_id,
+		sum(request_count) as request_count,
+		sum(quota) as quota,
+		sum(prompt_tokens) as prompt_tokens,
+		sum(completion_tokens) as completion_tokens,
+		sum(request_time) as request_time
+		FROM statistics
+		WHERE date BETWEEN ? AND ?
+		GROUP BY channel_id
+	`, startTime, endTime).Scan(&usages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []*Channel
+	if err = DB.Select("id", "labels").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+
+	return aggregateChannelLabelStatistics(usages, channels), nil
+}
+
+func aggregateChannelLabelStatistics(usages []*channelUsageStatistic, channels []*Channel) []*ChannelLabelStatistic {
+	channelLabels := make(map[int][]string, len(channels))
+	for _, channel := range channels {
+		if channel.Labels != nil {
+			channelLabels[channel.Id] = *channel.Labels
+		}
+	}
+
+	statistics := make(map[string]*ChannelLabelStatistic)
+	for _, usage := range usages {
+		for _, label := range channelLabels[usage.ChannelId] {
+			statistic, ok := statistics[label]
+			if !ok {
+				statistic = &ChannelLabelStatistic{Label: label}
+				statistics[label] = statistic
+			}
+			statistic.ChannelCount++
+			statistic.RequestCount += usage.RequestCount
+			statistic.Quota += usage.Quota
+			statistic.PromptTokens += usage.PromptTokens
+			statistic.CompletionTokens += usage.CompletionTokens
+			statistic.RequestTime += usage.RequestTime
+		}
+	}
+
+	result := make([]*ChannelLabelStatistic, 0, len(statistics))
+	for _, statistic := range statistics {
+		result = append(result, statistic)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Label < result[j].Label
+	})
+
+	return result
+}
+
 type StatisticsUpdateType int
 
 const (
@@ -67,6 +67,24 @@ type TokenSetting struct {
 	Heartbeat  HeartbeatSetting `json:"heartbeat,omitempty"`
 	Limits     LimitsConfig     `json:"limits,omitempty"`
 	BillingTag *string          `json:"billing_tag,omitempty"` // 费用标签，用于按分组统计费用，仅可信内部员工和管理员可见
+
+	ChannelLabels   []string `json:"channel_labels,omitempty"`    // 限制令牌只使用包含全部标签的渠道
+	BufferToolCalls bool     `json:"buffer_tool_calls,omitempty"` // 流式响应中缓冲工具调用参数，完整后一次性输出
+	MaxPriority     string   `json:"max_priority,omitempty"`      // 请求可使用的最高优先级 high/normal/low，默认 normal
+
+	AllowedEndpoints []string `json:"allowed_endpoints,omitempty"` // 令牌可调用的接口，如 chat_completions、models，为空时不限制
+
+	ModelDisplayNames map[string]string `json:"model_display_names,omitempty"` // 真实模型 => 对外展示的名称，优先于分组配置
+
+	Privileged    bool `json:"privileged,omitempty"`      // 特权令牌，错误信息中附带上游请求 ID，仅可信内部员工和管理员可设置
+	MaxStreamCost int  `json:"max_stream_cost,omitempty"` // 单次流式请求的费用上限（额度），超出时中止输出，0 为不限制；仅 /v1/chat/completions 支持，其他接口的流式请求会被拒绝
+
+	CompletionWebhook *CompletionWebhookSetting `json:"completion_webhook,omitempty"` // 每次请求完成后回调
+}
+
+type CompletionWebhookSetting struct {
+	URL            string `json:"url"`
+	IncludeContent bool   `json:"include_content"` // 回调中包含请求内容，默认只发送用量摘要
 }
 
 type HeartbeatSetting struct {
@@ -280,6 +298,13 @@ func GetTokenById(id int) (*Token, error) {
 	return &token, err
 }
 
+// GetTokenByIdUnscoped 按 ID 获取令牌，包括已删除的令牌
+func GetTokenByIdUnscoped(id int) (*Token, error) {
+	var token Token
+	err := DB.Unscoped().First(&token, "id = ?", id).Error
+	return &token, err
+}
+
 func GetTokenByName(name string, userId int) (*Token, error) {
 	if name == "" {
 		return nil, errors.New("name 为空！")
@@ -421,11 +446,6 @@ func PreConsumeTokenQuota(tokenId int, quota int) (err error) {
 	if userQuota < quota {
 		return errors.New("用户额度不足")
 	}
-	quotaTooLow := userQuota >= config.QuotaRemindThreshold && userQuota-quota < config.QuotaRemindThreshold
-	noMoreQuota := userQuota-quota <= 0
-	if quotaTooLow || noMoreQuota {
-		go sendQuotaWarningEmail(token.UserId, userQuota, noMoreQuota)
-	}
 	if !token.UnlimitedQuota {
 		err = DecreaseTokenQuota(tokenId, quota)
 		if err != nil {
@@ -436,6 +456,61 @@ func PreConsumeTokenQuota(tokenId int, quota int) (err error) {
 	return err
 }
 
+var quotaRemindNotifier = sendQuotaWarningEmail
+
+// 已发送的额度提醒级别
+const (
+	quotaRemindNone = iota
+	quotaRemindLow
+	quotaRemindExhausted
+)
+
+// quotaRemindLevelExpr 按数据库中的当前额度计算应处的提醒级别
+const quotaRemindLevelExpr = "CASE WHEN quota <= 0 THEN ? WHEN quota < ? THEN ? ELSE ? END"
+
+// getQuotaRemindLevel 低于阈值时为 low，额度耗尽时为 exhausted
+func getQuotaRemindLevel(quota int, threshold int) int {
+	if quota <= 0 {
+		return quotaRemindExhausted
+	}
+	if threshold > 0 && quota < threshold {
+		return quotaRemindLow
+	}
+	return quotaRemindNone
+}
+
+// CheckUserQuotaRemind 扣费后按调用方已知的余额检查是否需要提醒，每个级别只提醒一次
+// 余额高于阈值时不访问数据库
+func CheckUserQuotaRemind(userId int, quota int) {
+	level := getQuotaRemindLevel(quota, config.QuotaRemindThreshold)
+	if level == quotaRemindNone {
+		return
+	}
+
+	// 通过条件更新保证并发下只有一个请求能提升级别，避免重复提醒
+	result := DB.Model(&User{}).Where("id = ? AND quota_remind_level < ?", userId, level).Update("quota_remind_level", level)
+	if result.Error != nil {
+		logger.SysError("failed to update user quota remind level: " + result.Error.Error())
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		quotaRemindNotifier(userId, quota, level == quotaRemindExhausted)
+	}
+}
+
+// ResetUserQuotaRemind 充值或退款写入数据库后调用，按数据库中的额度降低提醒级别，额度再次下降时重新提醒
+func ResetUserQuotaRemind(userId int) {
+	threshold := config.QuotaRemindThreshold
+	args := []any{quotaRemindExhausted, threshold, quotaRemindLow, quotaRemindNone}
+	err := DB.Model(&User{}).
+		Where("id = ? AND quota_remind_level > "+quotaRemindLevelExpr, append([]any{userId}, args...)...).
+		Update("quota_remind_level", gorm.Expr(quotaRemindLevelExpr, args...)).Error
+	if err != nil {
+		logger.SysError("failed to reset user quota remind level: " + err.Error())
+	}
+}
+
 func sendQuotaWarningEmail(userId int, userQuota int, noMoreQuota bool) {
 	user := User{Id: userId}
 
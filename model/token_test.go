@@ -0,0 +1,91 @@
+package model
+
+import (
+	"one-api/common/config"
+	"one-api/common/logger"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&User{}); err != nil {
+		t.Fatal(err)
+	}
+	DB = db
+}
+
+func getQuotaRemindLevelFromDB(userId int) int {
+	level := -1
+	DB.Model(&User{}).Where("id = ?", userId).Select("quota_remind_level").Find(&level)
+	return level
+}
+
+func TestCheckUserQuotaRemind(t *testing.T) {
+	setupTestDB(t)
+	config.QuotaRemindThreshold = 1000
+
+	notified := make([]bool, 0)
+	quotaRemindNotifier = func(userId int, userQuota int, noMoreQuota bool) {
+		notified = append(notified, noMoreQuota)
+	}
+	defer func() { quotaRemindNotifier = sendQuotaWarningEmail }()
+
+	user := &User{Username: "remind", Quota: 1500, AccessToken: "remind", AffCode: "remind"}
+	assert.Nil(t, DB.Create(user).Error)
+
+	// 未低于阈值，不提醒
+	CheckUserQuotaRemind(user.Id, 1500)
+	assert.Empty(t, notified)
+
+	// 低于阈值后只提醒一次
+	assert.Nil(t, decreaseUserQuota(user.Id, 800))
+	CheckUserQuotaRemind(user.Id, 700)
+	CheckUserQuotaRemind(user.Id, 600)
+	assert.Equal(t, []bool{false}, notified)
+
+	// 额度耗尽时再发送一次耗尽提醒
+	assert.Nil(t, decreaseUserQuota(user.Id, 700))
+	CheckUserQuotaRemind(user.Id, 0)
+	CheckUserQuotaRemind(user.Id, -10)
+	assert.Equal(t, []bool{false, true}, notified)
+
+	// 充值后按数据库中的额度重置提醒级别
+	assert.Nil(t, IncreaseUserQuota(user.Id, 500))
+	assert.Equal(t, quotaRemindLow, getQuotaRemindLevelFromDB(user.Id))
+	assert.Nil(t, IncreaseUserQuota(user.Id, 2000))
+	assert.Equal(t, quotaRemindNone, getQuotaRemindLevelFromDB(user.Id))
+
+	// 退款等增加额度的操作不会提升级别
+	assert.Nil(t, decreaseUserQuota(user.Id, 2400))
+	assert.Nil(t, IncreaseUserQuota(user.Id, 1))
+	assert.Equal(t, quotaRemindNone, getQuotaRemindLevelFromDB(user.Id))
+
+	// 再次低于阈值时重新提醒
+	CheckUserQuotaRemind(user.Id, 101)
+	assert.Equal(t, []bool{false, true, false}, notified)
+}
+
+func TestCheckUserQuotaRemindBatchUpdate(t *testing.T) {
+	setupTestDB(t)
+	config.QuotaRemindThreshold = 1000
+	config.BatchUpdateEnabled = true
+	logger.Logger = zap.NewNop()
+	defer func() { config.BatchUpdateEnabled = false }()
+
+	user := &User{Username: "batch", Quota: 0, QuotaRemindLevel: quotaRemindExhausted, AccessToken: "batch", AffCode: "batch"}
+	assert.Nil(t, DB.Create(user).Error)
+
+	// 批量更新模式下充值写入数据库后重置提醒级别
+	assert.Nil(t, IncreaseUserQuota(user.Id, 2000))
+	batchUpdate()
+
+	assert.Equal(t, quotaRemindNone, getQuotaRemindLevelFromDB(user.Id))
+}
@@ -46,6 +46,7 @@ type User struct {
 	InviterId        int            `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
 	LastLoginTime    int64          `json:"last_login_time" gorm:"bigint;default:0"`
 	LastLoginIp      string         `json:"last_login_ip" gorm:"type:varchar(128);default:''"`
+	QuotaRemindLevel int            `json:"-" gorm:"default:0"` // 已发送的额度提醒：0 未提醒，1 低额度，2 额度耗尽；充值后重置
 	CreatedTime      int64          `json:"created_time" gorm:"bigint"`
 	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 }
@@ -202,6 +203,12 @@ func (user *User) Delete() error {
 		return err
 	}
 
+	// 解除 LinuxDo 绑定，被删除的用户不占用 linuxdo_id 的唯一约束
+	err = DB.Model(user).Update("linuxdo_id", "").Error
+	if err != nil {
+		return err
+	}
+
 	err = DB.Delete(user).Error
 	return err
 }
@@ -364,6 +371,57 @@ func IsLinuxDoIdAlreadyTaken(linuxDoId string) bool {
 	return IsFieldAlreadyTaken("linuxdo_id", linuxDoId)
 }
 
+const (
+	linuxDoIdUniqueIndex = "idx_users_linuxdo_id_unique"
+	// linuxDoIdUniqueColumn MySQL 中用于唯一索引的生成列，空字符串转为 NULL
+	linuxDoIdUniqueColumn = "linuxdo_id_unique"
+)
+
+// CreateLinuxDoIdUniqueIndex 为非空的 linuxdo_id 创建唯一索引，避免并发绑定同一个 LinuxDo 账户
+// 未绑定用户的空值不参与约束；已有重复绑定的数据时创建失败
+func CreateLinuxDoIdUniqueIndex(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&User{}, linuxDoIdUniqueIndex) {
+		return nil
+	}
+
+	if db.Dialector.Name() == "mysql" {
+		// MySQL 不支持部分索引，函数索引需要 8.0.13 以上且 MariaDB 不支持，
+		// 改为在生成列上建唯一索引（MySQL 5.7、MariaDB 10.2 起支持），唯一索引中的多个 NULL 不冲突
+		if !db.Migrator().HasColumn(&User{}, linuxDoIdUniqueColumn) {
+			err := db.Exec("ALTER TABLE users ADD COLUMN " + linuxDoIdUniqueColumn + " VARCHAR(191) AS (NULLIF(linuxdo_id, '')) STORED").Error
+			if err != nil {
+				return err
+			}
+		}
+		return db.Exec("CREATE UNIQUE INDEX " + linuxDoIdUniqueIndex + " ON users (" + linuxDoIdUniqueColumn + ")").Error
+	}
+
+	return db.Exec("CREATE UNIQUE INDEX " + linuxDoIdUniqueIndex + " ON users (linuxdo_id) WHERE linuxdo_id <> ''").Error
+}
+
+// IsUniqueConstraintError 判断是否为违反唯一约束的数据库错误
+func IsUniqueConstraintError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	message := err.Error()
+	return strings.Contains(message, "UNIQUE constraint failed") || // sqlite
+		strings.Contains(message, "Duplicate entry") || // mysql
+		strings.Contains(message, "duplicate key value") // postgres
+}
+
+// IsLinuxDoIdConflictError 判断是否违反了 linuxdo_id 的唯一约束，其他字段（如用户名）重复时返回 false
+// sqlite 的错误信息只包含列名，mysql 和 postgres 包含索引名
+func IsLinuxDoIdConflictError(err error) bool {
+	if !IsUniqueConstraintError(err) {
+		return false
+	}
+
+	message := err.Error()
+	return strings.Contains(message, linuxDoIdUniqueIndex) || strings.Contains(message, "users.linuxdo_id")
+}
+
 func IsLarkIdAlreadyTaken(larkId string) bool {
 	return IsFieldAlreadyTaken("lark_id", larkId)
 }
@@ -465,10 +523,15 @@ func IncreaseUserQuota(id int, quota int) (err error) {
 		return errors.New("quota 不能为负数！")
 	}
 	if config.BatchUpdateEnabled {
+		// 批量写入数据库后再检查额度提醒
 		addNewRecord(BatchUpdateTypeUserQuota, id, quota)
 		return nil
 	}
-	return increaseUserQuota(id, quota)
+	err = increaseUserQuota(id, quota)
+	if err == nil {
+		ResetUserQuotaRemind(id)
+	}
+	return err
 }
 
 func increaseUserQuota(id int, quota int) (err error) {
@@ -590,6 +653,10 @@ func ChangeUserQuota(id int, quota int, isRecharge bool) (err error) {
 		return err
 	}
 
+	if quota > 0 {
+		ResetUserQuotaRemind(id)
+	}
+
 	if config.RedisEnabled {
 		redis.RedisDel(fmt.Sprintf(UserQuotaCacheKey, id))
 	}
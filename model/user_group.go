@@ -20,6 +20,9 @@ type UserGroup struct {
 	Min       int     `json:"min" form:"min" gorm:"default:0"`                 // 晋级条件最小值
 	Max       int     `json:"max" form:"max" gorm:"default:0"`                 // 晋级条件最大值
 	Enable    *bool   `json:"enable" form:"enable" gorm:"default:true"`        // 是否启用
+
+	DisableStream bool `json:"disable_stream" form:"disable_stream" gorm:"default:false"` // 禁用流式请求，按 GroupStreamDisabledPolicy 处理
+	Watermark     bool `json:"watermark" form:"watermark" gorm:"default:false"`           // 文本输出嵌入不可见水印，用于追溯泄露内容的令牌
 }
 
 type SearchUserGroupParams struct {
@@ -75,7 +78,7 @@ func (c *UserGroup) Create() error {
 }
 
 func (c *UserGroup) Update() error {
-	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max").Updates(c).Error
+	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max", "disable_stream", "watermark").Updates(c).Error
 	if err == nil {
 		GlobalUserGroupRatio.Load()
 	}
@@ -175,6 +178,18 @@ func (cgrm *UserGroupRatio) GetAPIRate(symbol string) int {
 	return userGroup.APIRate
 }
 
+// IsStreamDisabled 分组是否禁用了流式请求
+func (cgrm *UserGroupRatio) IsStreamDisabled(symbol string) bool {
+	userGroup := cgrm.GetBySymbol(symbol)
+	return userGroup != nil && userGroup.DisableStream
+}
+
+// IsWatermarkEnabled 分组是否开启了响应水印
+func (cgrm *UserGroupRatio) IsWatermarkEnabled(symbol string) bool {
+	userGroup := cgrm.GetBySymbol(symbol)
+	return userGroup != nil && userGroup.Watermark
+}
+
 func (cgrm *UserGroupRatio) GetPublicGroupList() []string {
 	cgrm.RLock()
 	defer cgrm.RUnlock()
@@ -207,7 +222,7 @@ func CheckAndUpgradeUserGroup(userId int, rechargeAmount int) error {
 
 	// Calculate cumulative recharge amount
 	cumulativeAmount := user.Quota + user.UsedQuota + rechargeAmount
-	logger.SysError(fmt.Sprintf("use:%f q:%f  cumulative:%f rechargeAmount:%f", (float64)(user.UsedQuota)/config.QuotaPerUnit, (float64)(user.Quota)/config.QuotaPerUnit, cumulativeAmount, rechargeAmount))
+	logger.SysError(fmt.Sprintf("use:%f q:%f  cumulative:%d rechargeAmount:%d", (float64)(user.UsedQuota)/config.QuotaPerUnit, (float64)(user.Quota)/config.QuotaPerUnit, cumulativeAmount, rechargeAmount))
 	// Get all promotion-enabled user groups
 	var promotionGroups []*UserGroup
 	err = DB.Where("promotion = ? AND enable = ?", true, true).Find(&promotionGroups).Error
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLinuxDoIdConflictError(t *testing.T) {
+	setupTestDB(t)
+	assert.Nil(t, CreateLinuxDoIdUniqueIndex(DB))
+
+	assert.Nil(t, DB.Create(&User{Username: "first", LinuxDoId: "100", AccessToken: "first", AffCode: "first"}).Error)
+
+	// linuxdo_id 重复
+	err := DB.Create(&User{Username: "second", LinuxDoId: "100", AccessToken: "second", AffCode: "second"}).Error
+	assert.True(t, IsUniqueConstraintError(err))
+	assert.True(t, IsLinuxDoIdConflictError(err))
+
+	// 其他唯一字段重复不是 LinuxDo 绑定冲突
+	err = DB.Create(&User{Username: "first", LinuxDoId: "200", AccessToken: "third", AffCode: "third"}).Error
+	assert.True(t, IsUniqueConstraintError(err))
+	assert.False(t, IsLinuxDoIdConflictError(err))
+}
+
+func TestMigrationAfterFailsWithoutLinuxDoIdUniqueIndex(t *testing.T) {
+	setupTestDB(t)
+	isMasterNode := config.IsMasterNode
+	config.IsMasterNode = true
+	defer func() { config.IsMasterNode = isMasterNode }()
+
+	// 已有重复绑定时无法创建唯一索引，迁移直接失败而不是只记录日志
+	assert.Nil(t, DB.Create(&User{Username: "first", LinuxDoId: "100", AccessToken: "first", AffCode: "first"}).Error)
+	assert.Nil(t, DB.Create(&User{Username: "second", LinuxDoId: "100", AccessToken: "second", AffCode: "second"}).Error)
+
+	err := migrationAfter(DB)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "linuxdo_id")
+}
@@ -89,6 +89,10 @@ func batchUpdate() {
 				err := increaseUserQuota(key, value)
 				if err != nil {
 					logger.SysError("failed to batch update user quota: " + err.Error())
+					continue
+				}
+				if value > 0 {
+					ResetUserQuotaRemind(key)
 				}
 			case BatchUpdateTypeTokenQuota:
 				err := increaseTokenQuota(key, value)
@@ -106,6 +106,13 @@ func (p *BaseProvider) CommonRequestHeaders(headers map[string]string) {
 	if headers["Content-Type"] == "" {
 		headers["Content-Type"] = "application/json"
 	}
+	headers["User-Agent"] = p.Channel.UserAgent
+	if headers["User-Agent"] == "" {
+		headers["User-Agent"] = "one-hub/" + config.Version
+	}
+	if p.Channel.ClientId != "" {
+		headers["X-Client-Id"] = p.Channel.ClientId
+	}
 	// 自定义header
 	if p.Channel.ModelHeaders != nil {
 		var customHeaders map[string]string
@@ -128,6 +135,26 @@ func (p *BaseProvider) SetUsage(usage *types.Usage) {
 
 func (p *BaseProvider) SetContext(c *gin.Context) {
 	p.Context = c
+	if p.Requester != nil && c != nil {
+		c.Set(config.GinUpstreamRequestIdKey, "")
+		p.Requester.OnResponse = p.recordUpstreamRequestId
+	}
+}
+
+// UpstreamRequestIdHeader 返回给客户端的上游请求 ID 响应头
+const UpstreamRequestIdHeader = "X-Upstream-Request-Id"
+
+// recordUpstreamRequestId 记录上游返回的请求 ID，开启后同时通过响应头返回给客户端
+func (p *BaseProvider) recordUpstreamRequestId(resp *http.Response) {
+	id := requester.GetUpstreamRequestId(resp.Header)
+	if id == "" {
+		return
+	}
+
+	p.Context.Set(config.GinUpstreamRequestIdKey, id)
+	if config.UpstreamRequestIdHeaderEnabled && !p.Context.Writer.Written() {
+		p.Context.Header(UpstreamRequestIdHeader, id)
+	}
 }
 
 func (p *BaseProvider) SetOriginalModel(ModelName string) {
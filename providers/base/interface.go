@@ -1,6 +1,7 @@
 package base
 
 import (
+	"context"
 	"net/http"
 	"one-api/common/requester"
 	"one-api/model"
@@ -43,6 +44,11 @@ type ProviderInterface interface {
 	GetSupportedResponse() bool
 }
 
+// 提示 token 计数接口，上游未返回提示 token 时用于获取准确值
+type PromptTokenCounter interface {
+	CountPromptTokens(ctx context.Context) (int, error)
+}
+
 // 完成接口
 type CompletionInterface interface {
 	ProviderInterface
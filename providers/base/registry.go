@@ -0,0 +1,38 @@
+package base
+
+import (
+	"fmt"
+	"one-api/model"
+	"sync"
+)
+
+// 定义供应商工厂接口
+type ProviderFactory interface {
+	Create(Channel *model.Channel) ProviderInterface
+}
+
+var (
+	providerFactories   = make(map[int]ProviderFactory)
+	providerFactoriesMu sync.RWMutex
+)
+
+// RegisterProviderFactory 按渠道类型注册供应商工厂，供应商在 init 中调用，重复注册时后者覆盖前者
+func RegisterProviderFactory(channelType int, factory ProviderFactory) {
+	providerFactoriesMu.Lock()
+	defer providerFactoriesMu.Unlock()
+
+	providerFactories[channelType] = factory
+}
+
+// GetProviderFactory 按渠道类型获取已注册的供应商工厂
+func GetProviderFactory(channelType int) (ProviderFactory, error) {
+	providerFactoriesMu.RLock()
+	defer providerFactoriesMu.RUnlock()
+
+	factory, ok := providerFactories[channelType]
+	if !ok {
+		return nil, fmt.Errorf("no provider factory registered for channel type %d", channelType)
+	}
+
+	return factory, nil
+}
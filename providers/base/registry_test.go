@@ -0,0 +1,22 @@
+package base_test
+
+import (
+	"one-api/common/config"
+	"one-api/providers/base"
+	"one-api/providers/claude"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProviderFactoryRegistered(t *testing.T) {
+	factory, err := base.GetProviderFactory(config.ChannelTypeAnthropic)
+	assert.Nil(t, err)
+	assert.IsType(t, claude.ClaudeProviderFactory{}, factory)
+}
+
+func TestGetProviderFactoryUnknownType(t *testing.T) {
+	factory, err := base.GetProviderFactory(-1)
+	assert.Nil(t, factory)
+	assert.EqualError(t, err, "no provider factory registered for channel type -1")
+}
@@ -93,6 +93,7 @@ func (p *BedrockProvider) getClaudeRequest(request *claude.ClaudeRequest) (*http
 	}
 	bedrockRequest.Model = ""
 	bedrockRequest.Stream = false
+	bedrockRequest.Metadata = nil
 
 	// 创建请求
 	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(bedrockRequest), p.Requester.WithHeader(headers))
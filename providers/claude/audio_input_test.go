@@ -0,0 +1,75 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const audioTestImage = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
+
+// sendAudioRequest 发送 OpenAI 格式的请求，返回上游收到的请求体，未请求上游时为空
+func sendAudioRequest(t *testing.T, content string) (string, *types.OpenAIErrorWithStatusCode) {
+	requester.InitHttpClient()
+	upstreamBody := ""
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		requestBody, _ := io.ReadAll(r.Body)
+		upstreamBody = string(requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	ctx, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider, _ := providers.GetProvider(&channel, ctx).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	var request types.ChatCompletionRequest
+	body := `{"model":"claude-sonnet-4-20250514","max_tokens":100,"messages":[{"role":"user","content":` + content + `}]}`
+	assert.Nil(t, json.Unmarshal([]byte(body), &request))
+	_, errWithCode := chatProvider.CreateChatCompletion(&request)
+
+	return upstreamBody, errWithCode
+}
+
+func TestAudioInputRejected(t *testing.T) {
+	audioContent := `[{"type":"text","text":"what is said?"},{"type":"input_audio","input_audio":{"data":"UklGRg==","format":"wav"}}]`
+
+	upstreamBody, errWithCode := sendAudioRequest(t, audioContent)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Equal(t, "unsupported_modality", errWithCode.Code)
+	assert.Contains(t, errWithCode.Message, "audio")
+	assert.Empty(t, upstreamBody)
+
+	// 关闭后忽略音频内容
+	config.ClaudeSettingsInstance.RejectAudioInput = false
+	defer func() { config.ClaudeSettingsInstance.RejectAudioInput = true }()
+	upstreamBody, errWithCode = sendAudioRequest(t, audioContent)
+	assert.Nil(t, errWithCode)
+	assert.Contains(t, upstreamBody, "what is said?")
+	assert.NotContains(t, upstreamBody, "UklGRg==")
+}
+
+func TestTextAndImageInputPass(t *testing.T) {
+	upstreamBody, errWithCode := sendAudioRequest(t, `"hi"`)
+	assert.Nil(t, errWithCode)
+	assert.Contains(t, upstreamBody, "hi")
+
+	upstreamBody, errWithCode = sendAudioRequest(t, `[{"type":"text","text":"describe"},{"type":"image_url","image_url":{"url":"`+audioTestImage+`"}}]`)
+	assert.Nil(t, errWithCode)
+	assert.Contains(t, upstreamBody, `"type":"image"`)
+}
@@ -1,9 +1,13 @@
 package claude
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"one-api/common/config"
 	"one-api/common/requester"
 	"one-api/model"
 	"one-api/providers/base"
@@ -11,8 +15,14 @@ import (
 	"strings"
 )
 
+const DefaultAnthropicVersion = "2023-06-01"
+
 type ClaudeProviderFactory struct{}
 
+func init() {
+	base.RegisterProviderFactory(config.ChannelTypeAnthropic, ClaudeProviderFactory{})
+}
+
 // 创建 ClaudeProvider
 func (f ClaudeProviderFactory) Create(channel *model.Channel) base.ProviderInterface {
 	return &ClaudeProvider{
@@ -26,6 +36,8 @@ func (f ClaudeProviderFactory) Create(channel *model.Channel) base.ProviderInter
 
 type ClaudeProvider struct {
 	base.BaseProvider
+	// 最近一次发送的请求，上游未返回提示 token 时用于 count_tokens
+	lastRequest *ClaudeRequest
 }
 
 func getConfig() base.ProviderConfig {
@@ -69,15 +81,32 @@ func (p *ClaudeProvider) GetRequestHeaders() (headers map[string]string) {
 	p.CommonRequestHeaders(headers)
 
 	headers["x-api-key"] = p.Channel.Key
-	anthropicVersion := p.Context.Request.Header.Get("anthropic-version")
-	if anthropicVersion == "" {
-		anthropicVersion = "2023-06-01"
-	}
-	headers["anthropic-version"] = anthropicVersion
+	headers["anthropic-version"] = p.getAnthropicVersion()
 
 	return headers
 }
 
+// 获取 anthropic-version，优先级：客户端请求头 > 渠道默认版本(Other) > 全局默认版本
+// getRequestContext 返回客户端请求的上下文，用于记录带请求 ID 的日志
+func (p *ClaudeProvider) getRequestContext() context.Context {
+	if p.Context == nil {
+		return context.Background()
+	}
+	return p.Context.Request.Context()
+}
+
+func (p *ClaudeProvider) getAnthropicVersion() string {
+	if anthropicVersion := p.Context.Request.Header.Get("anthropic-version"); anthropicVersion != "" {
+		return anthropicVersion
+	}
+
+	if channelVersion := strings.TrimSpace(p.Channel.Other); channelVersion != "" {
+		return channelVersion
+	}
+
+	return DefaultAnthropicVersion
+}
+
 func (p *ClaudeProvider) GetFullRequestURL(requestURL string) string {
 	baseURL := strings.TrimSuffix(p.GetBaseURL(), "/")
 	if strings.HasPrefix(baseURL, "https://gateway.ai.cloudflare.com") {
@@ -87,6 +116,25 @@ func (p *ClaudeProvider) GetFullRequestURL(requestURL string) string {
 	return fmt.Sprintf("%s%s", baseURL, requestURL)
 }
 
+// getResponseModel 按配置返回请求的模型或 Claude 实际返回的模型版本
+func getResponseModel(requestModel, upstreamModel string) string {
+	if config.ClaudeSettingsInstance.ResponseModel == config.ResponseModelUpstream && upstreamModel != "" {
+		return upstreamModel
+	}
+
+	return requestModel
+}
+
+// getSystemFingerprint 根据上游模型版本生成 system_fingerprint，模型版本变化时指纹随之变化
+func getSystemFingerprint(upstreamModel string) string {
+	if !config.ClaudeSettingsInstance.SystemFingerprint || upstreamModel == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(upstreamModel))
+	return "fp_" + hex.EncodeToString(hash[:])[:10]
+}
+
 func stopReasonClaude2OpenAI(reason string) string {
 	switch reason {
 	case "end_turn", "stop_sequence":
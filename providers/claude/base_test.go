@@ -0,0 +1,75 @@
+package claude_test
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/providers/claude"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getAnthropicVersionHeader(clientVersion, channelVersion string) string {
+	headers := test.RequestJSONConfig()
+	if clientVersion != "" {
+		headers["anthropic-version"] = clientVersion
+	}
+	context, _ := test.GetContext("POST", "/v1/chat/completions", headers, nil)
+	channel := test.GetChannel(config.ChannelTypeAnthropic, "", channelVersion, "", "")
+	provider := providers.GetProvider(&channel, context)
+
+	return provider.GetRequestHeaders()["anthropic-version"]
+}
+
+func TestGetRequestHeadersAnthropicVersion(t *testing.T) {
+	assert.Equal(t, claude.DefaultAnthropicVersion, getAnthropicVersionHeader("", ""))
+	assert.Equal(t, "2024-10-22", getAnthropicVersionHeader("", "2024-10-22"))
+	assert.Equal(t, "2023-01-01", getAnthropicVersionHeader("2023-01-01", "2024-10-22"))
+}
+
+func sendWithChannelHeaders(t *testing.T, userAgent, clientId string) http.Header {
+	requester.InitHttpClient()
+
+	var received http.Header
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":10,"output_tokens":5}}`)
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	channel.UserAgent = userAgent
+	channel.ClientId = clientId
+	context, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider, _ := providers.GetProvider(&channel, context).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	_, errWithCode := chatProvider.CreateChatCompletion(&types.ChatCompletionRequest{
+		Model:    "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{{Role: types.ChatMessageRoleUser, Content: "hi"}},
+	})
+	assert.Nil(t, errWithCode)
+
+	return received
+}
+
+func TestGetRequestHeadersUserAgent(t *testing.T) {
+	headers := sendWithChannelHeaders(t, "partner-gateway/1.0", "team-a")
+	assert.Equal(t, "partner-gateway/1.0", headers.Get("User-Agent"))
+	assert.Equal(t, "team-a", headers.Get("X-Client-Id"))
+
+	// 未配置时使用 one-hub 标识，且不发送 X-Client-Id
+	headers = sendWithChannelHeaders(t, "", "")
+	assert.Equal(t, "one-hub/"+config.Version, headers.Get("User-Agent"))
+	assert.Empty(t, headers.Get("X-Client-Id"))
+}
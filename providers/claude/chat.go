@@ -1,6 +1,7 @@
 package claude
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -24,19 +25,37 @@ const (
 )
 
 type ClaudeStreamHandler struct {
-	Usage       *types.Usage
-	Request     *types.ChatCompletionRequest
-	StreamTolls int
-	Prefix      string
+	Usage         *types.Usage
+	Request       *types.ChatCompletionRequest
+	StreamTolls   int
+	Prefix        string
+	UpstreamModel string
+
+	serverToolBlocks map[int]bool
+	// 已输出带 finish_reason 的块，以及本次响应是否包含工具调用
+	finishSent bool
+	toolUsed   bool
 }
 
 func (p *ClaudeProvider) CreateChatCompletion(request *types.ChatCompletionRequest) (*types.ChatCompletionResponse, *types.OpenAIErrorWithStatusCode) {
 	request.OneOtherArg = p.GetOtherArg()
-	claudeRequest, errWithCode := ConvertFromChatOpenai(request)
+	if errWithCode := p.applyZeroMaxTokens(&request.MaxTokens, request.Model); errWithCode != nil {
+		return nil, errWithCode
+	}
+	claudeRequest, errWithCode := convertFromChatOpenai(p.getRequestContext(), request)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	claudeResponse, errWithCode := p.createStrictChat(claudeRequest, getStrictToolSchemas(request))
 	if errWithCode != nil {
 		return nil, errWithCode
 	}
 
+	return ConvertToChatOpenai(p, claudeResponse, request)
+}
+
+func (p *ClaudeProvider) sendClaudeChat(claudeRequest *ClaudeRequest) (*ClaudeResponse, *types.OpenAIErrorWithStatusCode) {
 	req, errWithCode := p.getChatRequest(claudeRequest)
 	if errWithCode != nil {
 		return nil, errWithCode
@@ -50,12 +69,15 @@ func (p *ClaudeProvider) CreateChatCompletion(request *types.ChatCompletionReque
 		return nil, errWithCode
 	}
 
-	return ConvertToChatOpenai(p, claudeResponse, request)
+	return claudeResponse, nil
 }
 
 func (p *ClaudeProvider) CreateChatCompletionStream(request *types.ChatCompletionRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
 	request.OneOtherArg = p.GetOtherArg()
-	claudeRequest, errWithCode := ConvertFromChatOpenai(request)
+	if errWithCode := p.applyZeroMaxTokens(&request.MaxTokens, request.Model); errWithCode != nil {
+		return nil, errWithCode
+	}
+	claudeRequest, errWithCode := convertFromChatOpenai(p.getRequestContext(), request)
 	if errWithCode != nil {
 		return nil, errWithCode
 	}
@@ -89,15 +111,33 @@ func (p *ClaudeProvider) getChatRequest(claudeRequest *ClaudeRequest) (*http.Req
 		return nil, errWithCode
 	}
 
+	if errWithCode = p.applyStopSequences(claudeRequest); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	if errWithCode = checkCodeExecutionTool(claudeRequest); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	if errWithCode = p.applyMaxImages(claudeRequest); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	p.applySamplingParams(claudeRequest)
+
 	// 获取请求地址
 	fullRequestURL := p.GetFullRequestURL(url)
 	if fullRequestURL == "" {
 		return nil, common.ErrorWrapperLocal(nil, "invalid_claude_config", http.StatusInternalServerError)
 	}
 
+	// 请求体始终是 JSON，不沿用客户端的 Content-Type/Accept，避免网关据此返回非 JSON 的响应
 	headers := p.GetRequestHeaders()
+	headers["Content-Type"] = "application/json"
 	if claudeRequest.Stream {
 		headers["Accept"] = "text/event-stream"
+	} else {
+		headers["Accept"] = "application/json"
 	}
 
 	if strings.HasPrefix(claudeRequest.Model, "claude-3-5-sonnet") {
@@ -108,6 +148,17 @@ func (p *ClaudeProvider) getChatRequest(claudeRequest *ClaudeRequest) (*http.Req
 		headers["anthropic-beta"] = "output-128k-2025-02-19"
 	}
 
+	if hasCodeExecutionTool(claudeRequest) {
+		addAnthropicBeta(headers, CodeExecutionBeta)
+	}
+
+	p.addContext1MBeta(headers, claudeRequest)
+
+	if claudeRequest.DisablePromptCache {
+		removeAnthropicBeta(headers, PromptCachingBetaPrefix)
+	}
+	p.lastRequest = claudeRequest
+
 	// 创建请求
 	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(claudeRequest), p.Requester.WithHeader(headers))
 	if err != nil {
@@ -118,6 +169,21 @@ func (p *ClaudeProvider) getChatRequest(claudeRequest *ClaudeRequest) (*http.Req
 }
 
 func ConvertFromChatOpenai(request *types.ChatCompletionRequest) (*ClaudeRequest, *types.OpenAIErrorWithStatusCode) {
+	return convertFromChatOpenai(context.Background(), request)
+}
+
+// convertFromChatOpenai ctx 用于在日志中记录请求 ID
+func convertFromChatOpenai(ctx context.Context, request *types.ChatCompletionRequest) (*ClaudeRequest, *types.OpenAIErrorWithStatusCode) {
+	request = request.NormalizeLegacyFields()
+	if errWithCode := validateChatMessages(request.Messages); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// Claude 每次只生成一个结果，流式请求无法合并多个结果
+	if request.Stream && request.N != nil && *request.N > 1 && config.ClaudeSettingsInstance.RejectStreamMultipleChoices {
+		return nil, common.StringErrorWrapperLocal("streaming with n>1 is not supported on Claude channels", "n_not_supported", http.StatusBadRequest)
+	}
+
 	claudeRequest := ClaudeRequest{
 		Model:         request.Model,
 		Messages:      make([]Message, 0),
@@ -142,6 +208,7 @@ func ConvertFromChatOpenai(request *types.ChatCompletionRequest) (*ClaudeRequest
 
 	// 处理 system 字段（支持 cache_control）
 	systemMessage := ""
+	developerMessage := ""
 	mgsLen := len(request.Messages) - 1
 	isThink := (request.OneOtherArg == "thinking" || request.Reasoning != nil)
 
@@ -157,14 +224,18 @@ func ConvertFromChatOpenai(request *types.ChatCompletionRequest) (*ClaudeRequest
 
 	// 处理 messages
 	for index, msg := range request.Messages {
-		if isThink && index == mgsLen && (msg.Role == types.ChatMessageRoleAssistant || msg.Role == types.ChatMessageRoleSystem) {
+		if isThink && index == mgsLen && (msg.Role == types.ChatMessageRoleAssistant || msg.IsSystemRole()) {
 			msg.Role = types.ChatMessageRoleUser
 		}
 
-		if msg.Role == types.ChatMessageRoleSystem {
+		if msg.IsSystemRole() {
 			// 如果没有预设的 system 字段，从 messages 中提取
 			if request.System == nil {
-				systemMessage += msg.StringContent()
+				if msg.Role == types.ChatMessageRoleDeveloper {
+					developerMessage += msg.StringContent()
+				} else {
+					systemMessage += msg.StringContent()
+				}
 			}
 			continue
 		}
@@ -177,16 +248,39 @@ func ConvertFromChatOpenai(request *types.ChatCompletionRequest) (*ClaudeRequest
 		}
 	}
 
+	systemMessage = mergeDeveloperMessage(developerMessage, systemMessage)
+
 	// 如果没有预设的 system 字段，且从 messages 中提取到了 system message
 	if request.System == nil && systemMessage != "" {
 		claudeRequest.System = systemMessage
 	}
 
+	// Claude 至少需要一条非 system 的消息
+	if len(claudeRequest.Messages) == 0 {
+		return nil, common.StringErrorWrapperLocal("messages must contain at least one user message, system messages alone are not allowed", "invalid_request_error", http.StatusBadRequest)
+	}
+
+	if config.ClaudeSettingsInstance.ReorderToolResults {
+		messages, errWithCode := arrangeToolResults(ctx, claudeRequest.Messages)
+		if errWithCode != nil {
+			return nil, errWithCode
+		}
+		claudeRequest.Messages = messages
+	}
+
+	normalizeToolCallIds(claudeRequest.Messages)
+
 	for _, tool := range request.Tools {
+		// 代码执行工具原样透传，是否允许在发送请求时检查
+		if strings.HasPrefix(tool.Type, CodeExecutionToolPrefix) {
+			claudeRequest.Tools = append(claudeRequest.Tools, Tools{Type: tool.Type, Name: "code_execution"})
+			continue
+		}
+
 		tool := Tools{
 			Name:        tool.Function.Name,
 			Description: tool.Function.Description,
-			InputSchema: tool.Function.Parameters,
+			InputSchema: canonicalToolSchema(tool.Function.Parameters),
 		}
 		claudeRequest.Tools = append(claudeRequest.Tools, tool)
 	}
@@ -194,6 +288,9 @@ func ConvertFromChatOpenai(request *types.ChatCompletionRequest) (*ClaudeRequest
 	if request.ToolChoice != nil {
 		toolType, toolFunc := request.ParseToolChoice()
 		claudeRequest.ToolChoice = ConvertToolChoice(toolType, toolFunc)
+		if errWithCode := checkToolChoice(ctx, &claudeRequest); errWithCode != nil {
+			return nil, errWithCode
+		}
 	}
 
 	if claudeRequest.MaxTokens == 0 {
@@ -212,9 +309,86 @@ func ConvertFromChatOpenai(request *types.ChatCompletionRequest) (*ClaudeRequest
 		claudeRequest.TopP = nil
 	}
 
+	// 请求要求不使用缓存时，优先于全局的缓存配置
+	if request.DisablePromptCache {
+		claudeRequest.DisablePromptCache = true
+		StripCacheControl(&claudeRequest)
+		return &claudeRequest, nil
+	}
+
+	// 工具位于缓存前缀的最前面，先于 system 占用缓存断点
+	if config.ClaudeSettingsInstance.IsToolsCacheEnabled(claudeRequest.Model) {
+		cacheTools(&claudeRequest)
+	}
+
+	if system, ok := claudeRequest.System.(string); ok && config.ClaudeSettingsInstance.SystemPromptCache {
+		claudeRequest.System = cacheSystemPrompt(&claudeRequest, system)
+	}
+
 	return &claudeRequest, nil
 }
 
+// mergeDeveloperMessage developer 消息作为更高优先级的指令，按配置的先后顺序与 system 消息合并
+func mergeDeveloperMessage(developerMessage, systemMessage string) string {
+	if developerMessage == "" || systemMessage == "" {
+		return developerMessage + systemMessage
+	}
+
+	if config.ClaudeSettingsInstance.DeveloperRolePrecedence == config.SystemRoleFirst {
+		return systemMessage + "\n\n" + developerMessage
+	}
+
+	return developerMessage + "\n\n" + systemMessage
+}
+
+// validateChatMessages 在请求上游前校验消息，避免空消息导致上游 400
+func validateChatMessages(messages []types.ChatCompletionMessage) *types.OpenAIErrorWithStatusCode {
+	if len(messages) == 0 {
+		return common.StringErrorWrapperLocal("messages must not be empty", "invalid_request_error", http.StatusBadRequest)
+	}
+
+	for index, msg := range messages {
+		if isBlankMessage(&msg) {
+			return common.StringErrorWrapperLocal(fmt.Sprintf("messages[%d].content must not be empty or whitespace only", index), "invalid_request_error", http.StatusBadRequest)
+		}
+		if config.ClaudeSettingsInstance.RejectAudioInput && hasAudioInput(&msg) {
+			return common.StringErrorWrapperLocal(fmt.Sprintf("messages[%d] contains input_audio, audio input is not supported on Claude channels", index), "unsupported_modality", http.StatusBadRequest)
+		}
+	}
+
+	return nil
+}
+
+// hasAudioInput 判断消息是否包含音频内容，Claude 无法处理音频，忽略后会得到与请求无关的回答
+func hasAudioInput(msg *types.ChatCompletionMessage) bool {
+	if _, ok := msg.Content.(string); ok {
+		return false
+	}
+
+	for _, part := range msg.ParseContent() {
+		if part.Type == types.ContentTypeInputAudio {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBlankMessage 判断消息是否只包含空白文本（system、工具调用与工具结果不做判断）
+func isBlankMessage(msg *types.ChatCompletionMessage) bool {
+	if msg.IsSystemRole() || msg.ToolCalls != nil || msg.FunctionCall != nil || msg.Role == types.ChatMessageRoleTool || msg.Role == types.ChatMessageRoleFunction {
+		return false
+	}
+
+	for _, part := range msg.ParseContent() {
+		if part.Type != types.ContentTypeText || strings.TrimSpace(part.Text) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
 func getThinking(maxTokens int, reasoning *types.ChatReasoning) (newMaxtokens int, thinking *Thinking, err *types.OpenAIErrorWithStatusCode) {
 	newMaxtokens = maxTokens
 	thinking = &Thinking{
@@ -271,6 +445,23 @@ func ConvertToolChoice(toolType, toolFunc string) *ToolChoice {
 	return choice
 }
 
+// parseToolCallArguments 将 OpenAI 工具调用的 arguments 字符串解析为 tool_use 的 input 对象，空参数视为 {}
+func parseToolCallArguments(arguments string) (map[string]any, error) {
+	inputParam := make(map[string]any)
+	if strings.TrimSpace(arguments) == "" {
+		return inputParam, nil
+	}
+
+	if err := json.Unmarshal([]byte(arguments), &inputParam); err != nil {
+		return nil, err
+	}
+	if inputParam == nil {
+		inputParam = make(map[string]any)
+	}
+
+	return inputParam, nil
+}
+
 func convertMessageContent(msg *types.ChatCompletionMessage) (*Message, error) {
 	message := Message{
 		Role: convertRole(msg.Role),
@@ -279,10 +470,21 @@ func convertMessageContent(msg *types.ChatCompletionMessage) (*Message, error) {
 	content := make([]MessageContent, 0)
 
 	if msg.ToolCalls != nil {
+		// 重放历史时 assistant 消息中伴随工具调用的文本放在 tool_use 之前
+		if text := msg.StringContent(); strings.TrimSpace(text) != "" {
+			content = append(content, MessageContent{
+				Type: "text",
+				Text: text,
+			})
+		}
+
 		for _, toolCall := range msg.ToolCalls {
-			inputParam := make(map[string]any)
-			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &inputParam); err != nil {
-				return nil, err
+			if toolCall.Function == nil {
+				return nil, fmt.Errorf("tool call %s has no function", toolCall.Id)
+			}
+			inputParam, err := parseToolCallArguments(toolCall.Function.Arguments)
+			if err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool call %s: %s", toolCall.Id, err.Error())
 			}
 			content = append(content, MessageContent{
 				Type:  ContentTypeToolUes,
@@ -326,6 +528,11 @@ func convertMessageContent(msg *types.ChatCompletionMessage) (*Message, error) {
 			if err != nil {
 				return nil, common.ErrorWrapper(err, "image_url_invalid", http.StatusBadRequest)
 			}
+			// Claude 没有 detail 参数，low 时在编码前缩小图片以减少 token
+			mimeType, data, err = image.ResizeImageForDetail(mimeType, data, part.ImageURL.Detail)
+			if err != nil {
+				return nil, common.ErrorWrapper(err, "image_url_invalid", http.StatusBadRequest)
+			}
 			claudeType := "image"
 
 			if mimeType == "application/pdf" {
@@ -390,12 +597,18 @@ func ConvertToChatOpenai(provider base.ProviderInterface, response *ClaudeRespon
 			}
 			isThinking = true
 			thinkingContent = content.Thinking
+		case ContentTypeServerToolUse:
+			continue
 		default:
+			text := content.Text
+			if isCodeExecutionResult(content.Type) {
+				text = formatCodeExecutionResult(content.Content)
+			}
 			choice := types.ChatCompletionChoice{
 				Index: 0,
 				Message: types.ChatCompletionMessage{
 					Role:    response.Role,
-					Content: content.Text,
+					Content: text,
 				},
 				FinishReason: stopReasonClaude2OpenAI(response.StopReason),
 			}
@@ -421,25 +634,39 @@ func ConvertToChatOpenai(provider base.ProviderInterface, response *ClaudeRespon
 		})
 	}
 
+	// 与 OpenAI 一致，拒绝回答的内容放入 refusal 字段，content 置空
+	if response.StopReason == "refusal" && !config.ClaudeSettingsInstance.RefusalInContent {
+		for index := range choices {
+			if choices[index].Message.ToolCalls != nil {
+				continue
+			}
+			choices[index].Message.Refusal = choices[index].Message.StringContent()
+			choices[index].Message.Content = nil
+		}
+	}
+
 	openaiResponse = &types.ChatCompletionResponse{
 		ID:      response.Id,
 		Object:  "chat.completion",
 		Created: utils.GetTimestamp(),
 		Choices: choices,
-		Model:   request.Model,
+		Model:   getResponseModel(request.Model, response.Model),
 		Usage: &types.Usage{
 			CompletionTokens: 0,
 			PromptTokens:     0,
 			TotalTokens:      0,
 		},
+		SystemFingerprint: getSystemFingerprint(response.Model),
 	}
 
 	usage := provider.GetUsage()
 	isOk := ClaudeUsageToOpenaiUsage(&response.Usage, usage)
 	if !isOk {
+		setPromptTokens(&response.Usage, usage)
 		usage.CompletionTokens = ClaudeOutputUsage(response)
 		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	}
+	recordCodeExecutionUsage(response.Content, usage)
 
 	openaiResponse.Usage = usage
 
@@ -473,6 +700,7 @@ func (h *ClaudeStreamHandler) HandlerStream(rawLine *[]byte, dataChan chan strin
 	}
 
 	if claudeResponse.Type == "message_stop" {
+		h.sendMissingFinish(dataChan)
 		errChan <- io.EOF
 		*rawLine = requester.StreamClosed
 		return
@@ -480,18 +708,39 @@ func (h *ClaudeStreamHandler) HandlerStream(rawLine *[]byte, dataChan chan strin
 
 	switch claudeResponse.Type {
 	case "message_start":
+		h.UpstreamModel = claudeResponse.Message.Model
 		h.convertToOpenaiStream(&claudeResponse, dataChan)
-		h.Usage.PromptTokens = claudeResponse.Message.Usage.InputTokens
+		setPromptTokens(&claudeResponse.Message.Usage, h.Usage)
 
 	case "message_delta":
-		h.convertToOpenaiStream(&claudeResponse, dataChan)
+		// 中间的 message_delta 可能 stop_reason 为 null，只更新用量，结束原因只在最终的事件中发送一次
+		if claudeResponse.Delta.StopReason != "" && !h.finishSent {
+			h.convertToOpenaiStream(&claudeResponse, dataChan)
+		}
+		if hasInputUsage(&claudeResponse.Usage) {
+			setPromptTokens(&claudeResponse.Usage, h.Usage)
+		}
 		h.Usage.CompletionTokens = claudeResponse.Usage.OutputTokens
 		h.Usage.TotalTokens = h.Usage.PromptTokens + h.Usage.CompletionTokens
 
 	case "content_block_delta":
+		// 服务端工具（如代码执行）的输入由 Claude 自行执行，不转换为 tool_calls
+		if h.serverToolBlocks[claudeResponse.Index] {
+			return
+		}
 		h.convertToOpenaiStream(&claudeResponse, dataChan)
-		h.Usage.TextBuilder.WriteString(claudeResponse.Delta.Text)
+		writeDeltaText(&h.Usage.TextBuilder, &claudeResponse.Delta)
 	case "content_block_start":
+		if claudeResponse.ContentBlock.Type == ContentTypeServerToolUse {
+			if h.serverToolBlocks == nil {
+				h.serverToolBlocks = make(map[int]bool)
+			}
+			h.serverToolBlocks[claudeResponse.Index] = true
+			return
+		}
+		if isCodeExecutionResult(claudeResponse.ContentBlock.Type) {
+			h.Usage.IncExtraBilling(types.APITollTypeCodeExecution, "")
+		}
 		h.convertToOpenaiStream(&claudeResponse, dataChan)
 
 	default:
@@ -499,6 +748,20 @@ func (h *ClaudeStreamHandler) HandlerStream(rawLine *[]byte, dataChan chan strin
 	}
 }
 
+// sendMissingFinish 部分网关在 message_stop 前不发送带 stop_reason 的 message_delta，
+// 补发一个带 finish_reason 的块，保证 [DONE] 之前客户端能收到结束原因
+func (h *ClaudeStreamHandler) sendMissingFinish(dataChan chan string) {
+	if h.finishSent {
+		return
+	}
+
+	stopReason := "end_turn"
+	if h.toolUsed {
+		stopReason = "tool_use"
+	}
+	h.convertToOpenaiStream(&ClaudeStreamResponse{Delta: Delta{StopReason: stopReason}}, dataChan)
+}
+
 func (h *ClaudeStreamHandler) convertToOpenaiStream(claudeResponse *ClaudeStreamResponse, dataChan chan string) {
 	choice := types.ChatCompletionStreamChoice{
 		Index: claudeResponse.Index,
@@ -512,9 +775,14 @@ func (h *ClaudeStreamHandler) convertToOpenaiStream(claudeResponse *ClaudeStream
 		choice.Delta.Content = claudeResponse.ContentBlock.Text
 	}
 
+	if isCodeExecutionResult(claudeResponse.ContentBlock.Type) {
+		choice.Delta.Content = formatCodeExecutionResult(claudeResponse.ContentBlock.Content)
+	}
+
 	var toolCalls []*types.ChatCompletionToolCalls
 
 	if claudeResponse.ContentBlock.Type == ContentTypeToolUes {
+		h.toolUsed = true
 		toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
 			Id:   claudeResponse.ContentBlock.Id,
 			Type: types.ChatMessageRoleFunction,
@@ -565,13 +833,15 @@ func (h *ClaudeStreamHandler) convertToOpenaiStream(claudeResponse *ClaudeStream
 	finishReason := stopReasonClaude2OpenAI(claudeResponse.Delta.StopReason)
 	if finishReason != "" {
 		choice.FinishReason = &finishReason
+		h.finishSent = true
 	}
 	chatCompletion := types.ChatCompletionStreamResponse{
-		ID:      fmt.Sprintf("chatcmpl-%s", utils.GetUUID()),
-		Object:  "chat.completion.chunk",
-		Created: utils.GetTimestamp(),
-		Model:   h.Request.Model,
-		Choices: []types.ChatCompletionStreamChoice{choice},
+		ID:                fmt.Sprintf("chatcmpl-%s", utils.GetUUID()),
+		Object:            "chat.completion.chunk",
+		Created:           utils.GetTimestamp(),
+		Model:             getResponseModel(h.Request.Model, h.UpstreamModel),
+		Choices:           []types.ChatCompletionStreamChoice{choice},
+		SystemFingerprint: getSystemFingerprint(h.UpstreamModel),
 	}
 
 	responseBody, _ := json.Marshal(chatCompletion)
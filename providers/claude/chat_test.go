@@ -0,0 +1,304 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/test"
+	"one-api/providers"
+	"one-api/providers/claude"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Logger = zap.NewNop()
+}
+
+func TestConvertFromChatOpenaiEmptyMessages(t *testing.T) {
+	request := &types.ChatCompletionRequest{
+		Model:    "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{},
+	}
+
+	_, errWithCode := claude.ConvertFromChatOpenai(request)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.True(t, errWithCode.LocalError)
+	assert.Contains(t, errWithCode.Message, "must not be empty")
+}
+
+func TestConvertFromChatOpenaiWhitespaceMessage(t *testing.T) {
+	request := &types.ChatCompletionRequest{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hello"},
+			{Role: types.ChatMessageRoleAssistant, Content: "  \n\t"},
+		},
+	}
+
+	_, errWithCode := claude.ConvertFromChatOpenai(request)
+	assert.NotNil(t, errWithCode)
+	assert.Contains(t, errWithCode.Message, "messages[1]")
+}
+
+func TestConvertFromChatOpenaiSystemOnly(t *testing.T) {
+	request := &types.ChatCompletionRequest{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleSystem, Content: "You are a helpful assistant."},
+		},
+	}
+
+	_, errWithCode := claude.ConvertFromChatOpenai(request)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Contains(t, errWithCode.Message, "at least one user message")
+}
+
+func TestConvertFromChatOpenaiValidMessages(t *testing.T) {
+	request := &types.ChatCompletionRequest{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleSystem, Content: "You are a helpful assistant."},
+			{Role: types.ChatMessageRoleUser, Content: "Hello!"},
+		},
+	}
+
+	claudeRequest, errWithCode := claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "You are a helpful assistant.", claudeRequest.System)
+	assert.Len(t, claudeRequest.Messages, 1)
+}
+
+func TestConvertFromChatOpenaiDuplicateToolCallIds(t *testing.T) {
+	toolCall := func(id string) []*types.ChatCompletionToolCalls {
+		return []*types.ChatCompletionToolCalls{
+			{Id: id, Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "search", Arguments: `{}`}},
+		}
+	}
+	request := &types.ChatCompletionRequest{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "search twice"},
+			{Role: types.ChatMessageRoleAssistant, ToolCalls: toolCall("call_1")},
+			{Role: types.ChatMessageRoleTool, ToolCallID: "call_1", Content: "first result"},
+			{Role: types.ChatMessageRoleAssistant, ToolCalls: toolCall("call_1")},
+			{Role: types.ChatMessageRoleTool, ToolCallID: "call_1", Content: "second result"},
+		},
+	}
+
+	claudeRequest, errWithCode := claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+
+	contentOf := func(index int) claude.MessageContent {
+		return claudeRequest.Messages[index].Content.([]claude.MessageContent)[0]
+	}
+
+	assert.Equal(t, "call_1", contentOf(1).Id)
+	assert.Equal(t, "call_1", contentOf(2).ToolUseId)
+	assert.Equal(t, "first result", contentOf(2).Content)
+
+	assert.Equal(t, "call_1_1", contentOf(3).Id)
+	assert.Equal(t, "call_1_1", contentOf(4).ToolUseId)
+	assert.Equal(t, "second result", contentOf(4).Content)
+}
+
+func TestConvertToChatOpenaiResponseModel(t *testing.T) {
+	defer func() {
+		config.ClaudeSettingsInstance.ResponseModel = config.ResponseModelAdvertised
+		config.ClaudeSettingsInstance.SystemFingerprint = false
+	}()
+
+	context, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	channel := test.GetChannel(config.ChannelTypeAnthropic, "", "", "", "")
+	provider := providers.GetProvider(&channel, context)
+	request := &types.ChatCompletionRequest{Model: "claude-sonnet"}
+	response := &claude.ClaudeResponse{
+		Id:      "msg_1",
+		Role:    types.ChatMessageRoleAssistant,
+		Model:   "claude-3-5-sonnet-20241022",
+		Content: []claude.ResContent{{Type: "text", Text: "hi"}},
+		Usage:   claude.Usage{InputTokens: 5, OutputTokens: 1},
+	}
+
+	provider.SetUsage(&types.Usage{})
+	openaiResponse, errWithCode := claude.ConvertToChatOpenai(provider, response, request)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "claude-sonnet", openaiResponse.Model)
+	assert.Empty(t, openaiResponse.SystemFingerprint)
+
+	config.ClaudeSettingsInstance.ResponseModel = config.ResponseModelUpstream
+	config.ClaudeSettingsInstance.SystemFingerprint = true
+	provider.SetUsage(&types.Usage{})
+	openaiResponse, errWithCode = claude.ConvertToChatOpenai(provider, response, request)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "claude-3-5-sonnet-20241022", openaiResponse.Model)
+	assert.True(t, strings.HasPrefix(openaiResponse.SystemFingerprint, "fp_"))
+
+	// 模型版本变化时指纹随之变化
+	fingerprint := openaiResponse.SystemFingerprint
+	response.Model = "claude-3-5-sonnet-20250101"
+	provider.SetUsage(&types.Usage{})
+	openaiResponse, _ = claude.ConvertToChatOpenai(provider, response, request)
+	assert.NotEqual(t, fingerprint, openaiResponse.SystemFingerprint)
+}
+
+func TestClaudeStreamHandlerResponseModel(t *testing.T) {
+	config.ClaudeSettingsInstance.ResponseModel = config.ResponseModelUpstream
+	config.ClaudeSettingsInstance.SystemFingerprint = true
+	defer func() {
+		config.ClaudeSettingsInstance.ResponseModel = config.ResponseModelAdvertised
+		config.ClaudeSettingsInstance.SystemFingerprint = false
+	}()
+
+	handler := &claude.ClaudeStreamHandler{
+		Usage:   &types.Usage{},
+		Request: &types.ChatCompletionRequest{Model: "claude-sonnet"},
+		Prefix:  `data: {`,
+	}
+	dataChan := make(chan string, 10)
+	errChan := make(chan error, 10)
+
+	line := []byte(`data: {"type":"message_start","message":{"id":"msg_1","role":"assistant","model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":5}}}`)
+	handler.HandlerStream(&line, dataChan, errChan)
+
+	var chunk types.ChatCompletionStreamResponse
+	assert.Nil(t, json.Unmarshal([]byte(<-dataChan), &chunk))
+	assert.Equal(t, "claude-3-5-sonnet-20241022", chunk.Model)
+	assert.True(t, strings.HasPrefix(chunk.SystemFingerprint, "fp_"))
+}
+
+func TestConvertToChatOpenaiRefusal(t *testing.T) {
+	defer func() { config.ClaudeSettingsInstance.RefusalInContent = false }()
+
+	context, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	channel := test.GetChannel(config.ChannelTypeAnthropic, "", "", "", "")
+	provider := providers.GetProvider(&channel, context)
+	request := &types.ChatCompletionRequest{Model: "claude-sonnet"}
+	response := &claude.ClaudeResponse{
+		Id:         "msg_1",
+		Role:       types.ChatMessageRoleAssistant,
+		Model:      "claude-3-5-sonnet-20241022",
+		StopReason: "refusal",
+		Content:    []claude.ResContent{{Type: "text", Text: "I can't help with that."}},
+		Usage:      claude.Usage{InputTokens: 5, OutputTokens: 6},
+	}
+
+	provider.SetUsage(&types.Usage{})
+	openaiResponse, errWithCode := claude.ConvertToChatOpenai(provider, response, request)
+	assert.Nil(t, errWithCode)
+	message := openaiResponse.Choices[0].Message
+	assert.Equal(t, "I can't help with that.", message.Refusal)
+	assert.Nil(t, message.Content)
+	assert.Equal(t, types.FinishReasonContentFilter, openaiResponse.Choices[0].FinishReason)
+
+	// 兼容模式：拒绝内容仍放在 content 中
+	config.ClaudeSettingsInstance.RefusalInContent = true
+	provider.SetUsage(&types.Usage{})
+	openaiResponse, errWithCode = claude.ConvertToChatOpenai(provider, response, request)
+	assert.Nil(t, errWithCode)
+	message = openaiResponse.Choices[0].Message
+	assert.Equal(t, "I can't help with that.", message.Content)
+	assert.Empty(t, message.Refusal)
+}
+
+func TestConvertFromChatOpenaiDeveloperRole(t *testing.T) {
+	defer func() { config.ClaudeSettingsInstance.DeveloperRolePrecedence = config.DeveloperRoleFirst }()
+
+	request := &types.ChatCompletionRequest{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleSystem, Content: "You are helpful."},
+			{Role: types.ChatMessageRoleDeveloper, Content: "Always answer in French."},
+			{Role: types.ChatMessageRoleUser, Content: "Hello"},
+		},
+	}
+
+	claudeRequest, errWithCode := claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "Always answer in French.\n\nYou are helpful.", claudeRequest.System)
+	assert.Len(t, claudeRequest.Messages, 1)
+	assert.Equal(t, types.ChatMessageRoleUser, claudeRequest.Messages[0].Role)
+
+	config.ClaudeSettingsInstance.DeveloperRolePrecedence = config.SystemRoleFirst
+	claudeRequest, errWithCode = claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "You are helpful.\n\nAlways answer in French.", claudeRequest.System)
+
+	// 只有 developer 消息
+	request.Messages = request.Messages[1:]
+	claudeRequest, errWithCode = claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "Always answer in French.", claudeRequest.System)
+}
+
+func TestConvertFromChatOpenaiLegacyFunctions(t *testing.T) {
+	legacyBody := `{"model":"claude-3-5-sonnet-20241022","max_completion_tokens":200,
+		"functions":[{"name":"get_weather","description":"weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}],
+		"function_call":{"name":"get_weather"},
+		"messages":[
+			{"role":"user","content":"weather in Paris?"},
+			{"role":"assistant","function_call":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+			{"role":"function","name":"get_weather","content":"sunny"}
+		]}`
+	currentBody := `{"model":"claude-3-5-sonnet-20241022","max_tokens":200,
+		"tools":[{"type":"function","function":{"name":"get_weather","description":"weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}],
+		"tool_choice":{"type":"function","function":{"name":"get_weather"}},
+		"messages":[
+			{"role":"user","content":"weather in Paris?"},
+			{"role":"assistant","tool_calls":[{"id":"get_weather","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+			{"role":"tool","tool_call_id":"get_weather","content":"sunny"}
+		]}`
+
+	var legacy, current types.ChatCompletionRequest
+	assert.Nil(t, json.Unmarshal([]byte(legacyBody), &legacy))
+	assert.Nil(t, json.Unmarshal([]byte(currentBody), &current))
+
+	legacyRequest, errWithCode := claude.ConvertFromChatOpenai(&legacy)
+	assert.Nil(t, errWithCode)
+	currentRequest, errWithCode := claude.ConvertFromChatOpenai(&current)
+	assert.Nil(t, errWithCode)
+
+	assert.Equal(t, currentRequest, legacyRequest)
+	assert.Equal(t, 200, legacyRequest.MaxTokens)
+	assert.Equal(t, "tool", legacyRequest.ToolChoice.Type)
+	assert.Equal(t, "get_weather", legacyRequest.ToolChoice.Name)
+
+	// 原请求保持旧版字段，响应仍按 function_call 格式返回
+	assert.Len(t, legacy.Functions, 1)
+	assert.Nil(t, legacy.Messages[1].ToolCalls)
+	assert.Equal(t, types.ChatMessageRoleFunction, legacy.Messages[2].Role)
+}
+
+func TestConvertFromChatOpenaiStreamMultipleChoices(t *testing.T) {
+	n := 2
+	request := &types.ChatCompletionRequest{
+		Model:    "claude-3-5-sonnet-20241022",
+		Stream:   true,
+		N:        &n,
+		Messages: []types.ChatCompletionMessage{{Role: types.ChatMessageRoleUser, Content: "hi"}},
+	}
+
+	_, errWithCode := claude.ConvertFromChatOpenai(request)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Equal(t, "n_not_supported", errWithCode.Code)
+
+	// 非流式请求不受影响
+	request.Stream = false
+	_, errWithCode = claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+
+	// 关闭后忽略 n
+	config.ClaudeSettingsInstance.RejectStreamMultipleChoices = false
+	defer func() { config.ClaudeSettingsInstance.RejectStreamMultipleChoices = true }()
+	request.Stream = true
+	_, errWithCode = claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+}
@@ -0,0 +1,103 @@
+package claude
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/types"
+	"strings"
+)
+
+const (
+	ContentTypeServerToolUse               = "server_tool_use"
+	ContentTypeCodeExecutionToolResult     = "code_execution_tool_result"
+	ContentTypeBashCodeExecutionToolResult = "bash_code_execution_tool_result"
+
+	CodeExecutionToolPrefix = "code_execution_"
+	CodeExecutionBeta       = "code-execution-2025-05-22"
+)
+
+// hasCodeExecutionTool 判断请求中是否包含代码执行（容器）工具
+func hasCodeExecutionTool(request *ClaudeRequest) bool {
+	for _, tool := range request.Tools {
+		if strings.HasPrefix(tool.Type, CodeExecutionToolPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkCodeExecutionTool 代码执行工具存在费用和安全风险，未开启时拒绝请求
+func checkCodeExecutionTool(request *ClaudeRequest) *types.OpenAIErrorWithStatusCode {
+	if !hasCodeExecutionTool(request) || config.ClaudeSettingsInstance.CodeExecution {
+		return nil
+	}
+
+	return common.StringErrorWrapperLocal("code execution tool is not enabled", "code_execution_disabled", http.StatusBadRequest)
+}
+
+// addAnthropicBeta 追加 anthropic-beta 请求头，已有的值以逗号分隔保留
+func addAnthropicBeta(headers map[string]string, beta string) {
+	if headers["anthropic-beta"] == "" {
+		headers["anthropic-beta"] = beta
+		return
+	}
+
+	if strings.Contains(headers["anthropic-beta"], beta) {
+		return
+	}
+
+	headers["anthropic-beta"] += "," + beta
+}
+
+func isCodeExecutionResult(contentType string) bool {
+	return contentType == ContentTypeCodeExecutionToolResult || contentType == ContentTypeBashCodeExecutionToolResult
+}
+
+// recordCodeExecutionUsage 按代码执行次数记录容器的额外计费
+func recordCodeExecutionUsage(contents []ResContent, usage *types.Usage) {
+	if usage == nil {
+		return
+	}
+
+	for _, content := range contents {
+		if isCodeExecutionResult(content.Type) {
+			usage.IncExtraBilling(types.APITollTypeCodeExecution, "")
+		}
+	}
+}
+
+// formatCodeExecutionResult 将代码执行结果转换为文本，用于 OpenAI 格式的响应
+func formatCodeExecutionResult(result any) string {
+	resultMap, ok := result.(map[string]any)
+	if !ok {
+		return ""
+	}
+
+	if errorCode, ok := resultMap["error_code"].(string); ok {
+		return fmt.Sprintf("\n```\ncode execution error: %s\n```\n", errorCode)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("\n```\n")
+	if stdout, _ := resultMap["stdout"].(string); stdout != "" {
+		builder.WriteString(stdout)
+		if !strings.HasSuffix(stdout, "\n") {
+			builder.WriteString("\n")
+		}
+	}
+	if stderr, _ := resultMap["stderr"].(string); stderr != "" {
+		builder.WriteString("stderr:\n" + stderr)
+		if !strings.HasSuffix(stderr, "\n") {
+			builder.WriteString("\n")
+		}
+	}
+	if returnCode, ok := resultMap["return_code"].(float64); ok && returnCode != 0 {
+		builder.WriteString(fmt.Sprintf("return code: %d\n", int(returnCode)))
+	}
+	builder.WriteString("```\n")
+
+	return builder.String()
+}
@@ -0,0 +1,98 @@
+package claude_test
+
+import (
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const codeExecutionResponseFixture = `{
+	"id": "msg_1",
+	"type": "message",
+	"role": "assistant",
+	"model": "claude-sonnet-4-20250514",
+	"stop_reason": "end_turn",
+	"content": [
+		{"type": "text", "text": "Let me calculate that."},
+		{"type": "server_tool_use", "id": "srvtoolu_1", "name": "code_execution", "input": {"code": "print(2 ** 10)"}},
+		{"type": "code_execution_tool_result", "tool_use_id": "srvtoolu_1", "content": {"type": "code_execution_result", "stdout": "1024\n", "stderr": "", "return_code": 0}},
+		{"type": "text", "text": "The result is 1024."}
+	],
+	"container": {"id": "container_1", "expires_at": "2025-06-01T00:00:00Z"},
+	"usage": {"input_tokens": 20, "output_tokens": 30}
+}`
+
+func setupCodeExecutionTestServer(t *testing.T) (chatProvider providers_base.ChatInterface, teardown func()) {
+	requester.InitHttpClient()
+
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("anthropic-beta"), "code-execution-2025-05-22")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(codeExecutionResponseFixture))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	context, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider, _ = providers.GetProvider(&channel, context).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	return chatProvider, ts.Close
+}
+
+func getCodeExecutionRequest() *types.ChatCompletionRequest {
+	return &types.ChatCompletionRequest{
+		Model:     "claude-sonnet-4-20250514",
+		MaxTokens: 100,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "what is 2 ** 10?"},
+		},
+		Tools: []*types.ChatCompletionTool{
+			{Type: "code_execution_20250522"},
+		},
+	}
+}
+
+func TestCodeExecutionDisabled(t *testing.T) {
+	chatProvider, teardown := setupCodeExecutionTestServer(t)
+	defer teardown()
+
+	_, errWithCode := chatProvider.CreateChatCompletion(getCodeExecutionRequest())
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, "code_execution_disabled", errWithCode.Code)
+	assert.True(t, errWithCode.LocalError)
+}
+
+func TestCodeExecutionResponse(t *testing.T) {
+	config.ClaudeSettingsInstance.CodeExecution = true
+	defer func() { config.ClaudeSettingsInstance.CodeExecution = false }()
+
+	chatProvider, teardown := setupCodeExecutionTestServer(t)
+	defer teardown()
+
+	response, errWithCode := chatProvider.CreateChatCompletion(getCodeExecutionRequest())
+	assert.Nil(t, errWithCode)
+
+	// 代码执行的输出作为文本返回，server_tool_use 不转换为 tool_calls
+	contents := make([]string, 0)
+	for _, choice := range response.Choices {
+		assert.Nil(t, choice.Message.ToolCalls)
+		contents = append(contents, choice.Message.StringContent())
+	}
+	output := strings.Join(contents, "")
+	assert.Contains(t, output, "1024\n```")
+	assert.Contains(t, output, "The result is 1024.")
+
+	usage := chatProvider.GetUsage()
+	assert.Equal(t, 1, usage.ExtraBilling[types.APITollTypeCodeExecution].CallCount)
+}
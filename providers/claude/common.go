@@ -94,6 +94,26 @@ func ClaudeUsageToOpenaiUsage(cUsage *Usage, usage *types.Usage) bool {
 	return true
 }
 
+// setPromptTokens 部分网关在响应（流式为 message_start）中不返回 input_tokens，
+// 此时保留请求前本地估算的提示 token 用于计费，并标记为估算。
+// 提示全部命中缓存时 input_tokens 为 0，但缓存字段有值，这是真实用量，不是缺失
+func setPromptTokens(cUsage *Usage, usage *types.Usage) {
+	if !hasInputUsage(cUsage) {
+		usage.PromptEstimated = true
+		return
+	}
+
+	usage.PromptTokens = cUsage.InputTokens
+	usage.PromptTokensDetails.CachedWriteTokens = cUsage.CacheCreationInputTokens
+	usage.PromptTokensDetails.CachedReadTokens = cUsage.CacheReadInputTokens
+	usage.PromptEstimated = false
+}
+
+// hasInputUsage 上游是否返回了输入用量（未缓存的输入或缓存的读写）
+func hasInputUsage(cUsage *Usage) bool {
+	return cUsage.InputTokens > 0 || cUsage.CacheCreationInputTokens > 0 || cUsage.CacheReadInputTokens > 0
+}
+
 func ClaudeOutputUsage(response *ClaudeResponse) int {
 	var textMsg strings.Builder
 
@@ -105,3 +125,10 @@ func ClaudeOutputUsage(response *ClaudeResponse) int {
 
 	return common.CountTokenText(textMsg.String(), response.Model)
 }
+
+// writeDeltaText 记录流式输出的文本、思考和工具参数，上游未返回最终用量时用于估算补全 token
+func writeDeltaText(builder *strings.Builder, delta *Delta) {
+	builder.WriteString(delta.Text)
+	builder.WriteString(delta.Thinking)
+	builder.WriteString(delta.PartialJson)
+}
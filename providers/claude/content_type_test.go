@@ -0,0 +1,47 @@
+package claude_test
+
+import (
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatCompletionTextPlainJSON(t *testing.T) {
+	requester.InitHttpClient()
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	context, _ := test.GetContext("POST", "/v1/chat/completions", map[string]string{
+		"Content-Type": "text/plain",
+		"Accept":       "*/*",
+	}, nil)
+	chatProvider, _ := providers.GetProvider(&channel, context).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	response, errWithCode := chatProvider.CreateChatCompletion(&types.ChatCompletionRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+	})
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "Hello", response.Choices[0].Message.Content)
+}
@@ -0,0 +1,45 @@
+package claude
+
+import (
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"strings"
+)
+
+const (
+	Context1MBeta = "context-1m-2025-08-07"
+	// StandardContextTokens 未开启 1M 上下文时的标准上下文长度
+	StandardContextTokens = 200000
+)
+
+// getContextWindow 获取模型的上下文长度，渠道配置优先，用于兼容自定义上下文长度的网关
+func (p *ClaudeProvider) getContextWindow(modelName string) int {
+	if p.Channel != nil {
+		if contextWindow := p.Channel.GetContextWindow(modelName); contextWindow > 0 {
+			return contextWindow
+		}
+	}
+
+	return StandardContextTokens
+}
+
+// addContext1MBeta 支持 1M 上下文的模型在提示 token 超出标准上下文时自动添加 context-1m beta 请求头，
+// 客户端已在请求头中声明时直接沿用；未配置的模型不使用 1M 上下文，客户端的声明也不转发
+func (p *ClaudeProvider) addContext1MBeta(headers map[string]string, request *ClaudeRequest) {
+	if strings.Contains(headers["anthropic-beta"], Context1MBeta) || !config.ClaudeSettingsInstance.IsContext1MModel(request.Model) {
+		return
+	}
+
+	if p.Context != nil && strings.Contains(p.Context.Request.Header.Get("anthropic-beta"), Context1MBeta) {
+		addAnthropicBeta(headers, Context1MBeta)
+		return
+	}
+
+	if p.Usage == nil || p.Usage.PromptTokens <= p.getContextWindow(request.Model) {
+		return
+	}
+
+	addAnthropicBeta(headers, Context1MBeta)
+	logger.LogInfo(p.getRequestContext(), fmt.Sprintf("claude request for %s has %d prompt tokens, added anthropic-beta %s", request.Model, p.Usage.PromptTokens, Context1MBeta))
+}
@@ -0,0 +1,104 @@
+package claude_test
+
+import (
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/providers/claude"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getContext1MBetaHeader(t *testing.T, modelName string, promptTokens int, clientBeta string) string {
+	return getChannelContext1MBetaHeader(t, "", modelName, promptTokens, clientBeta)
+}
+
+// getChannelContext1MBetaHeader 渠道配置了 context_windows 时获取上游收到的 anthropic-beta
+func getChannelContext1MBetaHeader(t *testing.T, contextWindows string, modelName string, promptTokens int, clientBeta string) string {
+	requester.InitHttpClient()
+	var betaHeader string
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		betaHeader = r.Header.Get("anthropic-beta")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"` + modelName + `","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	headers := test.RequestJSONConfig()
+	if clientBeta != "" {
+		headers["anthropic-beta"] = clientBeta
+	}
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	channel.ContextWindows = &contextWindows
+	ctx, _ := test.GetContext("POST", "/v1/chat/completions", headers, nil)
+	chatProvider, _ := providers.GetProvider(&channel, ctx).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{PromptTokens: promptTokens})
+
+	_, errWithCode := chatProvider.CreateChatCompletion(&types.ChatCompletionRequest{
+		Model:     modelName,
+		MaxTokens: 100,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+	})
+	assert.Nil(t, errWithCode)
+
+	return betaHeader
+}
+
+func TestContext1MBeta(t *testing.T) {
+	config.ClaudeSettingsInstance.Context1MModels = "claude-sonnet-4, claude-opus-4-1"
+	defer func() { config.ClaudeSettingsInstance.Context1MModels = "claude-sonnet-4" }()
+
+	// 配置的模型超出标准上下文时自动添加
+	assert.Equal(t, claude.Context1MBeta, getContext1MBetaHeader(t, "claude-sonnet-4-20250514", 250000, ""))
+	assert.Equal(t, claude.Context1MBeta, getContext1MBetaHeader(t, "claude-opus-4-1-20250805", 250000, ""))
+
+	// 未超出标准上下文
+	assert.Empty(t, getContext1MBetaHeader(t, "claude-sonnet-4-20250514", 1000, ""))
+
+	// 未配置的模型
+	assert.Empty(t, getContext1MBetaHeader(t, "claude-3-haiku-20240307", 250000, ""))
+
+	// 客户端已声明时沿用，不重复添加
+	assert.Equal(t, claude.Context1MBeta, getContext1MBetaHeader(t, "claude-sonnet-4-20250514", 1000, claude.Context1MBeta))
+
+	// 未配置的模型不转发客户端的声明
+	assert.Empty(t, getContext1MBetaHeader(t, "claude-3-haiku-20240307", 1000, claude.Context1MBeta))
+}
+
+func TestContext1MBetaChannelContextWindow(t *testing.T) {
+	contextWindows := `{"claude-sonnet-4-20250514":100000}`
+
+	// 渠道的上下文更小时直接按渠道上下文判断
+	assert.Empty(t, getChannelContext1MBetaHeader(t, contextWindows, "claude-sonnet-4-20250514", 90000, ""))
+	assert.Equal(t, claude.Context1MBeta, getChannelContext1MBetaHeader(t, contextWindows, "claude-sonnet-4-20250514", 150000, ""))
+
+	// 渠道的上下文更大，未超出时不添加
+	largeWindows := `{"claude-sonnet-4-20250514":500000}`
+	assert.Empty(t, getChannelContext1MBetaHeader(t, largeWindows, "claude-sonnet-4-20250514", 250000, ""))
+	assert.Equal(t, claude.Context1MBeta, getChannelContext1MBetaHeader(t, largeWindows, "claude-sonnet-4-20250514", 600000, ""))
+
+	// 未配置的模型使用标准上下文
+	assert.Empty(t, getChannelContext1MBetaHeader(t, largeWindows, "claude-sonnet-4-5-20250929", 150000, ""))
+	assert.Equal(t, claude.Context1MBeta, getChannelContext1MBetaHeader(t, largeWindows, "claude-sonnet-4-5-20250929", 250000, ""))
+}
+
+func TestContext1MBetaIgnoresModelInfoContextLength(t *testing.T) {
+	model.PricingInstance = &model.Pricing{Prices: map[string]*model.Price{
+		"claude-sonnet-4-20250514": {Model: "claude-sonnet-4-20250514", ModelInfo: &model.ModelInfoResponse{ContextLength: 1000000}},
+	}}
+	defer func() { model.PricingInstance = nil }()
+
+	// 模型信息中的 1M 上下文长度不影响判断，超出标准上下文时仍需添加
+	assert.Equal(t, claude.Context1MBeta, getContext1MBetaHeader(t, "claude-sonnet-4-20250514", 250000, ""))
+}
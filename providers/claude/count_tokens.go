@@ -0,0 +1,63 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"one-api/common/utils"
+)
+
+const countTokensURL = "/v1/messages/count_tokens"
+
+type countTokensRequest struct {
+	Model      string      `json:"model"`
+	System     any         `json:"system,omitempty"`
+	Messages   []Message   `json:"messages"`
+	Tools      []Tools     `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+	Thinking   *Thinking   `json:"thinking,omitempty"`
+	McpServers any         `json:"mcp_servers,omitempty"`
+}
+
+type countTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+// CountPromptTokens 通过 count_tokens 接口计算最近一次请求的提示 token
+func (p *ClaudeProvider) CountPromptTokens(ctx context.Context) (int, error) {
+	if p.lastRequest == nil {
+		return 0, errors.New("no request to count")
+	}
+
+	headers := p.GetRequestHeaders()
+	headers["Content-Type"] = "application/json"
+	headers["Accept"] = "application/json"
+
+	body := &countTokensRequest{
+		Model:      p.lastRequest.Model,
+		System:     p.lastRequest.System,
+		Messages:   p.lastRequest.Messages,
+		Tools:      p.lastRequest.Tools,
+		ToolChoice: p.lastRequest.ToolChoice,
+		Thinking:   p.lastRequest.Thinking,
+		McpServers: p.lastRequest.McpServers,
+	}
+	req, err := p.Requester.NewRequest(http.MethodPost, p.GetFullRequestURL(countTokensURL), p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(utils.SetProxy(*p.Channel.Proxy, ctx))
+	defer req.Body.Close()
+
+	// 不覆盖对话请求记录的上游请求 ID
+	onResponse := p.Requester.OnResponse
+	p.Requester.OnResponse = nil
+	defer func() { p.Requester.OnResponse = onResponse }()
+
+	response := &countTokensResponse{}
+	if _, errWithCode := p.Requester.SendRequest(req, response, false); errWithCode != nil {
+		return 0, errors.New(errWithCode.Message)
+	}
+
+	return response.InputTokens, nil
+}
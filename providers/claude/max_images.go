@@ -0,0 +1,160 @@
+package claude
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/types"
+)
+
+// 截断时替换被移除图片的文字，保证消息内容不为空
+const imageOmittedText = "[image omitted: too many images in the request]"
+
+// getMaxImages 获取单次请求的图片数量上限，渠道配置优先于全局的模型配置
+func (p *ClaudeProvider) getMaxImages(model string) int {
+	if p.Channel != nil && p.Channel.MaxImages > 0 {
+		return p.Channel.MaxImages
+	}
+
+	return config.ClaudeSettingsInstance.GetMaxImages(model)
+}
+
+// applyMaxImages 在请求上游之前检查图片数量，并按配置处理超出上限的情况
+func (p *ClaudeProvider) applyMaxImages(claudeRequest *ClaudeRequest) *types.OpenAIErrorWithStatusCode {
+	limit := p.getMaxImages(claudeRequest.Model)
+	omitted, err := LimitImages(claudeRequest.Messages, limit, config.ClaudeSettingsInstance.MaxImagesOverflow)
+	if err != nil {
+		return common.ErrorWrapperLocal(err, "too_many_images", http.StatusBadRequest)
+	}
+
+	if omitted > 0 && p.Context != nil {
+		logger.LogWarn(p.Context.Request.Context(), fmt.Sprintf("images exceed the limit of %d, omitted %d earlier images", limit, omitted))
+	}
+
+	return nil
+}
+
+// LimitImages 统计消息中的图片数量，超出 limit 时 error 策略返回错误
+// truncate 策略保留最近的 limit 张图片，较早的图片替换为文字说明，返回被移除的数量
+func LimitImages(messages []Message, limit int, overflow string) (omitted int, err error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	count := 0
+	for _, message := range messages {
+		count += countContentImages(message.Content)
+	}
+	if count <= limit {
+		return 0, nil
+	}
+
+	if overflow != config.MaxImagesOverflowTruncate {
+		return 0, fmt.Errorf("too many images: %d, the limit is %d", count, limit)
+	}
+
+	kept := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		omitted += truncateContentImages(messages[i].Content, limit, &kept)
+	}
+
+	return omitted, nil
+}
+
+// countContentImages 统计消息内容中的图片块，包括 tool_result 中嵌套的图片
+// 内容可能是转换后的 MessageContent，也可能是原生请求解析出的 map
+func countContentImages(content any) int {
+	count := 0
+	switch blocks := content.(type) {
+	case []MessageContent:
+		for _, block := range blocks {
+			count += countBlockImages(block)
+		}
+	case []any:
+		for _, block := range blocks {
+			count += countBlockImages(block)
+		}
+	}
+
+	return count
+}
+
+func countBlockImages(block any) int {
+	if isImageBlock(block) {
+		return 1
+	}
+	if nested, ok := toolResultContent(block); ok {
+		return countContentImages(nested)
+	}
+
+	return 0
+}
+
+// truncateContentImages 从后往前保留图片，超出上限的替换为文字说明，tool_result 中的图片同样处理
+func truncateContentImages(content any, limit int, kept *int) (omitted int) {
+	switch blocks := content.(type) {
+	case []MessageContent:
+		for j := len(blocks) - 1; j >= 0; j-- {
+			if nested, ok := toolResultContent(blocks[j]); ok {
+				omitted += truncateContentImages(nested, limit, kept)
+				continue
+			}
+			if blocks[j].Type != "image" {
+				continue
+			}
+			if *kept < limit {
+				*kept++
+				continue
+			}
+			blocks[j] = MessageContent{Type: "text", Text: imageOmittedText}
+			omitted++
+		}
+	case []any:
+		for j := len(blocks) - 1; j >= 0; j-- {
+			if nested, ok := toolResultContent(blocks[j]); ok {
+				omitted += truncateContentImages(nested, limit, kept)
+				continue
+			}
+			if !isImageBlock(blocks[j]) {
+				continue
+			}
+			if *kept < limit {
+				*kept++
+				continue
+			}
+			blocks[j] = map[string]any{"type": "text", "text": imageOmittedText}
+			omitted++
+		}
+	}
+
+	return omitted
+}
+
+func isImageBlock(block any) bool {
+	switch value := block.(type) {
+	case map[string]any:
+		return value["type"] == "image"
+	case MessageContent:
+		return value.Type == "image"
+	}
+
+	return false
+}
+
+// toolResultContent 获取 tool_result 块的内容，内容中的切片与原消息共享，可以原地修改
+func toolResultContent(block any) (any, bool) {
+	switch value := block.(type) {
+	case map[string]any:
+		if value["type"] == "tool_result" {
+			return value["content"], true
+		}
+	case MessageContent:
+		if value.Type == "tool_result" {
+			return value.Content, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,160 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	"one-api/providers/claude"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func imageMessages(count int) []claude.Message {
+	messages := make([]claude.Message, 0, count)
+	for i := 0; i < count; i++ {
+		messages = append(messages, claude.Message{
+			Role: "user",
+			Content: []claude.MessageContent{
+				{Type: "text", Text: "look"},
+				{Type: "image", Source: &claude.ContentSource{Type: "base64", MediaType: "image/png", Data: string(rune('a' + i))}},
+			},
+		})
+	}
+	return messages
+}
+
+func TestLimitImagesUnderLimit(t *testing.T) {
+	messages := imageMessages(3)
+	omitted, err := claude.LimitImages(messages, 3, config.MaxImagesOverflowError)
+
+	assert.Nil(t, err)
+	assert.Zero(t, omitted)
+	assert.Equal(t, "image", messages[0].Content.([]claude.MessageContent)[1].Type)
+
+	// 0 表示不限制
+	omitted, err = claude.LimitImages(imageMessages(10), 0, config.MaxImagesOverflowError)
+	assert.Nil(t, err)
+	assert.Zero(t, omitted)
+}
+
+func TestLimitImagesOverLimitError(t *testing.T) {
+	_, err := claude.LimitImages(imageMessages(4), 3, config.MaxImagesOverflowError)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the limit is 3")
+}
+
+func TestLimitImagesOverLimitTruncate(t *testing.T) {
+	messages := imageMessages(4)
+	omitted, err := claude.LimitImages(messages, 3, config.MaxImagesOverflowTruncate)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, omitted)
+	// 保留最近的图片，最早的替换为文字
+	first := messages[0].Content.([]claude.MessageContent)
+	assert.Equal(t, "text", first[1].Type)
+	assert.Nil(t, first[1].Source)
+	assert.Equal(t, "b", messages[1].Content.([]claude.MessageContent)[1].Source.Data)
+}
+
+func TestLimitImagesInToolResult(t *testing.T) {
+	toolResultMessages := func() []claude.Message {
+		return []claude.Message{
+			{Role: "user", Content: []claude.MessageContent{
+				{Type: "image", Source: &claude.ContentSource{Type: "base64", MediaType: "image/png", Data: "a"}},
+			}},
+			{Role: "user", Content: []claude.MessageContent{
+				{Type: "tool_result", ToolUseId: "toolu_1", Content: []claude.MessageContent{
+					{Type: "text", Text: "screenshot"},
+					{Type: "image", Source: &claude.ContentSource{Type: "base64", MediaType: "image/png", Data: "b"}},
+				}},
+			}},
+		}
+	}
+
+	// tool_result 中的图片计入上限
+	_, err := claude.LimitImages(toolResultMessages(), 1, config.MaxImagesOverflowError)
+	assert.NotNil(t, err)
+
+	messages := toolResultMessages()
+	omitted, err := claude.LimitImages(messages, 1, config.MaxImagesOverflowTruncate)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, omitted)
+	assert.Equal(t, "text", messages[0].Content.([]claude.MessageContent)[0].Type)
+	nested := messages[1].Content.([]claude.MessageContent)[0].Content.([]claude.MessageContent)
+	assert.Equal(t, "b", nested[1].Source.Data)
+
+	// 原生请求解析出的 map 同样处理
+	var native []claude.Message
+	assert.Nil(t, json.Unmarshal([]byte(`[{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"a"}},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"b"}}]}]}]`), &native))
+	omitted, err = claude.LimitImages(native, 1, config.MaxImagesOverflowTruncate)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, omitted)
+	nestedBlocks := native[0].Content.([]any)[0].(map[string]any)["content"].([]any)
+	assert.Equal(t, "text", nestedBlocks[0].(map[string]any)["type"])
+	assert.Equal(t, "image", nestedBlocks[1].(map[string]any)["type"])
+}
+
+// sendImagesRequest 以原生 Claude 格式发送请求，返回上游收到的图片数量，未请求上游时为 -1
+func sendImagesRequest(t *testing.T, channelMaxImages int, body string) (int, *types.OpenAIErrorWithStatusCode) {
+	requester.InitHttpClient()
+	upstreamImages := -1
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		requestBody, _ := io.ReadAll(r.Body)
+		upstreamImages = strings.Count(string(requestBody), `"type":"image"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	channel.MaxImages = channelMaxImages
+	ctx, _ := test.GetContext("POST", "/v1/messages", test.RequestJSONConfig(), strings.NewReader(body))
+	claudeProvider, _ := providers.GetProvider(&channel, ctx).(*claude.ClaudeProvider)
+	claudeProvider.SetUsage(&types.Usage{})
+
+	var request claude.ClaudeRequest
+	assert.Nil(t, json.Unmarshal([]byte(body), &request))
+	_, errWithCode := claudeProvider.CreateClaudeChat(&request)
+
+	return upstreamImages, errWithCode
+}
+
+func TestMaxImagesRequest(t *testing.T) {
+	config.ClaudeSettingsInstance.SetMaxImages(`{"claude-sonnet-4-20250514":2}`)
+	defer func() {
+		config.ClaudeSettingsInstance.SetMaxImages("")
+		config.ClaudeSettingsInstance.MaxImagesOverflow = config.MaxImagesOverflowError
+	}()
+
+	image := `{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}}`
+	body := `{"model":"claude-sonnet-4-20250514","max_tokens":100,"messages":[{"role":"user","content":[` + image + `,` + image + `,` + image + `,{"type":"text","text":"compare"}]}]}`
+
+	// 超出上限直接报错，不请求上游
+	upstreamImages, errWithCode := sendImagesRequest(t, 0, body)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Equal(t, "too_many_images", errWithCode.Code)
+	assert.Equal(t, -1, upstreamImages)
+
+	// 渠道配置优先
+	upstreamImages, errWithCode = sendImagesRequest(t, 3, body)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, 3, upstreamImages)
+
+	// 截断后只发送最近的图片
+	config.ClaudeSettingsInstance.MaxImagesOverflow = config.MaxImagesOverflowTruncate
+	upstreamImages, errWithCode = sendImagesRequest(t, 0, body)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, 2, upstreamImages)
+}
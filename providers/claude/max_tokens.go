@@ -0,0 +1,82 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/types"
+	"strings"
+)
+
+// 各模型的最大输出 token 数，按前缀匹配，先匹配较具体的前缀
+var claudeMaxOutputTokens = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude-opus-4", 32000},
+	{"claude-sonnet-4", 64000},
+	{"claude-3-7-sonnet", 64000},
+	{"claude-3-5", 8192},
+	{"claude-3", 4096},
+}
+
+// GetMaxOutputTokens 获取模型的最大输出 token 数，未知模型使用默认值
+func GetMaxOutputTokens(model string) int {
+	for _, item := range claudeMaxOutputTokens {
+		if strings.HasPrefix(model, item.prefix) {
+			return item.tokens
+		}
+	}
+
+	return config.ClaudeSettingsInstance.GetDefaultMaxTokens(model)
+}
+
+// hasExplicitZeroMaxTokens 客户端在请求体中显式传入 max_tokens: 0（OpenAI 中表示不限制），与未传入区分
+func (p *ClaudeProvider) hasExplicitZeroMaxTokens() bool {
+	if p.Context == nil {
+		return false
+	}
+
+	rawBody, ok := p.GetRawBody()
+	if !ok {
+		return false
+	}
+
+	var body struct {
+		MaxTokens           *int `json:"max_tokens"`
+		MaxCompletionTokens *int `json:"max_completion_tokens"`
+	}
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		return false
+	}
+
+	if body.MaxCompletionTokens != nil && *body.MaxCompletionTokens > 0 {
+		return false
+	}
+
+	return (body.MaxTokens != nil && *body.MaxTokens == 0) || (body.MaxCompletionTokens != nil && *body.MaxCompletionTokens == 0)
+}
+
+// applyZeroMaxTokens Claude 不接受 max_tokens 为 0，客户端显式传入 0 时按配置替换为默认值或模型最大输出
+func (p *ClaudeProvider) applyZeroMaxTokens(maxTokens *int, model string) *types.OpenAIErrorWithStatusCode {
+	if *maxTokens != 0 || !p.hasExplicitZeroMaxTokens() {
+		return nil
+	}
+
+	interpretation := config.ClaudeSettingsInstance.ZeroMaxTokens
+	switch interpretation {
+	case config.ZeroMaxTokensError:
+		return common.StringErrorWrapperLocal("max_tokens must be greater than 0", "invalid_max_tokens", http.StatusBadRequest)
+	case config.ZeroMaxTokensMax:
+		*maxTokens = GetMaxOutputTokens(model)
+	default:
+		interpretation = config.ZeroMaxTokensDefault
+		*maxTokens = config.ClaudeSettingsInstance.GetDefaultMaxTokens(model)
+	}
+
+	logger.LogInfo(p.Context.Request.Context(), fmt.Sprintf("max_tokens: 0 interpreted as %s, using max_tokens %d for %s", interpretation, *maxTokens, model))
+	return nil
+}
@@ -0,0 +1,93 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/providers/claude"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sendMaxTokensRequest 以原始请求体发送 OpenAI 格式的请求，返回上游收到的 max_tokens
+func sendMaxTokensRequest(t *testing.T, body string) (int, *types.OpenAIErrorWithStatusCode) {
+	requester.InitHttpClient()
+	upstreamMaxTokens := -1
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		var upstreamRequest claude.ClaudeRequest
+		requestBody, _ := io.ReadAll(r.Body)
+		json.Unmarshal(requestBody, &upstreamRequest)
+		upstreamMaxTokens = upstreamRequest.MaxTokens
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	ctx, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), strings.NewReader(body))
+	ctx.Set(config.GinRequestBodyKey, []byte(body))
+	chatProvider, _ := providers.GetProvider(&channel, ctx).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	var request types.ChatCompletionRequest
+	assert.Nil(t, json.Unmarshal([]byte(body), &request))
+	_, errWithCode := chatProvider.CreateChatCompletion(&request)
+
+	return upstreamMaxTokens, errWithCode
+}
+
+func TestZeroMaxTokens(t *testing.T) {
+	defer func() { config.ClaudeSettingsInstance.ZeroMaxTokens = config.ZeroMaxTokensDefault }()
+	zeroBody := `{"model":"claude-sonnet-4-20250514","max_tokens":0,"messages":[{"role":"user","content":"hi"}]}`
+
+	// 默认使用配置的默认值
+	maxTokens, errWithCode := sendMaxTokensRequest(t, zeroBody)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, config.ClaudeSettingsInstance.GetDefaultMaxTokens("claude-sonnet-4-20250514"), maxTokens)
+
+	// 使用模型最大输出
+	config.ClaudeSettingsInstance.ZeroMaxTokens = config.ZeroMaxTokensMax
+	maxTokens, errWithCode = sendMaxTokensRequest(t, zeroBody)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, 64000, maxTokens)
+
+	// 正常的值保持不变
+	maxTokens, errWithCode = sendMaxTokensRequest(t, `{"model":"claude-sonnet-4-20250514","max_tokens":1000,"messages":[{"role":"user","content":"hi"}]}`)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, 1000, maxTokens)
+
+	// 未传入时仍使用默认值，不按 0 处理
+	maxTokens, errWithCode = sendMaxTokensRequest(t, `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hi"}]}`)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, config.ClaudeSettingsInstance.GetDefaultMaxTokens("claude-sonnet-4-20250514"), maxTokens)
+
+	// max_completion_tokens 优先
+	maxTokens, errWithCode = sendMaxTokensRequest(t, `{"model":"claude-sonnet-4-20250514","max_tokens":0,"max_completion_tokens":2000,"messages":[{"role":"user","content":"hi"}]}`)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, 2000, maxTokens)
+
+	// 直接报错，不请求上游
+	config.ClaudeSettingsInstance.ZeroMaxTokens = config.ZeroMaxTokensError
+	maxTokens, errWithCode = sendMaxTokensRequest(t, zeroBody)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Equal(t, -1, maxTokens)
+}
+
+func TestGetMaxOutputTokens(t *testing.T) {
+	assert.Equal(t, 32000, claude.GetMaxOutputTokens("claude-opus-4-1-20250805"))
+	assert.Equal(t, 8192, claude.GetMaxOutputTokens("claude-3-5-haiku-20241022"))
+	assert.Equal(t, 4096, claude.GetMaxOutputTokens("claude-3-haiku-20240307"))
+	assert.Equal(t, config.ClaudeSettingsInstance.GetDefaultMaxTokens("unknown"), claude.GetMaxOutputTokens("unknown"))
+}
@@ -0,0 +1,209 @@
+package claude
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"one-api/common/config"
+	"strings"
+)
+
+// 提示词缓存的 beta 请求头，渠道自定义请求头中可能带有
+const PromptCachingBetaPrefix = "prompt-caching-"
+
+// Claude 单个请求最多 4 个缓存断点
+const maxCacheBreakpoints = 4
+
+const defaultPromptCacheMinTokens = 1024
+
+// 各模型可缓存的最小 token 数，按前缀匹配，未匹配的使用 defaultPromptCacheMinTokens
+var promptCacheMinTokens = map[string]int{
+	"claude-3-haiku":   2048,
+	"claude-3-5-haiku": 2048,
+	"claude-haiku":     2048,
+}
+
+func getPromptCacheMinTokens(model string) int {
+	for prefix, minTokens := range promptCacheMinTokens {
+		if strings.HasPrefix(model, prefix) {
+			return minTokens
+		}
+	}
+
+	return defaultPromptCacheMinTokens
+}
+
+// cacheSystemPrompt 为 system 提示词添加缓存标记，低于最小可缓存 token 数时原样返回，
+// 超过拆分阈值时拆分为多个缓存块，数量不超过剩余的缓存断点
+func cacheSystemPrompt(claudeRequest *ClaudeRequest, system string) any {
+	minTokens := getPromptCacheMinTokens(claudeRequest.Model)
+	tokens := common.CountTokenText(system, claudeRequest.Model)
+	available := maxCacheBreakpoints - countCacheBreakpoints(claudeRequest)
+	if tokens < minTokens || available <= 0 {
+		return system
+	}
+
+	blocks := 1
+	if splitTokens := config.ClaudeSettingsInstance.SystemPromptCacheSplitTokens; splitTokens > 0 {
+		// 每个缓存块的前缀都需要达到最小可缓存 token 数
+		splitTokens = max(splitTokens, minTokens)
+		blocks = min((tokens+splitTokens-1)/splitTokens, available)
+	}
+
+	chunks := splitSystemPrompt(system, blocks)
+	contents := make([]MessageContent, 0, len(chunks))
+	for _, chunk := range chunks {
+		contents = append(contents, MessageContent{
+			Type:         ContentTypeText,
+			Text:         chunk,
+			CacheControl: map[string]string{"type": "ephemeral"},
+		})
+	}
+
+	return contents
+}
+
+// splitSystemPrompt 将文本按长度均分为 n 段，尽量在换行处切分
+func splitSystemPrompt(text string, n int) []string {
+	if n <= 1 {
+		return []string{text}
+	}
+
+	runes := []rune(text)
+	size := len(runes) / n
+	chunks := make([]string, 0, n)
+	start := 0
+	for i := 1; i < n; i++ {
+		end := i * size
+		if end <= start {
+			continue
+		}
+		// 在本段后半部分寻找最近的换行
+		for j := end; j > start+size/2; j-- {
+			if runes[j-1] == '\n' {
+				end = j
+				break
+			}
+		}
+		chunks = append(chunks, string(runes[start:end]))
+		start = end
+	}
+
+	return append(chunks, string(runes[start:]))
+}
+
+// countCacheBreakpoints 统计请求中已有的缓存断点
+func countCacheBreakpoints(claudeRequest *ClaudeRequest) int {
+	count := 0
+	for _, tool := range claudeRequest.Tools {
+		if tool.CacheControl != nil {
+			count++
+		}
+	}
+
+	for _, message := range claudeRequest.Messages {
+		contents, ok := message.Content.([]MessageContent)
+		if !ok {
+			continue
+		}
+		for _, content := range contents {
+			if content.CacheControl != nil {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// cacheTools 工具定义达到最小可缓存 token 数时，在最后一个工具上添加缓存标记，
+// 缓存覆盖此前的全部工具定义
+func cacheTools(claudeRequest *ClaudeRequest) {
+	if len(claudeRequest.Tools) == 0 || countCacheBreakpoints(claudeRequest) >= maxCacheBreakpoints {
+		return
+	}
+
+	last := &claudeRequest.Tools[len(claudeRequest.Tools)-1]
+	if last.CacheControl != nil {
+		return
+	}
+
+	toolsJson, err := json.Marshal(claudeRequest.Tools)
+	if err != nil {
+		return
+	}
+
+	if common.CountTokenText(string(toolsJson), claudeRequest.Model) < getPromptCacheMinTokens(claudeRequest.Model) {
+		return
+	}
+
+	last.CacheControl = map[string]string{"type": "ephemeral"}
+}
+
+// canonicalToolSchema 将工具参数转换为键有序的通用结构，
+// 保证相同的工具定义序列化结果一致，否则缓存无法命中
+func canonicalToolSchema(schema any) any {
+	if schema == nil {
+		return nil
+	}
+
+	schemaJson, err := json.Marshal(schema)
+	if err != nil {
+		return schema
+	}
+
+	var canonical any
+	if err := json.Unmarshal(schemaJson, &canonical); err != nil {
+		return schema
+	}
+
+	return canonical
+}
+
+// StripCacheControl 移除请求中工具、system 和消息内容上的全部缓存标记，
+// 内容可能是转换后的 MessageContent，也可能是原生请求解析出的 map
+func StripCacheControl(claudeRequest *ClaudeRequest) {
+	for i := range claudeRequest.Tools {
+		claudeRequest.Tools[i].CacheControl = nil
+	}
+
+	stripContentCacheControl(claudeRequest.System)
+	for _, message := range claudeRequest.Messages {
+		stripContentCacheControl(message.Content)
+	}
+}
+
+func stripContentCacheControl(content any) {
+	switch blocks := content.(type) {
+	case []MessageContent:
+		for i := range blocks {
+			blocks[i].CacheControl = nil
+		}
+	case []any:
+		for _, block := range blocks {
+			if value, ok := block.(map[string]any); ok {
+				delete(value, "cache_control")
+			}
+		}
+	}
+}
+
+// removeAnthropicBeta 从 anthropic-beta 请求头中移除指定前缀的 beta
+func removeAnthropicBeta(headers map[string]string, prefix string) {
+	if headers["anthropic-beta"] == "" {
+		return
+	}
+
+	betas := make([]string, 0)
+	for _, beta := range strings.Split(headers["anthropic-beta"], ",") {
+		beta = strings.TrimSpace(beta)
+		if beta != "" && !strings.HasPrefix(beta, prefix) {
+			betas = append(betas, beta)
+		}
+	}
+
+	if len(betas) == 0 {
+		delete(headers, "anthropic-beta")
+		return
+	}
+	headers["anthropic-beta"] = strings.Join(betas, ",")
+}
@@ -0,0 +1,263 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/providers/claude"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func convertWithSystemPrompt(t *testing.T, model, system string) *claude.ClaudeRequest {
+	claudeRequest, errWithCode := claude.ConvertFromChatOpenai(&types.ChatCompletionRequest{
+		Model: model,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleSystem, Content: system},
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+	})
+	assert.Nil(t, errWithCode)
+	return claudeRequest
+}
+
+func setupSystemPromptCache(splitTokens int) func() {
+	config.ApproximateTokenEnabled = true
+	config.ClaudeSettingsInstance.SystemPromptCache = true
+	config.ClaudeSettingsInstance.SystemPromptCacheSplitTokens = splitTokens
+	return func() {
+		config.ApproximateTokenEnabled = false
+		config.ClaudeSettingsInstance.SystemPromptCache = false
+		config.ClaudeSettingsInstance.SystemPromptCacheSplitTokens = 0
+	}
+}
+
+func longSystemPrompt(lines int) string {
+	return strings.Repeat("You are a careful assistant, follow every rule listed in this section.\n", lines)
+}
+
+func TestSystemPromptCacheBelowMinimum(t *testing.T) {
+	defer setupSystemPromptCache(0)()
+
+	claudeRequest := convertWithSystemPrompt(t, "claude-3-5-sonnet-20241022", "You are a helpful assistant.")
+	assert.Equal(t, "You are a helpful assistant.", claudeRequest.System)
+
+	// haiku 的最小可缓存 token 数更高
+	claudeRequest = convertWithSystemPrompt(t, "claude-3-5-haiku-20241022", longSystemPrompt(60))
+	assert.IsType(t, "", claudeRequest.System)
+}
+
+func TestSystemPromptCacheAboveMinimum(t *testing.T) {
+	defer setupSystemPromptCache(0)()
+
+	system := longSystemPrompt(60)
+	claudeRequest := convertWithSystemPrompt(t, "claude-3-5-sonnet-20241022", system)
+
+	blocks := claudeRequest.System.([]claude.MessageContent)
+	assert.Len(t, blocks, 1)
+	assert.Equal(t, system, blocks[0].Text)
+
+	body, _ := json.Marshal(claudeRequest)
+	assert.Contains(t, string(body), `"cache_control":{"type":"ephemeral"}`)
+
+	// 关闭时不添加
+	config.ClaudeSettingsInstance.SystemPromptCache = false
+	assert.IsType(t, "", convertWithSystemPrompt(t, "claude-3-5-sonnet-20241022", system).System)
+}
+
+func TestSystemPromptCacheSplit(t *testing.T) {
+	defer setupSystemPromptCache(1024)()
+
+	system := longSystemPrompt(100)
+	blocks := convertWithSystemPrompt(t, "claude-3-5-sonnet-20241022", system).System.([]claude.MessageContent)
+	assert.Len(t, blocks, 3)
+
+	// 在换行处拆分，合并后与原文一致
+	text := ""
+	for _, block := range blocks {
+		assert.NotNil(t, block.CacheControl)
+		assert.True(t, strings.HasSuffix(block.Text, "\n"))
+		text += block.Text
+	}
+	assert.Equal(t, system, text)
+
+	// 不超过 Claude 的缓存断点上限
+	blocks = convertWithSystemPrompt(t, "claude-3-5-sonnet-20241022", longSystemPrompt(3000)).System.([]claude.MessageContent)
+	assert.Len(t, blocks, 4)
+}
+
+func convertWithTools(t *testing.T, model string, tools []*types.ChatCompletionTool) *claude.ClaudeRequest {
+	claudeRequest, errWithCode := claude.ConvertFromChatOpenai(&types.ChatCompletionRequest{
+		Model: model,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+		Tools: tools,
+	})
+	assert.Nil(t, errWithCode)
+	return claudeRequest
+}
+
+func getCacheTools(count int, parameters func() any) []*types.ChatCompletionTool {
+	tools := make([]*types.ChatCompletionTool, 0, count)
+	for i := 0; i < count; i++ {
+		tools = append(tools, &types.ChatCompletionTool{
+			Type: "function",
+			Function: types.ChatCompletionFunction{
+				Name:        "tool_" + strings.Repeat("x", i+1),
+				Description: strings.Repeat("Look up detailed records from the internal catalogue. ", 10),
+				Parameters:  parameters(),
+			},
+		})
+	}
+	return tools
+}
+
+func setupToolsCache() func() {
+	config.ApproximateTokenEnabled = true
+	config.ClaudeSettingsInstance.SetToolsCache(`{"claude-3-5-sonnet-20241022":true}`)
+	return func() {
+		config.ApproximateTokenEnabled = false
+		config.ClaudeSettingsInstance.SetToolsCache("")
+	}
+}
+
+func TestToolsCache(t *testing.T) {
+	defer setupToolsCache()()
+	parameters := func() any {
+		return map[string]any{"type": "object", "properties": map[string]any{"id": map[string]any{"type": "string"}}}
+	}
+
+	claudeRequest := convertWithTools(t, "claude-3-5-sonnet-20241022", getCacheTools(1, parameters))
+	assert.Nil(t, claudeRequest.Tools[0].CacheControl)
+
+	claudeRequest = convertWithTools(t, "claude-3-5-sonnet-20241022", getCacheTools(20, parameters))
+	for _, tool := range claudeRequest.Tools[:19] {
+		assert.Nil(t, tool.CacheControl)
+	}
+	assert.Equal(t, map[string]string{"type": "ephemeral"}, claudeRequest.Tools[19].CacheControl)
+
+	// 未开启的模型不添加缓存标记
+	claudeRequest = convertWithTools(t, "claude-3-7-sonnet-20250219", getCacheTools(20, parameters))
+	assert.Nil(t, claudeRequest.Tools[19].CacheControl)
+
+	// default 作用于未单独配置的模型
+	config.ClaudeSettingsInstance.SetToolsCache(`{"default":true,"claude-3-5-sonnet-20241022":false}`)
+	claudeRequest = convertWithTools(t, "claude-3-7-sonnet-20250219", getCacheTools(20, parameters))
+	assert.NotNil(t, claudeRequest.Tools[19].CacheControl)
+	claudeRequest = convertWithTools(t, "claude-3-5-sonnet-20241022", getCacheTools(20, parameters))
+	assert.Nil(t, claudeRequest.Tools[19].CacheControl)
+}
+
+func TestToolsCacheDeterministic(t *testing.T) {
+	defer setupToolsCache()()
+
+	first := convertWithTools(t, "claude-3-5-sonnet-20241022", getCacheTools(20, func() any {
+		return json.RawMessage(`{"type":"object","properties":{"id":{"type":"string"},"limit":{"type":"integer"}}}`)
+	}))
+	second := convertWithTools(t, "claude-3-5-sonnet-20241022", getCacheTools(20, func() any {
+		return json.RawMessage(`{"properties":{"limit":{"type":"integer"},"id":{"type":"string"}},"type":"object"}`)
+	}))
+
+	firstJson, _ := json.Marshal(first.Tools)
+	secondJson, _ := json.Marshal(second.Tools)
+	assert.Equal(t, string(firstJson), string(secondJson))
+	assert.NotNil(t, second.Tools[19].CacheControl)
+}
+
+func TestDisablePromptCache(t *testing.T) {
+	defer setupSystemPromptCache(0)()
+	defer setupToolsCache()()
+
+	system := longSystemPrompt(60)
+	request := &types.ChatCompletionRequest{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleSystem, Content: system},
+			{
+				Role:         types.ChatMessageRoleUser,
+				Content:      []any{map[string]any{"type": "text", "text": "hi"}},
+				CacheControl: map[string]string{"type": "ephemeral"},
+			},
+		},
+		Tools: getCacheTools(20, func() any {
+			return map[string]any{"type": "object"}
+		}),
+		DisablePromptCache: true,
+	}
+
+	// 全局开启缓存时，请求级别的关闭优先，客户端自带的缓存标记也一并移除
+	claudeRequest, errWithCode := claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+	assert.True(t, claudeRequest.DisablePromptCache)
+	assert.Equal(t, system, claudeRequest.System)
+	body, _ := json.Marshal(claudeRequest)
+	assert.NotContains(t, string(body), "cache_control")
+
+	request.DisablePromptCache = false
+	claudeRequest, errWithCode = claude.ConvertFromChatOpenai(request)
+	assert.Nil(t, errWithCode)
+	body, _ = json.Marshal(claudeRequest)
+	assert.Equal(t, 3, strings.Count(string(body), "cache_control"))
+}
+
+func TestStripCacheControlNative(t *testing.T) {
+	claudeRequest := &claude.ClaudeRequest{}
+	err := json.Unmarshal([]byte(`{
+		"model":"claude-3-5-sonnet-20241022",
+		"system":[{"type":"text","text":"rules","cache_control":{"type":"ephemeral"}}],
+		"tools":[{"name":"lookup","input_schema":{"type":"object"},"cache_control":{"type":"ephemeral"}}],
+		"messages":[{"role":"user","content":[{"type":"text","text":"hi","cache_control":{"type":"ephemeral"}}]}]
+	}`), claudeRequest)
+	assert.Nil(t, err)
+
+	claude.StripCacheControl(claudeRequest)
+	body, _ := json.Marshal(claudeRequest)
+	assert.NotContains(t, string(body), "cache_control")
+	assert.Contains(t, string(body), `"text":"rules"`)
+}
+
+func TestDisablePromptCacheBetaHeader(t *testing.T) {
+	requester.InitHttpClient()
+	var betaHeader string
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		betaHeader = r.Header.Get("anthropic-beta")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	// 渠道自定义请求头中开启了提示词缓存的 beta
+	modelHeaders := `{"anthropic-beta":"prompt-caching-2024-07-31,token-efficient-tools-2025-02-19"}`
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	channel.ModelHeaders = &modelHeaders
+
+	for _, disabled := range []bool{false, true} {
+		ctx, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+		chatProvider, _ := providers.GetProvider(&channel, ctx).(providers_base.ChatInterface)
+		chatProvider.SetUsage(&types.Usage{})
+		_, errWithCode := chatProvider.CreateChatCompletion(&types.ChatCompletionRequest{
+			Model:              "claude-sonnet-4-20250514",
+			MaxTokens:          100,
+			Messages:           []types.ChatCompletionMessage{{Role: types.ChatMessageRoleUser, Content: "hi"}},
+			DisablePromptCache: disabled,
+		})
+		assert.Nil(t, errWithCode)
+
+		if disabled {
+			assert.Equal(t, "token-efficient-tools-2025-02-19", betaHeader)
+		} else {
+			assert.Equal(t, "prompt-caching-2024-07-31,token-efficient-tools-2025-02-19", betaHeader)
+		}
+	}
+}
@@ -19,6 +19,10 @@ type ClaudeRelayStreamHandler struct {
 }
 
 func (p *ClaudeProvider) CreateClaudeChat(request *ClaudeRequest) (*ClaudeResponse, *types.OpenAIErrorWithStatusCode) {
+	if errWithCode := p.applyZeroMaxTokens(&request.MaxTokens, request.Model); errWithCode != nil {
+		return nil, errWithCode
+	}
+
 	req, errWithCode := p.getChatRequest(request)
 	if errWithCode != nil {
 		return nil, errWithCode
@@ -36,14 +40,20 @@ func (p *ClaudeProvider) CreateClaudeChat(request *ClaudeRequest) (*ClaudeRespon
 
 	isOk := ClaudeUsageToOpenaiUsage(&claudeResponse.Usage, usage)
 	if !isOk {
+		setPromptTokens(&claudeResponse.Usage, usage)
 		usage.CompletionTokens = ClaudeOutputUsage(claudeResponse)
 		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	}
+	recordCodeExecutionUsage(claudeResponse.Content, usage)
 
 	return claudeResponse, nil
 }
 
 func (p *ClaudeProvider) CreateClaudeChatStream(request *ClaudeRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	if errWithCode := p.applyZeroMaxTokens(&request.MaxTokens, request.Model); errWithCode != nil {
+		return nil, errWithCode
+	}
+
 	req, errWithCode := p.getChatRequest(request)
 	if errWithCode != nil {
 		return nil, errWithCode
@@ -112,13 +122,27 @@ func (h *ClaudeRelayStreamHandler) HandlerStream(rawLine *[]byte, dataChan chan
 
 	switch claudeResponse.Type {
 	case "message_start":
+		// message_start 中的 output_tokens 只是占位，补全用量以 message_delta 为准，缺失时按已输出的内容估算
 		ClaudeUsageToOpenaiUsage(&claudeResponse.Message.Usage, h.Usage)
-		h.StartUsage = &claudeResponse.Message.Usage
+		setPromptTokens(&claudeResponse.Message.Usage, h.Usage)
+		h.Usage.CompletionTokens = 0
+		h.Usage.TotalTokens = h.Usage.PromptTokens
+		startUsage := claudeResponse.Message.Usage
+		startUsage.OutputTokens = 0
+		h.StartUsage = &startUsage
 	case "message_delta":
 		ClaudeUsageMerge(&claudeResponse.Usage, h.StartUsage)
-		ClaudeUsageToOpenaiUsage(&claudeResponse.Usage, h.Usage)
+		if !ClaudeUsageToOpenaiUsage(&claudeResponse.Usage, h.Usage) && claudeResponse.Usage.OutputTokens > 0 {
+			h.Usage.CompletionTokens = claudeResponse.Usage.OutputTokens
+			h.Usage.TotalTokens = h.Usage.PromptTokens + h.Usage.CompletionTokens
+		}
+		setPromptTokens(&claudeResponse.Usage, h.Usage)
+	case "content_block_start":
+		if isCodeExecutionResult(claudeResponse.ContentBlock.Type) {
+			h.Usage.IncExtraBilling(types.APITollTypeCodeExecution, "")
+		}
 	case "content_block_delta":
-		h.Usage.TextBuilder.WriteString(claudeResponse.Delta.Text)
+		writeDeltaText(&h.Usage.TextBuilder, &claudeResponse.Delta)
 	}
 
 	dataChan <- rawStr
@@ -0,0 +1,40 @@
+package claude
+
+import (
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+)
+
+// getSamplingParamsPrecedence 渠道配置优先于全局配置
+func (p *ClaudeProvider) getSamplingParamsPrecedence() string {
+	if p.Channel != nil && p.Channel.SamplingPrecedence != "" {
+		return p.Channel.SamplingPrecedence
+	}
+
+	return config.ClaudeSettingsInstance.SamplingParamsPrecedence
+}
+
+// applySamplingParams Anthropic 建议不要同时设置 temperature 与 top_p，
+// 两者都存在时按配置只保留其中一个
+func (p *ClaudeProvider) applySamplingParams(claudeRequest *ClaudeRequest) {
+	if claudeRequest.Temperature == nil || claudeRequest.TopP == nil {
+		return
+	}
+
+	var dropped string
+	switch p.getSamplingParamsPrecedence() {
+	case config.SamplingParamsTemperature:
+		dropped = fmt.Sprintf("top_p=%v", *claudeRequest.TopP)
+		claudeRequest.TopP = nil
+	case config.SamplingParamsTopP:
+		dropped = fmt.Sprintf("temperature=%v", *claudeRequest.Temperature)
+		claudeRequest.Temperature = nil
+	default:
+		return
+	}
+
+	if p.Context != nil {
+		logger.LogWarn(p.Context.Request.Context(), "both temperature and top_p are set, dropped "+dropped)
+	}
+}
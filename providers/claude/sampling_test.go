@@ -0,0 +1,105 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sendSamplingRequest 发送请求并返回上游实际收到的请求体
+func sendSamplingRequest(t *testing.T, temperature, topP *float64) map[string]any {
+	return sendChannelSamplingRequest(t, "", temperature, topP)
+}
+
+// sendChannelSamplingRequest 使用设置了 sampling_precedence 的渠道发送请求
+func sendChannelSamplingRequest(t *testing.T, precedence string, temperature, topP *float64) map[string]any {
+	requester.InitHttpClient()
+
+	received := map[string]any{}
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	channel.SamplingPrecedence = precedence
+	context, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider, _ := providers.GetProvider(&channel, context).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	_, errWithCode := chatProvider.CreateChatCompletion(&types.ChatCompletionRequest{
+		Model:       "claude-3-5-sonnet-20241022",
+		MaxTokens:   100,
+		Temperature: temperature,
+		TopP:        topP,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+	})
+	assert.Nil(t, errWithCode)
+
+	return received
+}
+
+func TestSamplingParamsPrecedence(t *testing.T) {
+	defer func() { config.ClaudeSettingsInstance.SamplingParamsPrecedence = config.SamplingParamsBoth }()
+	temperature, topP := 0.5, 0.9
+
+	// 默认全部透传
+	body := sendSamplingRequest(t, &temperature, &topP)
+	assert.Equal(t, 0.5, body["temperature"])
+	assert.Equal(t, 0.9, body["top_p"])
+
+	config.ClaudeSettingsInstance.SamplingParamsPrecedence = config.SamplingParamsTemperature
+	body = sendSamplingRequest(t, &temperature, &topP)
+	assert.Equal(t, 0.5, body["temperature"])
+	assert.NotContains(t, body, "top_p")
+
+	config.ClaudeSettingsInstance.SamplingParamsPrecedence = config.SamplingParamsTopP
+	body = sendSamplingRequest(t, &temperature, &topP)
+	assert.NotContains(t, body, "temperature")
+	assert.Equal(t, 0.9, body["top_p"])
+
+	// 只设置一个参数时保持不变
+	body = sendSamplingRequest(t, &temperature, nil)
+	assert.Equal(t, 0.5, body["temperature"])
+	assert.NotContains(t, body, "top_p")
+
+	config.ClaudeSettingsInstance.SamplingParamsPrecedence = config.SamplingParamsTemperature
+	body = sendSamplingRequest(t, nil, &topP)
+	assert.NotContains(t, body, "temperature")
+	assert.Equal(t, 0.9, body["top_p"])
+}
+
+func TestSamplingParamsPrecedenceChannel(t *testing.T) {
+	defer func() { config.ClaudeSettingsInstance.SamplingParamsPrecedence = config.SamplingParamsBoth }()
+	temperature, topP := 0.5, 0.9
+
+	// 渠道配置覆盖全局配置
+	config.ClaudeSettingsInstance.SamplingParamsPrecedence = config.SamplingParamsTemperature
+	body := sendChannelSamplingRequest(t, config.SamplingParamsTopP, &temperature, &topP)
+	assert.NotContains(t, body, "temperature")
+	assert.Equal(t, 0.9, body["top_p"])
+
+	body = sendChannelSamplingRequest(t, config.SamplingParamsBoth, &temperature, &topP)
+	assert.Equal(t, 0.5, body["temperature"])
+	assert.Equal(t, 0.9, body["top_p"])
+
+	// 渠道未配置时使用全局配置
+	body = sendChannelSamplingRequest(t, "", &temperature, &topP)
+	assert.Equal(t, 0.5, body["temperature"])
+	assert.NotContains(t, body, "top_p")
+}
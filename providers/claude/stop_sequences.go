@@ -0,0 +1,68 @@
+package claude
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/types"
+	"slices"
+)
+
+// getChannelStopSequences 从渠道自定义参数的 stop_sequences 中获取渠道强制的停止序列
+func (p *ClaudeProvider) getChannelStopSequences() []string {
+	customParams, err := p.CustomParameterHandler()
+	if err != nil || customParams == nil {
+		return nil
+	}
+
+	values, ok := customParams["stop_sequences"].([]any)
+	if !ok {
+		return nil
+	}
+
+	stopSequences := make([]string, 0, len(values))
+	for _, value := range values {
+		if stop, ok := value.(string); ok && stop != "" {
+			stopSequences = append(stopSequences, stop)
+		}
+	}
+
+	return stopSequences
+}
+
+// applyStopSequences 合并渠道与客户端的 stop_sequences，并按配置处理超出上限的情况
+func (p *ClaudeProvider) applyStopSequences(claudeRequest *ClaudeRequest) *types.OpenAIErrorWithStatusCode {
+	stopSequences, truncated, err := MergeStopSequences(p.getChannelStopSequences(), claudeRequest.StopSequences, config.ClaudeSettingsInstance.StopSequencesLimit, config.ClaudeSettingsInstance.StopSequencesOverflow)
+	if err != nil {
+		return common.ErrorWrapperLocal(err, "invalid_request_error", http.StatusBadRequest)
+	}
+
+	if len(truncated) > 0 && p.Context != nil {
+		logger.LogWarn(p.Context.Request.Context(), fmt.Sprintf("stop_sequences exceed the limit of %d, truncated: %q", config.ClaudeSettingsInstance.StopSequencesLimit, truncated))
+	}
+
+	claudeRequest.StopSequences = stopSequences
+	return nil
+}
+
+// MergeStopSequences 合并渠道与客户端的停止序列（渠道优先、去重）
+// 超出 limit 时，truncate 策略返回截断后的结果及被丢弃的序列，error 策略返回错误
+func MergeStopSequences(channelStops, clientStops []string, limit int, overflow string) (merged []string, truncated []string, err error) {
+	for _, stop := range append(slices.Clone(channelStops), clientStops...) {
+		if !slices.Contains(merged, stop) {
+			merged = append(merged, stop)
+		}
+	}
+
+	if limit <= 0 || len(merged) <= limit {
+		return merged, nil, nil
+	}
+
+	if overflow == config.StopSequencesOverflowError {
+		return nil, nil, fmt.Errorf("too many stop sequences: %d, the limit is %d", len(merged), limit)
+	}
+
+	return merged[:limit], merged[limit:], nil
+}
@@ -0,0 +1,32 @@
+package claude_test
+
+import (
+	"one-api/common/config"
+	"one-api/providers/claude"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeStopSequencesUnderLimit(t *testing.T) {
+	merged, truncated, err := claude.MergeStopSequences([]string{"</answer>"}, []string{"\n\nHuman:", "</answer>"}, 4, config.StopSequencesOverflowError)
+
+	assert.Nil(t, err)
+	assert.Empty(t, truncated)
+	assert.Equal(t, []string{"</answer>", "\n\nHuman:"}, merged)
+}
+
+func TestMergeStopSequencesOverLimitTruncate(t *testing.T) {
+	merged, truncated, err := claude.MergeStopSequences([]string{"A", "B"}, []string{"C", "D", "E"}, 3, config.StopSequencesOverflowTruncate)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"A", "B", "C"}, merged)
+	assert.Equal(t, []string{"D", "E"}, truncated)
+}
+
+func TestMergeStopSequencesOverLimitError(t *testing.T) {
+	_, _, err := claude.MergeStopSequences([]string{"A", "B"}, []string{"C", "D", "E"}, 3, config.StopSequencesOverflowError)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the limit is 3")
+}
@@ -0,0 +1,146 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runClaudeStreamToClient 将 Claude 的流式事件转换为 OpenAI 格式，返回转换后的数据块和用量
+func runClaudeStreamToClient(t *testing.T, events []string) ([]types.ChatCompletionStreamResponse, *types.Usage) {
+	requester.InitHttpClient()
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			w.Write([]byte("event: message\ndata: " + event + "\n\n"))
+		}
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider := providers.GetProvider(&channel, c).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	stream, errWithCode := chatProvider.CreateChatCompletionStream(&types.ChatCompletionRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Stream:    true,
+		Messages:  []types.ChatCompletionMessage{{Role: types.ChatMessageRoleUser, Content: "hi"}},
+	})
+	assert.Nil(t, errWithCode)
+
+	chunks := make([]types.ChatCompletionStreamResponse, 0)
+	dataChan, errChan := stream.Recv()
+	for {
+		select {
+		case data := <-dataChan:
+			var chunk types.ChatCompletionStreamResponse
+			assert.Nil(t, json.Unmarshal([]byte(data), &chunk), data)
+			chunks = append(chunks, chunk)
+		case err := <-errChan:
+			assert.ErrorIs(t, err, io.EOF)
+			return chunks, chatProvider.GetUsage()
+		}
+	}
+}
+
+// assertStreamTerminal 校验 finish_reason 只出现在最后一个数据块
+func assertStreamTerminal(t *testing.T, chunks []types.ChatCompletionStreamResponse, finishReason string) {
+	assert.NotEmpty(t, chunks)
+
+	finalChunk := chunks[len(chunks)-1]
+	assert.Len(t, finalChunk.Choices, 1)
+	assert.NotNil(t, finalChunk.Choices[0].FinishReason)
+	assert.Equal(t, finishReason, finalChunk.Choices[0].FinishReason)
+
+	for _, chunk := range chunks[:len(chunks)-1] {
+		for _, choice := range chunk.Choices {
+			assert.Nil(t, choice.FinishReason)
+		}
+	}
+}
+
+const streamMessageStart = `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`
+
+func TestClaudeStreamTerminalStop(t *testing.T) {
+	chunks, usage := runClaudeStreamToClient(t, []string{
+		streamMessageStart,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"ping"}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+		`{"type":"message_stop"}`,
+	})
+	assertStreamTerminal(t, chunks, types.FinishReasonStop)
+	assert.Equal(t, 15, usage.TotalTokens)
+}
+
+func TestClaudeStreamTerminalToolUse(t *testing.T) {
+	chunks, _ := runClaudeStreamToClient(t, []string{
+		streamMessageStart,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+		`{"type":"message_stop"}`,
+	})
+	assertStreamTerminal(t, chunks, types.FinishReasonToolCalls)
+
+	arguments := ""
+	for _, chunk := range chunks {
+		for _, choice := range chunk.Choices {
+			for _, toolCall := range choice.Delta.ToolCalls {
+				arguments += toolCall.Function.Arguments
+			}
+		}
+	}
+	assert.Equal(t, `{"city":"Paris"}`, arguments)
+}
+
+func TestClaudeStreamTerminalRefusal(t *testing.T) {
+	chunks, _ := runClaudeStreamToClient(t, []string{
+		streamMessageStart,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"I can't help with that."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"refusal"},"usage":{"output_tokens":5}}`,
+		`{"type":"message_stop"}`,
+	})
+	assertStreamTerminal(t, chunks, types.FinishReasonContentFilter)
+}
+
+func TestClaudeStreamTerminalWithoutMessageStop(t *testing.T) {
+	// 上游未发送 message_stop 直接断开，仍然只输出一次 finish_reason
+	chunks, _ := runClaudeStreamToClient(t, []string{
+		streamMessageStart,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+	})
+	assertStreamTerminal(t, chunks, types.FinishReasonStop)
+}
+
+func TestClaudeStreamTerminalWithoutMessageDelta(t *testing.T) {
+	// 上游在 message_stop 前没有发送 stop_reason，结束时补发 finish_reason
+	chunks, _ := runClaudeStreamToClient(t, []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"usage":{"input_tokens":10,"output_tokens":5}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{}"}}`,
+		`{"type":"message_stop"}`,
+	})
+	assertStreamTerminal(t, chunks, types.FinishReasonToolCalls)
+}
@@ -0,0 +1,270 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/providers/claude"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newStreamUsageServer(events []string) *test.ServerTest {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			w.Write([]byte("data: " + event + "\n\n"))
+		}
+	})
+	return server
+}
+
+func drainStream[T any](stream requester.StreamReaderInterface[T]) {
+	dataChan, errChan := stream.Recv()
+	for {
+		select {
+		case <-dataChan:
+		case <-errChan:
+			return
+		}
+	}
+}
+
+var streamWithoutInputTokens = []string{
+	`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"usage":{}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+	`{"type":"message_stop"}`,
+}
+
+func TestChatStreamMissingInputTokens(t *testing.T) {
+	requester.InitHttpClient()
+	ts := newStreamUsageServer(streamWithoutInputTokens).TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	ctx, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider, _ := providers.GetProvider(&channel, ctx).(providers_base.ChatInterface)
+	// 请求前按本地估算得到的提示 token
+	usage := &types.Usage{PromptTokens: 42}
+	chatProvider.SetUsage(usage)
+
+	stream, errWithCode := chatProvider.CreateChatCompletionStream(&types.ChatCompletionRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Stream:    true,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+	})
+	assert.Nil(t, errWithCode)
+	drainStream(stream)
+
+	assert.Equal(t, 42, usage.PromptTokens)
+	assert.Equal(t, 7, usage.CompletionTokens)
+	assert.Equal(t, 49, usage.TotalTokens)
+	assert.True(t, usage.PromptEstimated)
+}
+
+func TestClaudeRelayStreamMissingInputTokens(t *testing.T) {
+	requester.InitHttpClient()
+	ts := newStreamUsageServer(streamWithoutInputTokens).TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	ctx, _ := test.GetContext("POST", "/claude/v1/messages", test.RequestJSONConfig(), nil)
+	claudeProvider, _ := providers.GetProvider(&channel, ctx).(*claude.ClaudeProvider)
+	usage := &types.Usage{PromptTokens: 42}
+	claudeProvider.SetUsage(usage)
+
+	stream, errWithCode := claudeProvider.CreateClaudeChatStream(&claude.ClaudeRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Stream:    true,
+		Messages: []claude.Message{
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+	})
+	assert.Nil(t, errWithCode)
+	drainStream(stream)
+
+	assert.Equal(t, 42, usage.PromptTokens)
+	assert.Equal(t, 7, usage.CompletionTokens)
+	assert.True(t, usage.PromptEstimated)
+}
+
+func TestChatStreamInputTokensReported(t *testing.T) {
+	handler := &claude.ClaudeStreamHandler{
+		Usage:   &types.Usage{PromptTokens: 42},
+		Request: &types.ChatCompletionRequest{Model: "claude-sonnet"},
+		Prefix:  `data: {`,
+	}
+	dataChan := make(chan string, 10)
+	errChan := make(chan error, 10)
+
+	line := []byte(`data: {"type":"message_start","message":{"id":"msg_1","role":"assistant","model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":5}}}`)
+	handler.HandlerStream(&line, dataChan, errChan)
+
+	assert.Equal(t, 5, handler.Usage.PromptTokens)
+	assert.False(t, handler.Usage.PromptEstimated)
+}
+
+var streamFullyCached = []string{
+	`data: {"type":"message_start","message":{"id":"msg_1","role":"assistant","model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":0,"cache_read_input_tokens":100,"cache_creation_input_tokens":20,"output_tokens":1}}}`,
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":3}}`,
+}
+
+func TestStreamFullyCachedPrompt(t *testing.T) {
+	// 提示全部命中缓存时 input_tokens 为 0，使用上游的缓存用量，不按估算计费
+	for name, run := range map[string]func([]string) *types.Usage{
+		"chat":  runClaudeStreamFixture,
+		"relay": runClaudeRelayStreamFixture,
+	} {
+		usage := run(streamFullyCached)
+		assert.False(t, usage.PromptEstimated, name)
+		assert.Zero(t, usage.PromptTokens, name)
+		assert.Equal(t, 100, usage.PromptTokensDetails.CachedReadTokens, name)
+		assert.Equal(t, 20, usage.PromptTokensDetails.CachedWriteTokens, name)
+		assert.Equal(t, 3, usage.CompletionTokens, name)
+	}
+}
+
+func runClaudeStreamFixture(lines []string) *types.Usage {
+	handler := &claude.ClaudeStreamHandler{
+		Usage:   &types.Usage{PromptTokens: 12},
+		Request: &types.ChatCompletionRequest{Model: "claude-3-5-sonnet-20241022"},
+		Prefix:  `data: {`,
+	}
+	dataChan := make(chan string, len(lines)*2)
+	errChan := make(chan error, len(lines))
+	for _, line := range lines {
+		rawLine := []byte(line)
+		handler.HandlerStream(&rawLine, dataChan, errChan)
+	}
+
+	return handler.Usage
+}
+
+func runClaudeRelayStreamFixture(lines []string) *types.Usage {
+	handler := &claude.ClaudeRelayStreamHandler{
+		Usage:     &types.Usage{PromptTokens: 12},
+		ModelName: "claude-3-5-sonnet-20241022",
+		Prefix:    `data: {`,
+	}
+	dataChan := make(chan string, len(lines)*2)
+	errChan := make(chan error, len(lines))
+	for _, line := range lines {
+		rawLine := []byte(line)
+		handler.HandlerStream(&rawLine, dataChan, errChan)
+	}
+
+	return handler.Usage
+}
+
+var streamWithoutFinalUsage = []string{
+	`data: {"type":"message_start","message":{"id":"msg_1","role":"assistant","model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":12,"output_tokens":1}}}`,
+	`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The quick brown fox jumps over the lazy dog."}}`,
+}
+
+var streamWithFinalUsage = []string{
+	`data: {"type":"message_start","message":{"id":"msg_1","role":"assistant","model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":12,"output_tokens":1}}}`,
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The quick brown fox jumps over the lazy dog."}}`,
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":11}}`,
+}
+
+func TestChatStreamWithoutFinalUsage(t *testing.T) {
+	// 流在 message_delta 之前中断，留给上层按已输出的文本估算
+	usage := runClaudeStreamFixture(streamWithoutFinalUsage)
+	assert.Zero(t, usage.CompletionTokens)
+	assert.Equal(t, "The quick brown fox jumps over the lazy dog.", usage.TextBuilder.String())
+
+	usage = runClaudeStreamFixture(streamWithFinalUsage)
+	assert.Equal(t, 11, usage.CompletionTokens)
+}
+
+func TestClaudeRelayStreamWithoutFinalUsage(t *testing.T) {
+	// 原生 /claude/v1/messages 流不能按 message_start 的 output_tokens 计费
+	usage := runClaudeRelayStreamFixture(streamWithoutFinalUsage)
+	assert.Equal(t, 12, usage.PromptTokens)
+	assert.Zero(t, usage.CompletionTokens)
+	assert.NotZero(t, usage.TextBuilder.Len())
+
+	usage = runClaudeRelayStreamFixture(streamWithFinalUsage)
+	assert.Equal(t, 12, usage.PromptTokens)
+	assert.Equal(t, 11, usage.CompletionTokens)
+}
+
+func TestChatStreamNullStopReason(t *testing.T) {
+	requester.InitHttpClient()
+	ts := newStreamUsageServer([]string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"usage":{"input_tokens":10}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":null,"stop_sequence":null},"usage":{"output_tokens":3}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":null,"stop_sequence":null},"usage":{"output_tokens":5}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"max_tokens","stop_sequence":null},"usage":{"output_tokens":6}}`,
+		`{"type":"message_stop"}`,
+	}).TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	ctx, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider, _ := providers.GetProvider(&channel, ctx).(providers_base.ChatInterface)
+	usage := &types.Usage{}
+	chatProvider.SetUsage(usage)
+
+	stream, errWithCode := chatProvider.CreateChatCompletionStream(&types.ChatCompletionRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Stream:    true,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+	})
+	assert.Nil(t, errWithCode)
+
+	var chunks []types.ChatCompletionStreamResponse
+	dataChan, errChan := stream.Recv()
+	for done := false; !done; {
+		select {
+		case data := <-dataChan:
+			var chunk types.ChatCompletionStreamResponse
+			assert.Nil(t, json.Unmarshal([]byte(data), &chunk))
+			chunks = append(chunks, chunk)
+		case <-errChan:
+			done = true
+		}
+	}
+
+	// 中间 stop_reason 为 null 的 message_delta 不产生空块
+	assert.Len(t, chunks, 5)
+
+	// finish_reason 只在最后出现一次
+	finishCount := 0
+	for _, chunk := range chunks {
+		if chunk.Choices[0].FinishReason != nil {
+			finishCount++
+		}
+	}
+	assert.Equal(t, 1, finishCount)
+	last := chunks[len(chunks)-1].Choices[0]
+	assert.NotNil(t, last.FinishReason)
+	assert.Equal(t, types.FinishReasonLength, last.FinishReason)
+	assert.Equal(t, 6, usage.CompletionTokens)
+}
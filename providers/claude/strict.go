@@ -0,0 +1,272 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/retry"
+	"one-api/types"
+	"sort"
+	"strings"
+)
+
+// getStrictToolSchemas 获取请求中 strict: true 的工具 schema
+func getStrictToolSchemas(request *types.ChatCompletionRequest) map[string]any {
+	schemas := make(map[string]any)
+	for _, tool := range request.Tools {
+		if tool == nil || tool.Function.Strict == nil || !*tool.Function.Strict {
+			continue
+		}
+		schemas[tool.Function.Name] = tool.Function.Parameters
+	}
+
+	return schemas
+}
+
+// validateStrictToolUse 校验响应中的 tool_use 输入是否符合 strict 工具的 schema
+func validateStrictToolUse(response *ClaudeResponse, schemas map[string]any) map[string]error {
+	failures := make(map[string]error)
+	for _, content := range response.Content {
+		if content.Type != ContentTypeToolUes {
+			continue
+		}
+		schema, ok := schemas[content.Name]
+		if !ok {
+			continue
+		}
+
+		if err := validateSchema(normalizeSchemaValue(schema), normalizeSchemaValue(content.Input), "input"); err != nil {
+			failures[content.Id] = err
+		}
+	}
+
+	return failures
+}
+
+// StrictSchemaValidationFailedCode strict 工具的输入在重试后仍不符合 schema
+const StrictSchemaValidationFailedCode = "strict_schema_validation_failed"
+
+// createStrictChat 发送请求，若 strict 工具的输入不符合 schema，则附带纠正说明重试一次
+func (p *ClaudeProvider) createStrictChat(claudeRequest *ClaudeRequest, schemas map[string]any) (*ClaudeResponse, *types.OpenAIErrorWithStatusCode) {
+	claudeResponse, errWithCode := p.sendClaudeChat(claudeRequest)
+	if errWithCode != nil || len(schemas) == 0 || !config.ClaudeSettingsInstance.StrictToolValidation {
+		return claudeResponse, errWithCode
+	}
+
+	failures := validateStrictToolUse(claudeResponse, schemas)
+	if len(failures) == 0 {
+		return claudeResponse, nil
+	}
+
+	// strict 重试与渠道重试共用请求的重试预算
+	if !retry.Acquire(p.Context) {
+		return nil, p.strictSchemaError(&claudeResponse.Usage, failures)
+	}
+
+	retryRequest := *claudeRequest
+	retryRequest.Messages = append(append([]Message{}, claudeRequest.Messages...), buildStrictCorrectionMessages(claudeResponse, failures)...)
+
+	retryResponse, errWithCode := p.sendClaudeChat(&retryRequest)
+	if errWithCode != nil {
+		// 第一次请求已产生消耗，按校验失败返回并计费
+		logger.LogWarn(p.Context.Request.Context(), "strict tool retry failed: "+errWithCode.Message)
+		return nil, p.strictSchemaError(&claudeResponse.Usage, failures)
+	}
+
+	addClaudeUsage(&retryResponse.Usage, &claudeResponse.Usage)
+
+	if failures = validateStrictToolUse(retryResponse, schemas); len(failures) > 0 {
+		return nil, p.strictSchemaError(&retryResponse.Usage, failures)
+	}
+
+	return retryResponse, nil
+}
+
+// strictSchemaError 记录已发生请求的用量并返回本地错误，不再切换渠道重试
+func (p *ClaudeProvider) strictSchemaError(cUsage *Usage, failures map[string]error) *types.OpenAIErrorWithStatusCode {
+	if p.Usage != nil && !ClaudeUsageToOpenaiUsage(cUsage, p.Usage) {
+		setPromptTokens(cUsage, p.Usage)
+		p.Usage.CompletionTokens = cUsage.OutputTokens
+		p.Usage.TotalTokens = p.Usage.PromptTokens + p.Usage.CompletionTokens
+	}
+
+	messages := make([]string, 0, len(failures))
+	for _, err := range failures {
+		messages = append(messages, err.Error())
+	}
+	sort.Strings(messages)
+
+	errWithCode := common.StringErrorWrapperLocal("tool input does not match the strict schema: "+strings.Join(messages, "; "), StrictSchemaValidationFailedCode, http.StatusBadGateway)
+	errWithCode.Billed = true
+	return errWithCode
+}
+
+func addClaudeUsage(total *Usage, usage *Usage) {
+	total.InputTokens += usage.InputTokens
+	total.OutputTokens += usage.OutputTokens
+	total.CacheCreationInputTokens += usage.CacheCreationInputTokens
+	total.CacheReadInputTokens += usage.CacheReadInputTokens
+}
+
+// buildStrictCorrectionMessages 构建纠正消息：回放上一次的 assistant 输出，并对每个 tool_use 返回带错误说明的 tool_result
+func buildStrictCorrectionMessages(response *ClaudeResponse, failures map[string]error) []Message {
+	assistantContent := make([]MessageContent, 0, len(response.Content))
+	toolResults := make([]MessageContent, 0)
+	isError := true
+
+	for _, content := range response.Content {
+		switch content.Type {
+		case ContentTypeText:
+			if content.Text == "" {
+				continue
+			}
+			assistantContent = append(assistantContent, MessageContent{Type: ContentTypeText, Text: content.Text})
+		case ContentTypeToolUes:
+			assistantContent = append(assistantContent, MessageContent{
+				Type:  ContentTypeToolUes,
+				Id:    content.Id,
+				Name:  content.Name,
+				Input: content.Input,
+			})
+
+			result := MessageContent{
+				Type:      ContentTypeToolResult,
+				ToolUseId: content.Id,
+				Content:   "Tool call was not executed.",
+			}
+			if err, ok := failures[content.Id]; ok {
+				result.IsError = &isError
+				result.Content = fmt.Sprintf("The tool input does not match the JSON schema: %s. Call the tool again with input that strictly conforms to the schema.", err.Error())
+			}
+			toolResults = append(toolResults, result)
+		}
+	}
+
+	return []Message{
+		{Role: types.ChatMessageRoleAssistant, Content: assistantContent},
+		{Role: types.ChatMessageRoleUser, Content: toolResults},
+	}
+}
+
+// normalizeSchemaValue 将任意值转换为 JSON 解码后的通用结构，便于校验
+func normalizeSchemaValue(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return value
+	}
+
+	return normalized
+}
+
+// validateSchema 按 JSON Schema 的常用子集（type/enum/properties/required/additionalProperties/items）校验数据
+func validateSchema(schema any, value any, path string) error {
+	schemaMap, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if typeValue, ok := schemaMap["type"]; ok && !matchSchemaType(typeValue, value) {
+		return fmt.Errorf("%s: expected type %v", path, typeValue)
+	}
+
+	if enum, ok := schemaMap["enum"].([]any); ok {
+		matched := false
+		for _, item := range enum {
+			if fmt.Sprint(item) == fmt.Sprint(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value is not one of %v", path, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schemaMap["properties"].(map[string]any)
+		if required, ok := schemaMap["required"].([]any); ok {
+			for _, name := range required {
+				key, _ := name.(string)
+				if _, exists := v[key]; !exists {
+					return fmt.Errorf("%s: missing required property %q", path, key)
+				}
+			}
+		}
+
+		for key, item := range v {
+			propertySchema, exists := properties[key]
+			if !exists {
+				if additional, ok := schemaMap["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s: additional property %q is not allowed", path, key)
+				}
+				continue
+			}
+			if err := validateSchema(propertySchema, item, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if items, ok := schemaMap["items"]; ok {
+			for index, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, index)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func matchSchemaType(typeValue any, value any) bool {
+	switch t := typeValue.(type) {
+	case string:
+		return matchSingleSchemaType(t, value)
+	case []any:
+		for _, item := range t {
+			if name, ok := item.(string); ok && matchSingleSchemaType(name, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func matchSingleSchemaType(typeName string, value any) bool {
+	switch strings.ToLower(typeName) {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return true
+	}
+}
@@ -0,0 +1,128 @@
+package claude_test
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providers_base "one-api/providers/base"
+	"one-api/types"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupStrictTestServer(inputs []string) (chatProvider providers_base.ChatInterface, context *gin.Context, calls *int, teardown func()) {
+	requester.InitHttpClient()
+	config.ClaudeSettingsInstance.StrictToolValidation = true
+
+	calls = new(int)
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		input := inputs[*calls]
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"msg_%d","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"tool_use","content":[{"type":"tool_use","id":"toolu_%d","name":"get_weather","input":%s}],"usage":{"input_tokens":10,"output_tokens":5}}`, *calls, *calls, input)
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	context, _ = test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider, _ = providers.GetProvider(&channel, context).(providers_base.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	teardown = func() {
+		ts.Close()
+		config.ClaudeSettingsInstance.StrictToolValidation = false
+	}
+	return
+}
+
+func getStrictChatRequest() *types.ChatCompletionRequest {
+	strict := true
+	return &types.ChatCompletionRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "weather in Paris?"},
+		},
+		Tools: []*types.ChatCompletionTool{
+			{
+				Type: "function",
+				Function: types.ChatCompletionFunction{
+					Name:   "get_weather",
+					Strict: &strict,
+					Parameters: map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"city": map[string]any{"type": "string"},
+							"unit": map[string]any{"type": "string", "enum": []string{"c", "f"}},
+						},
+						"required":             []string{"city", "unit"},
+						"additionalProperties": false,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestStrictToolValidationPass(t *testing.T) {
+	chatProvider, _, calls, teardown := setupStrictTestServer([]string{`{"city":"Paris","unit":"c"}`})
+	defer teardown()
+
+	response, errWithCode := chatProvider.CreateChatCompletion(getStrictChatRequest())
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, `{"city":"Paris","unit":"c"}`, response.Choices[0].Message.ToolCalls[0].Function.Arguments)
+}
+
+func TestStrictToolValidationRetry(t *testing.T) {
+	chatProvider, _, calls, teardown := setupStrictTestServer([]string{`{"city":"Paris","unit":"kelvin"}`, `{"city":"Paris","unit":"c"}`})
+	defer teardown()
+
+	response, errWithCode := chatProvider.CreateChatCompletion(getStrictChatRequest())
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, 2, *calls)
+	assert.Equal(t, `{"city":"Paris","unit":"c"}`, response.Choices[0].Message.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, 20, chatProvider.GetUsage().PromptTokens)
+	assert.Equal(t, 10, chatProvider.GetUsage().CompletionTokens)
+}
+
+func TestStrictToolValidationFailed(t *testing.T) {
+	chatProvider, _, calls, teardown := setupStrictTestServer([]string{`{"city":"Paris"}`, `{"city":"Paris","extra":1}`})
+	defer teardown()
+
+	_, errWithCode := chatProvider.CreateChatCompletion(getStrictChatRequest())
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, 2, *calls)
+	assert.Equal(t, "strict_schema_validation_failed", errWithCode.Code)
+	assert.Equal(t, http.StatusBadGateway, errWithCode.StatusCode)
+	// 本地错误不切换渠道，两次请求的用量都计费
+	assert.True(t, errWithCode.LocalError)
+	assert.True(t, errWithCode.Billed)
+	assert.Equal(t, 20, chatProvider.GetUsage().PromptTokens)
+	assert.Equal(t, 10, chatProvider.GetUsage().CompletionTokens)
+}
+
+func TestStrictToolValidationRetryBudget(t *testing.T) {
+	config.RetryBudgetSeconds = 5
+	defer func() { config.RetryBudgetSeconds = 0 }()
+
+	chatProvider, context, calls, teardown := setupStrictTestServer([]string{`{"city":"Paris"}`, `{"city":"Paris","unit":"c"}`})
+	defer teardown()
+
+	// 请求的重试预算已耗尽时不再发起 strict 重试
+	context.Set("requestStartTime", time.Now().Add(-10*time.Second))
+	_, errWithCode := chatProvider.CreateChatCompletion(getStrictChatRequest())
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, "strict_schema_validation_failed", errWithCode.Code)
+	assert.Equal(t, 10, chatProvider.GetUsage().PromptTokens)
+	assert.Equal(t, 5, chatProvider.GetUsage().CompletionTokens)
+}
@@ -0,0 +1,50 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/types"
+	"strings"
+)
+
+// checkToolChoice tool_choice 指定的工具不在 tools 中时 Claude 会报错，
+// 按配置返回明确的错误，或改为 auto 并记录警告
+func checkToolChoice(ctx context.Context, claudeRequest *ClaudeRequest) *types.OpenAIErrorWithStatusCode {
+	choice := claudeRequest.ToolChoice
+	if choice == nil || choice.Type != "tool" {
+		return nil
+	}
+
+	names := make([]string, 0, len(claudeRequest.Tools))
+	for _, tool := range claudeRequest.Tools {
+		if tool.Name == choice.Name {
+			return nil
+		}
+		names = append(names, tool.Name)
+	}
+
+	message := fmt.Sprintf("tool_choice references tool %q which is not defined in tools", choice.Name)
+	if len(names) == 0 {
+		message = fmt.Sprintf("tool_choice references tool %q but no tools are defined", choice.Name)
+	} else {
+		message += fmt.Sprintf(", available tools: %s", strings.Join(names, ", "))
+	}
+
+	if config.ClaudeSettingsInstance.UndefinedToolChoice != config.UndefinedToolChoiceAuto {
+		return common.StringErrorWrapperLocal(message, "invalid_tool_choice", http.StatusBadRequest)
+	}
+
+	logger.LogWarn(ctx, fmt.Sprintf("%s, downgraded tool_choice to auto", message))
+	// 没有工具时 tool_choice 无意义，直接去掉
+	if len(names) == 0 {
+		claudeRequest.ToolChoice = nil
+		return nil
+	}
+	claudeRequest.ToolChoice = &ToolChoice{Type: "auto", DisableParallelToolUse: choice.DisableParallelToolUse}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package claude_test
+
+import (
+	"net/http"
+	"one-api/common/config"
+	"one-api/providers/claude"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func convertWithToolChoice(tools []*types.ChatCompletionTool, toolName string) (*claude.ClaudeRequest, *types.OpenAIErrorWithStatusCode) {
+	return claude.ConvertFromChatOpenai(&types.ChatCompletionRequest{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hi"},
+		},
+		Tools:      tools,
+		ToolChoice: map[string]any{"type": "function", "function": map[string]any{"name": toolName}},
+	})
+}
+
+var toolChoiceTools = []*types.ChatCompletionTool{
+	{Type: "function", Function: types.ChatCompletionFunction{Name: "get_weather", Parameters: map[string]any{"type": "object"}}},
+	{Type: "function", Function: types.ChatCompletionFunction{Name: "get_time", Parameters: map[string]any{"type": "object"}}},
+}
+
+func TestUndefinedToolChoiceError(t *testing.T) {
+	claudeRequest, errWithCode := convertWithToolChoice(toolChoiceTools, "get_time")
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, &claude.ToolChoice{Type: "tool", Name: "get_time"}, claudeRequest.ToolChoice)
+
+	_, errWithCode = convertWithToolChoice(toolChoiceTools, "get_stock")
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Equal(t, "invalid_tool_choice", errWithCode.Code)
+	assert.Equal(t, `tool_choice references tool "get_stock" which is not defined in tools, available tools: get_weather, get_time`, errWithCode.Message)
+
+	_, errWithCode = convertWithToolChoice(nil, "get_stock")
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, `tool_choice references tool "get_stock" but no tools are defined`, errWithCode.Message)
+}
+
+func TestUndefinedToolChoiceAuto(t *testing.T) {
+	config.ClaudeSettingsInstance.UndefinedToolChoice = config.UndefinedToolChoiceAuto
+	defer func() { config.ClaudeSettingsInstance.UndefinedToolChoice = config.UndefinedToolChoiceError }()
+
+	claudeRequest, errWithCode := convertWithToolChoice(toolChoiceTools, "get_stock")
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, &claude.ToolChoice{Type: "auto"}, claudeRequest.ToolChoice)
+	assert.Len(t, claudeRequest.Tools, 2)
+
+	// 没有定义工具时去掉 tool_choice
+	claudeRequest, errWithCode = convertWithToolChoice(nil, "get_stock")
+	assert.Nil(t, errWithCode)
+	assert.Nil(t, claudeRequest.ToolChoice)
+
+	// 指定的工具存在时不受影响
+	claudeRequest, errWithCode = convertWithToolChoice(toolChoiceTools, "get_weather")
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, &claude.ToolChoice{Type: "tool", Name: "get_weather"}, claudeRequest.ToolChoice)
+}
@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"fmt"
+	"one-api/common/logger"
+)
+
+// normalizeToolCallIds 确保请求中 tool_use 的 id 唯一
+// 部分 Agent 框架会在不同轮次复用 tool_call id，冲突的 id 会被重新分配，
+// 随后的 tool_result 按出现顺序对应到重新分配后的 id，保持调用与结果的关联
+func normalizeToolCallIds(messages []Message) int {
+	seen := make(map[string]bool)
+	pending := make(map[string][]string)
+	pendingMessage := make(map[string]int)
+	remapped := 0
+
+	for messageIndex, message := range messages {
+		contents, ok := message.Content.([]MessageContent)
+		if !ok {
+			continue
+		}
+
+		for index := range contents {
+			content := &contents[index]
+			switch content.Type {
+			case ContentTypeToolUes:
+				if content.Id == "" {
+					continue
+				}
+				originalId := content.Id
+				if seen[originalId] {
+					content.Id = newToolCallId(originalId, seen)
+					remapped++
+				}
+				seen[content.Id] = true
+				// 之前轮次未被结果消费的 id 不再参与关联
+				if pendingMessage[originalId] != messageIndex {
+					pending[originalId] = nil
+					pendingMessage[originalId] = messageIndex
+				}
+				pending[originalId] = append(pending[originalId], content.Id)
+			case ContentTypeToolResult:
+				ids := pending[content.ToolUseId]
+				if len(ids) == 0 {
+					continue
+				}
+				pending[content.ToolUseId] = ids[1:]
+				content.ToolUseId = ids[0]
+			}
+		}
+	}
+
+	if remapped > 0 {
+		logger.SysLog(fmt.Sprintf("claude request contains duplicate tool_call ids, remapped %d", remapped))
+	}
+
+	return remapped
+}
+
+func newToolCallId(id string, seen map[string]bool) string {
+	for n := 1; ; n++ {
+		newId := fmt.Sprintf("%s_%d", id, n)
+		if !seen[newId] {
+			return newId
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/logger"
+	"one-api/types"
+)
+
+// arrangeToolResults 确保每个 tool_result 紧跟在包含对应 tool_use 的 assistant 消息之后
+// OpenAI 客户端可能把工具结果与其他消息交错排列，顺序正确时原样返回，
+// 否则按 tool_use 的顺序重新排列，无法对应的调用或结果返回明确的错误
+func arrangeToolResults(ctx context.Context, messages []Message) ([]Message, *types.OpenAIErrorWithStatusCode) {
+	if toolResultsInOrder(messages) {
+		return messages, nil
+	}
+
+	// 取出所有仅包含 tool_result 的消息，按出现顺序放入待匹配列表
+	results := make([]MessageContent, 0)
+	remaining := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		if contents, ok := toolResultContents(message); ok {
+			results = append(results, contents...)
+			continue
+		}
+		remaining = append(remaining, message)
+	}
+
+	arranged := make([]Message, 0, len(messages))
+	for index, message := range remaining {
+		arranged = append(arranged, message)
+
+		toolUseIds := getToolUseIds(message)
+		if len(toolUseIds) == 0 {
+			continue
+		}
+
+		matched := make([]MessageContent, 0, len(toolUseIds))
+		for _, id := range toolUseIds {
+			resultIndex := findToolResult(results, id)
+			if resultIndex < 0 {
+				// 最后一条 assistant 消息之后允许没有工具结果
+				if index == len(remaining)-1 {
+					continue
+				}
+				return nil, common.StringErrorWrapperLocal(fmt.Sprintf("tool call %s has no matching tool result", id), "invalid_request_error", http.StatusBadRequest)
+			}
+			matched = append(matched, results[resultIndex])
+			results = append(results[:resultIndex], results[resultIndex+1:]...)
+		}
+
+		if len(matched) > 0 {
+			arranged = append(arranged, Message{Role: types.ChatMessageRoleUser, Content: matched})
+		}
+	}
+
+	if len(results) > 0 {
+		return nil, common.StringErrorWrapperLocal(fmt.Sprintf("tool result for tool_call_id %s has no matching tool call", results[0].ToolUseId), "invalid_request_error", http.StatusBadRequest)
+	}
+
+	logger.LogWarn(ctx, "claude request tool results are out of order, rearranged")
+
+	return arranged, nil
+}
+
+// toolResultsInOrder 判断每个 tool_use 之后的连续工具结果消息是否恰好包含全部对应结果
+func toolResultsInOrder(messages []Message) bool {
+	pending := make(map[string]bool)
+	for _, message := range messages {
+		if contents, ok := toolResultContents(message); ok {
+			for _, content := range contents {
+				if !pending[content.ToolUseId] {
+					return false
+				}
+				delete(pending, content.ToolUseId)
+			}
+			continue
+		}
+
+		if len(pending) > 0 {
+			return false
+		}
+		for _, id := range getToolUseIds(message) {
+			pending[id] = true
+		}
+	}
+
+	return true
+}
+
+// toolResultContents 返回仅由 tool_result 组成的消息内容
+func toolResultContents(message Message) ([]MessageContent, bool) {
+	contents, ok := message.Content.([]MessageContent)
+	if !ok || len(contents) == 0 || message.Role != types.ChatMessageRoleUser {
+		return nil, false
+	}
+
+	for _, content := range contents {
+		if content.Type != ContentTypeToolResult {
+			return nil, false
+		}
+	}
+
+	return contents, true
+}
+
+func getToolUseIds(message Message) []string {
+	contents, ok := message.Content.([]MessageContent)
+	if !ok || message.Role != types.ChatMessageRoleAssistant {
+		return nil
+	}
+
+	ids := make([]string, 0)
+	for _, content := range contents {
+		if content.Type == ContentTypeToolUes && content.Id != "" {
+			ids = append(ids, content.Id)
+		}
+	}
+
+	return ids
+}
+
+func findToolResult(results []MessageContent, toolUseId string) int {
+	for index, result := range results {
+		if result.ToolUseId == toolUseId {
+			return index
+		}
+	}
+
+	return -1
+}
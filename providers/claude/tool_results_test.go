@@ -0,0 +1,177 @@
+package claude_test
+
+import (
+	"net/http"
+	"one-api/common/config"
+	"one-api/providers/claude"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func toolCallMessage(ids ...string) types.ChatCompletionMessage {
+	toolCalls := make([]*types.ChatCompletionToolCalls, 0, len(ids))
+	for _, id := range ids {
+		toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
+			Id:       id,
+			Type:     "function",
+			Function: &types.ChatCompletionToolCallsFunction{Name: "search", Arguments: `{}`},
+		})
+	}
+	return types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, ToolCalls: toolCalls}
+}
+
+func toolResultMessage(id, content string) types.ChatCompletionMessage {
+	return types.ChatCompletionMessage{Role: types.ChatMessageRoleTool, ToolCallID: id, Content: content}
+}
+
+func convertToolMessages(messages ...types.ChatCompletionMessage) (*claude.ClaudeRequest, *types.OpenAIErrorWithStatusCode) {
+	return claude.ConvertFromChatOpenai(&types.ChatCompletionRequest{
+		Model:    "claude-3-5-sonnet-20241022",
+		Messages: messages,
+	})
+}
+
+func TestArrangeToolResultsInOrder(t *testing.T) {
+	claudeRequest, errWithCode := convertToolMessages(
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "search twice"},
+		toolCallMessage("call_1", "call_2"),
+		toolResultMessage("call_2", "second"),
+		toolResultMessage("call_1", "first"),
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "thanks"},
+	)
+	assert.Nil(t, errWithCode)
+
+	// 顺序正确时保持原样
+	assert.Len(t, claudeRequest.Messages, 5)
+	assert.Equal(t, "call_2", claudeRequest.Messages[2].Content.([]claude.MessageContent)[0].ToolUseId)
+	assert.Equal(t, "call_1", claudeRequest.Messages[3].Content.([]claude.MessageContent)[0].ToolUseId)
+}
+
+func TestArrangeToolResultsRepaired(t *testing.T) {
+	claudeRequest, errWithCode := convertToolMessages(
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "search twice"},
+		toolCallMessage("call_1", "call_2"),
+		toolResultMessage("call_2", "second"),
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "thanks"},
+		toolResultMessage("call_1", "first"),
+	)
+	assert.Nil(t, errWithCode)
+
+	assert.Len(t, claudeRequest.Messages, 4)
+	results := claudeRequest.Messages[2].Content.([]claude.MessageContent)
+	assert.Equal(t, types.ChatMessageRoleUser, claudeRequest.Messages[2].Role)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "call_1", results[0].ToolUseId)
+	assert.Equal(t, "first", results[0].Content)
+	assert.Equal(t, "call_2", results[1].ToolUseId)
+	assert.Equal(t, "thanks", claudeRequest.Messages[3].Content.([]claude.MessageContent)[0].Text)
+}
+
+func TestArrangeToolResultsDisabled(t *testing.T) {
+	config.ClaudeSettingsInstance.ReorderToolResults = false
+	defer func() { config.ClaudeSettingsInstance.ReorderToolResults = true }()
+
+	// 关闭后原样发送，不重新排列也不报错
+	claudeRequest, errWithCode := convertToolMessages(
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "search twice"},
+		toolCallMessage("call_1", "call_2"),
+		toolResultMessage("call_2", "second"),
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "thanks"},
+		toolResultMessage("call_1", "first"),
+	)
+	assert.Nil(t, errWithCode)
+	assert.Len(t, claudeRequest.Messages, 5)
+	assert.Equal(t, "call_2", claudeRequest.Messages[2].Content.([]claude.MessageContent)[0].ToolUseId)
+	assert.Equal(t, "call_1", claudeRequest.Messages[4].Content.([]claude.MessageContent)[0].ToolUseId)
+}
+
+func TestArrangeToolResultsUnmatched(t *testing.T) {
+	_, errWithCode := convertToolMessages(
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "search"},
+		toolCallMessage("call_1"),
+		toolResultMessage("call_9", "unknown"),
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "thanks"},
+	)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Contains(t, errWithCode.Message, "tool call call_1 has no matching tool result")
+
+	_, errWithCode = convertToolMessages(
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "search"},
+		toolResultMessage("call_9", "unknown"),
+		toolCallMessage("call_1"),
+		toolResultMessage("call_1", "first"),
+	)
+	assert.NotNil(t, errWithCode)
+	assert.Contains(t, errWithCode.Message, "tool_call_id call_9 has no matching tool call")
+}
+
+func TestConvertToolCallHistory(t *testing.T) {
+	claudeRequest, errWithCode := convertToolMessages(
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "weather in Paris and Tokyo?"},
+		types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleAssistant,
+			Content: "Let me check both cities.",
+			ToolCalls: []*types.ChatCompletionToolCalls{
+				{Id: "call_paris", Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "get_weather", Arguments: `{"city":"Paris","days":2,"units":{"temp":"c"}}`}},
+				{Id: "call_tokyo", Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "get_weather", Arguments: `{"city":"Tokyo"}`}},
+			},
+		},
+		toolResultMessage("call_paris", "18C"),
+		toolResultMessage("call_tokyo", "22C"),
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: "Paris 18C, Tokyo 22C."},
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "and the time?"},
+		types.ChatCompletionMessage{
+			Role: types.ChatMessageRoleAssistant,
+			ToolCalls: []*types.ChatCompletionToolCalls{
+				{Id: "call_time", Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "get_time", Arguments: ""}},
+			},
+		},
+		toolResultMessage("call_time", "12:00"),
+	)
+	assert.Nil(t, errWithCode)
+	assert.Len(t, claudeRequest.Messages, 8)
+
+	// assistant 的文本与工具调用重建为同一轮中的 text + tool_use
+	assistant := claudeRequest.Messages[1]
+	assert.Equal(t, types.ChatMessageRoleAssistant, assistant.Role)
+	blocks := assistant.Content.([]claude.MessageContent)
+	assert.Len(t, blocks, 3)
+	assert.Equal(t, "text", blocks[0].Type)
+	assert.Equal(t, "Let me check both cities.", blocks[0].Text)
+	assert.Equal(t, claude.ContentTypeToolUes, blocks[1].Type)
+	assert.Equal(t, "call_paris", blocks[1].Id)
+	assert.Equal(t, "get_weather", blocks[1].Name)
+	assert.Equal(t, map[string]any{"city": "Paris", "days": float64(2), "units": map[string]any{"temp": "c"}}, blocks[1].Input)
+	assert.Equal(t, "call_tokyo", blocks[2].Id)
+
+	// 工具结果与 tool_use 的 id 对应
+	assert.Equal(t, claude.ContentTypeToolResult, claudeRequest.Messages[2].Content.([]claude.MessageContent)[0].Type)
+	assert.Equal(t, "call_paris", claudeRequest.Messages[2].Content.([]claude.MessageContent)[0].ToolUseId)
+	assert.Equal(t, "call_tokyo", claudeRequest.Messages[3].Content.([]claude.MessageContent)[0].ToolUseId)
+
+	// 空参数解析为空对象
+	timeCall := claudeRequest.Messages[6].Content.([]claude.MessageContent)
+	assert.Len(t, timeCall, 1)
+	assert.Equal(t, "call_time", timeCall[0].Id)
+	assert.Equal(t, map[string]any{}, timeCall[0].Input)
+	assert.Equal(t, "call_time", claudeRequest.Messages[7].Content.([]claude.MessageContent)[0].ToolUseId)
+}
+
+func TestConvertToolCallHistoryInvalidArguments(t *testing.T) {
+	_, errWithCode := convertToolMessages(
+		types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: "search"},
+		types.ChatCompletionMessage{
+			Role: types.ChatMessageRoleAssistant,
+			ToolCalls: []*types.ChatCompletionToolCalls{
+				{Id: "call_1", Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "search", Arguments: `{"q":`}},
+			},
+		},
+		toolResultMessage("call_1", "result"),
+	)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Contains(t, errWithCode.Message, "invalid arguments for tool call call_1")
+}
@@ -56,7 +56,7 @@ type ClaudeErrorInfo struct {
 }
 
 type ClaudeMetadata struct {
-	UserId string `json:"user_id"`
+	UserId string `json:"user_id,omitempty"`
 }
 
 type ResContent struct {
@@ -117,20 +117,23 @@ type Message struct {
 }
 
 type ClaudeRequest struct {
-	Model         string      `json:"model,omitempty"`
-	System        any         `json:"system,omitempty"`
-	Messages      []Message   `json:"messages"`
-	MaxTokens     int         `json:"max_tokens"`
-	StopSequences []string    `json:"stop_sequences,omitempty"`
-	Temperature   *float64    `json:"temperature,omitempty"`
-	TopP          *float64    `json:"top_p,omitempty"`
-	TopK          *int        `json:"top_k,omitempty"`
-	Tools         []Tools     `json:"tools,omitempty"`
-	ToolChoice    *ToolChoice `json:"tool_choice,omitempty"`
-	Thinking      *Thinking   `json:"thinking,omitempty"`
-	McpServers    any         `json:"mcp_servers,omitempty"`
-	//ClaudeMetadata    `json:"metadata,omitempty"`
-	Stream bool `json:"stream,omitempty"`
+	Model         string          `json:"model,omitempty"`
+	System        any             `json:"system,omitempty"`
+	Messages      []Message       `json:"messages"`
+	MaxTokens     int             `json:"max_tokens"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	TopP          *float64        `json:"top_p,omitempty"`
+	TopK          *int            `json:"top_k,omitempty"`
+	Tools         []Tools         `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice     `json:"tool_choice,omitempty"`
+	Thinking      *Thinking       `json:"thinking,omitempty"`
+	McpServers    any             `json:"mcp_servers,omitempty"`
+	Metadata      *ClaudeMetadata `json:"metadata,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+
+	// 本次请求不使用提示词缓存，移除 cache_control 标记和缓存相关的 beta 请求头
+	DisablePromptCache bool `json:"-"`
 }
 
 type Thinking struct {
@@ -203,11 +206,13 @@ type ClaudeStreamResponse struct {
 }
 
 type ContentBlock struct {
-	Type  string `json:"type"`
-	Id    string `json:"id"`
-	Name  string `json:"name,omitempty"`
-	Input any    `json:"input,omitempty"`
-	Text  string `json:"text,omitempty"`
+	Type      string `json:"type"`
+	Id        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	Input     any    `json:"input,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Content   any    `json:"content,omitempty"`
+	ToolUseId string `json:"tool_use_id,omitempty"`
 }
 
 type ModelListResponse struct {
@@ -12,7 +12,7 @@ import (
 	"one-api/providers/baidu"
 	"one-api/providers/base"
 	"one-api/providers/bedrock"
-	"one-api/providers/claude"
+	_ "one-api/providers/claude"
 	"one-api/providers/cloudflareAI"
 	"one-api/providers/cohere"
 	"one-api/providers/coze"
@@ -45,23 +45,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// 定义供应商工厂接口
-type ProviderFactory interface {
-	Create(Channel *model.Channel) base.ProviderInterface
-}
-
-// 创建全局的供应商工厂映射
-var providerFactories = make(map[int]ProviderFactory)
+type ProviderFactory = base.ProviderFactory
 
-// 在程序启动时，添加所有的供应商工厂
+// 在程序启动时，注册内置的供应商工厂；已迁移的供应商（如 claude）在各自包的 init 中自行注册
 func init() {
-	providerFactories = map[int]ProviderFactory{
+	builtinFactories := map[int]ProviderFactory{
 		config.ChannelTypeOpenAI:          openai.OpenAIProviderFactory{},
 		config.ChannelTypeAzure:           azure.AzureProviderFactory{},
 		config.ChannelTypeAli:             ali.AliProviderFactory{},
 		config.ChannelTypeTencent:         tencent.TencentProviderFactory{},
 		config.ChannelTypeBaidu:           baidu.BaiduProviderFactory{},
-		config.ChannelTypeAnthropic:       claude.ClaudeProviderFactory{},
 		config.ChannelTypePaLM:            palm.PalmProviderFactory{},
 		config.ChannelTypeZhipu:           zhipu.ZhipuProviderFactory{},
 		config.ChannelTypeXunfei:          xunfei.XunfeiProviderFactory{},
@@ -94,13 +87,17 @@ func init() {
 		config.ChannelTypeAzureV1:         azure_v1.AzureV1ProviderFactory{},
 		config.ChannelTypeXAI:             xAI.XAIProviderFactory{},
 	}
+
+	for channelType, factory := range builtinFactories {
+		base.RegisterProviderFactory(channelType, factory)
+	}
 }
 
 // 获取供应商
 func GetProvider(channel *model.Channel, c *gin.Context) base.ProviderInterface {
-	factory, ok := providerFactories[channel.Type]
+	factory, err := base.GetProviderFactory(channel.Type)
 	var provider base.ProviderInterface
-	if !ok {
+	if err != nil {
 		// 处理未找到的供应商工厂
 		baseURL := channel.GetBaseURL()
 		if baseURL == "" {
@@ -2,6 +2,8 @@ package relay
 
 import (
 	"encoding/json"
+	"net/http"
+	"one-api/common/config"
 	"one-api/model"
 	"one-api/relay/relay_util"
 	"one-api/types"
@@ -14,13 +16,15 @@ import (
 )
 
 type relayBase struct {
-	c              *gin.Context
-	provider       providersBase.ProviderInterface
-	originalModel  string
-	modelName      string
-	otherArg       string
-	allowHeartbeat bool
-	heartbeat      *relay_util.Heartbeat
+	c             *gin.Context
+	provider      providersBase.ProviderInterface
+	originalModel string
+	// 余额不足时降级后的模型，originalModel 仍保留客户端请求的模型
+	downgradedModel string
+	modelName       string
+	otherArg        string
+	allowHeartbeat  bool
+	heartbeat       *relay_util.Heartbeat
 
 	firstResponseTime time.Time
 }
@@ -53,7 +57,25 @@ func (r *relayBase) IsStream() bool {
 }
 
 func (r *relayBase) setProvider(modelName string) error {
-	provider, modelName, fail := GetProvider(r.c, modelName)
+	// 模型限制按客户端请求的模型检查
+	if modelName != "" {
+		if err := r.checkLimitModel(modelName); err != nil {
+			r.c.AbortWithStatus(http.StatusNotFound)
+			return err
+		}
+	}
+
+	// 降级只在首次选择渠道时判断，重试沿用同一个降级模型
+	if r.downgradedModel == "" {
+		if downgradeModel := applyModelDowngrade(r.c, modelName); downgradeModel != modelName {
+			r.downgradedModel = downgradeModel
+		}
+	}
+	if r.downgradedModel != "" {
+		modelName = r.downgradedModel
+	}
+
+	provider, modelName, fail := getChannelProvider(r.c, modelName)
 	if fail != nil {
 		return fail
 	}
@@ -65,6 +87,21 @@ func (r *relayBase) setProvider(modelName string) error {
 	return nil
 }
 
+// checkLimitModel 废弃模型被替换时，客户端请求的模型或替代模型在令牌允许列表中均可
+func (r *relayBase) checkLimitModel(modelName string) error {
+	err := checkLimitModel(r.c, modelName)
+	if err == nil {
+		return nil
+	}
+
+	deprecatedModel := r.c.GetString("deprecated_model")
+	if deprecatedModel != "" && modelName == r.originalModel && checkLimitModel(r.c, deprecatedModel) == nil {
+		return nil
+	}
+
+	return err
+}
+
 func (r *relayBase) getOtherArg() string {
 	return r.otherArg
 }
@@ -76,7 +113,12 @@ func (r *relayBase) setOriginalModel(modelName string) {
 		r.otherArg = parts[1]
 	}
 
-	r.originalModel = parts[0]
+	// 客户端使用展示名称时还原为真实模型，响应中再替换回展示名称
+	r.originalModel = resolveModelDisplayName(r.c, parts[0])
+	r.originalModel = applyModelDeprecation(r.c, r.originalModel)
+	if displayName := getModelDisplayName(r.c, r.originalModel); displayName != "" {
+		r.c.Set(config.GinModelDisplayNameKey, displayName)
+	}
 }
 
 func (r *relayBase) getContext() *gin.Context {
@@ -95,6 +137,9 @@ func (r *relayBase) getModelName() string {
 	billingOriginalModel := r.c.GetBool("billing_original_model")
 
 	if billingOriginalModel {
+		if r.downgradedModel != "" {
+			return r.downgradedModel
+		}
 		return r.originalModel
 	}
 	return r.modelName
@@ -10,7 +10,9 @@ import (
 	"one-api/common/config"
 	"one-api/common/requester"
 	"one-api/common/utils"
+	"one-api/model"
 	providersBase "one-api/providers/base"
+	"one-api/relay/relay_util"
 	"one-api/safty"
 	"one-api/types"
 	"time"
@@ -21,6 +23,9 @@ import (
 type relayChat struct {
 	relayBase
 	chatRequest types.ChatCompletionRequest
+	// 分组禁用流式时以非流式请求上游，响应包装为单个流式块
+	wrapStream        bool
+	wrapStreamOptions *types.StreamOptions
 }
 
 func NewRelayChat(c *gin.Context) *relayChat {
@@ -50,6 +55,8 @@ func (r *relayChat) setRequest() error {
 		r.chatRequest.StreamOptions = nil
 	}
 
+	r.chatRequest.DisablePromptCache = isPromptCacheDisabled(r.c)
+
 	r.setOriginalModel(r.chatRequest.Model)
 
 	otherArg := r.getOtherArg()
@@ -70,6 +77,19 @@ func (r *relayChat) IsStream() bool {
 	return r.chatRequest.Stream
 }
 
+// supportsStreamCostLimit 输出流经过 StreamCostLimit 包装，超出上限时中止
+func (r *relayChat) supportsStreamCostLimit() bool {
+	return true
+}
+
+func (r *relayChat) wrapNonStream() {
+	r.wrapStream = true
+	r.wrapStreamOptions = r.chatRequest.StreamOptions
+	r.chatRequest.Stream = false
+	r.chatRequest.StreamOptions = nil
+	removeCachedBodyStream(r.c)
+}
+
 func (r *relayChat) getPromptTokens() (int, error) {
 	channel := r.provider.GetChannel()
 	return common.CountTokenMessages(r.chatRequest.Messages, r.modelName, channel.PreCost), nil
@@ -117,24 +137,43 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 		}
 	}
 
+	responseWatermark := r.getWatermark()
+
 	if r.chatRequest.Stream {
-		var response requester.StreamReaderInterface[string]
-		response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
-		if err != nil {
-			return
-		}
+		for attempt := 0; ; attempt++ {
+			var response requester.StreamReaderInterface[string]
+			response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
+			if err != nil {
+				return
+			}
 
-		if r.heartbeat != nil {
-			r.heartbeat.Stop()
-		}
+			if r.shouldBufferToolCalls() {
+				response = relay_util.NewToolCallBufferStream(response)
+			}
 
-		doneStr := func() string {
-			return r.getUsageResponse()
-		}
+			if responseWatermark != "" {
+				response = relay_util.NewWatermarkStream(response, responseWatermark)
+			}
 
-		var firstResponseTime time.Time
-		firstResponseTime, err = responseStreamClient(r.c, response, doneStr)
-		r.SetFirstResponseTime(firstResponseTime)
+			if costLimit, ok := utils.GetGinValue[*relay_util.StreamCostLimit](r.c, config.GinStreamCostLimitKey); ok {
+				response = costLimit.Wrap(response)
+			}
+
+			if r.heartbeat != nil {
+				r.heartbeat.Stop()
+			}
+
+			doneStr := func() string {
+				return r.getUsageResponse()
+			}
+
+			var firstResponseTime time.Time
+			firstResponseTime, err = responseStreamClient(r.c, response, doneStr)
+			r.SetFirstResponseTime(firstResponseTime)
+			if !shouldRetryStreamReset(r.c, err, attempt) {
+				break
+			}
+		}
 	} else {
 		var response *types.ChatCompletionResponse
 		response, err = chatProvider.CreateChatCompletion(&r.chatRequest)
@@ -146,8 +185,15 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 			r.heartbeat.Stop()
 		}
 
-		err = responseJsonClient(r.c, response)
+		if responseWatermark != "" {
+			relay_util.WatermarkChatResponse(response, responseWatermark)
+		}
 
+		if r.wrapStream {
+			err = responseWrappedStreamClient(r.c, response.ToStreamResponse(), r.getUsageResponse)
+		} else {
+			err = responseJsonClient(r.c, response)
+		}
 	}
 
 	if err != nil {
@@ -157,8 +203,36 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 	return
 }
 
+// shouldBufferToolCalls 渠道或令牌开启后，流式响应中的工具调用完整后一次性输出
+func (r *relayChat) shouldBufferToolCalls() bool {
+	if len(r.chatRequest.Tools) == 0 && len(r.chatRequest.Functions) == 0 {
+		return false
+	}
+
+	if r.provider.GetChannel().BufferToolCalls {
+		return true
+	}
+
+	tokenSetting, ok := utils.GetGinValue[*model.TokenSetting](r.c, "token_setting")
+	return ok && tokenSetting != nil && tokenSetting.BufferToolCalls
+}
+
+// getWatermark 要求 JSON 输出的请求不追加水印
+func (r *relayChat) getWatermark() string {
+	if isJsonResponseFormat(r.chatRequest.ResponseFormat) {
+		return ""
+	}
+
+	return getResponseWatermark(r.c)
+}
+
 func (r *relayChat) getUsageResponse() string {
-	if r.chatRequest.StreamOptions != nil && r.chatRequest.StreamOptions.IncludeUsage {
+	streamOptions := r.chatRequest.StreamOptions
+	if r.wrapStream {
+		streamOptions = r.wrapStreamOptions
+	}
+
+	if streamOptions != nil && streamOptions.IncludeUsage {
 		usageResponse := types.ChatCompletionStreamResponse{
 			ID:      fmt.Sprintf("chatcmpl-%s", utils.GetUUID()),
 			Object:  "chat.completion.chunk",
@@ -211,7 +285,12 @@ func (r *relayChat) compatibleSend(resProvider providersBase.ResponsesInterface)
 		if r.heartbeat != nil {
 			r.heartbeat.Stop()
 		}
-		err = responseJsonClient(r.c, response.ToChat())
+
+		if r.wrapStream {
+			err = responseWrappedStreamClient(r.c, response.ToChat().ToStreamResponse(), r.getUsageResponse)
+		} else {
+			err = responseJsonClient(r.c, response.ToChat())
+		}
 	}
 
 	if err != nil {
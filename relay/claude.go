@@ -7,9 +7,11 @@ import (
 	"one-api/common/config"
 	"one-api/common/requester"
 	"one-api/providers/claude"
+	"one-api/relay/relay_util"
 	"one-api/safty"
 	"one-api/types"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,6 +40,14 @@ func (r *relayClaudeOnly) setRequest() error {
 	if err := common.UnmarshalBodyReusable(r.c, r.claudeRequest); err != nil {
 		return err
 	}
+	// metadata 中仅 user_id 为 Claude 原生字段，其余内容只记录到日志
+	if r.claudeRequest.Metadata != nil && r.claudeRequest.Metadata.UserId == "" {
+		r.claudeRequest.Metadata = nil
+	}
+	if isPromptCacheDisabled(r.c) {
+		r.claudeRequest.DisablePromptCache = true
+		claude.StripCacheControl(r.claudeRequest)
+	}
 	r.setOriginalModel(r.claudeRequest.Model)
 	return nil
 }
@@ -78,22 +88,34 @@ func (r *relayClaudeOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 		}
 	}
 
+	responseWatermark := getResponseWatermark(r.c)
+
 	if r.claudeRequest.Stream {
-		var response requester.StreamReaderInterface[string]
-		response, err = chatProvider.CreateClaudeChatStream(r.claudeRequest)
-		if err != nil {
-			return
-		}
+		for attempt := 0; ; attempt++ {
+			var response requester.StreamReaderInterface[string]
+			response, err = chatProvider.CreateClaudeChatStream(r.claudeRequest)
+			if err != nil {
+				return
+			}
 
-		if r.heartbeat != nil {
-			r.heartbeat.Stop()
-		}
+			if responseWatermark != "" {
+				response = relay_util.NewClaudeWatermarkStream(response, responseWatermark)
+			}
 
-		doneStr := func() string {
-			return ""
+			if r.heartbeat != nil {
+				r.heartbeat.Stop()
+			}
+
+			doneStr := func() string {
+				return ""
+			}
+			var firstResponseTime time.Time
+			firstResponseTime, err = responseGeneralStreamClient(r.c, response, doneStr, r.HandleStreamError)
+			r.SetFirstResponseTime(firstResponseTime)
+			if !shouldRetryStreamReset(r.c, err, attempt) {
+				break
+			}
 		}
-		firstResponseTime := responseGeneralStreamClient(r.c, response, doneStr)
-		r.SetFirstResponseTime(firstResponseTime)
 	} else {
 		var response *claude.ClaudeResponse
 		response, err = chatProvider.CreateClaudeChat(r.claudeRequest)
@@ -105,6 +127,10 @@ func (r *relayClaudeOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 			r.heartbeat.Stop()
 		}
 
+		if responseWatermark != "" {
+			watermarkClaudeResponse(response, responseWatermark)
+		}
+
 		openErr := responseJsonClient(r.c, response)
 
 		if openErr != nil {
@@ -118,6 +144,16 @@ func (r *relayClaudeOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 	return
 }
 
+// watermarkClaudeResponse 在第一个文本块末尾追加水印
+func watermarkClaudeResponse(response *claude.ClaudeResponse, responseWatermark string) {
+	for i := range response.Content {
+		if response.Content[i].Type == "text" && response.Content[i].Text != "" {
+			response.Content[i].Text += responseWatermark
+			return
+		}
+	}
+}
+
 func (r *relayClaudeOnly) GetError(err *types.OpenAIErrorWithStatusCode) (int, any) {
 	newErr := FilterOpenAIErr(r.c, err)
 
@@ -11,14 +11,18 @@ import (
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/requester"
+	"one-api/common/retry"
 	"one-api/common/utils"
+	"one-api/common/watermark"
 	"one-api/controller"
 	"one-api/metrics"
 	"one-api/model"
 	"one-api/providers"
 	providersBase "one-api/providers/base"
+	"one-api/relay/relay_util"
 	"one-api/types"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -99,6 +103,282 @@ func checkLimitModel(c *gin.Context, modelName string) (error error) {
 	return fmt.Errorf("Model %s is not supported for current token", modelName)
 }
 
+// applyModelDowngrade 用户剩余额度低于配置阈值时将模型降级为更便宜的模型，并通过响应头告知客户端
+func applyModelDowngrade(c *gin.Context, modelName string) string {
+	if len(config.ModelDowngradeSettingsInstance.Rules) == 0 {
+		return modelName
+	}
+
+	userQuota, err := model.CacheGetUserQuota(c.GetInt("id"))
+	if err != nil {
+		return modelName
+	}
+
+	downgradeModel := config.ModelDowngradeSettingsInstance.GetDowngradeModel(modelName, userQuota)
+	if downgradeModel == "" {
+		return modelName
+	}
+
+	logger.LogInfo(c.Request.Context(), fmt.Sprintf("user quota %d is low, downgrade model %s to %s", userQuota, modelName, downgradeModel))
+	c.Set("downgraded_from", modelName)
+	c.Header("X-Model-Downgraded-From", modelName)
+
+	return downgradeModel
+}
+
+// applyModelDeprecation 将已废弃的模型替换为配置的替代模型，记录审计日志并按配置通过响应头提示客户端
+func applyModelDeprecation(c *gin.Context, modelName string) string {
+	replacement := config.ModelDeprecationSettingsInstance.GetReplacementModel(modelName)
+	if replacement == "" {
+		return modelName
+	}
+
+	logger.LogWarn(c.Request.Context(), fmt.Sprintf("model %s is deprecated, user %d token %d substituted with %s", modelName, c.GetInt("id"), c.GetInt("token_id"), replacement))
+	c.Set("deprecated_model", modelName)
+	if config.ModelDeprecationSettingsInstance.WarningHeader {
+		// 响应头对客户端可见，配置了展示名称时不暴露真实模型
+		deprecatedName, replacementName := displayModelName(c, modelName), displayModelName(c, replacement)
+		c.Header("X-Model-Deprecated", deprecatedName)
+		c.Header("Warning", fmt.Sprintf(`299 - "model %s is deprecated, use %s instead"`, deprecatedName, replacementName))
+	}
+
+	return replacement
+}
+
+// estimateCompletionTokens 流式响应中断或上游未返回最终用量时，按已输出的内容估算补全 token，并标记为估算
+func estimateCompletionTokens(usage *types.Usage, modelName string) {
+	if usage.CompletionTokens != 0 || usage.TextBuilder.Len() == 0 {
+		return
+	}
+
+	usage.CompletionTokens = common.CountTokenText(usage.TextBuilder.String(), modelName)
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	usage.Estimated = true
+}
+
+// recountPromptTokens 上游未返回提示 token 时，先通过 count_tokens 获取准确值，失败或超时时保留本地估算
+// count_tokens 返回的是包含缓存部分的总数，已有缓存用量时不重新计算，避免按完整输入价格重复计费
+func recountPromptTokens(c *gin.Context, provider providersBase.ProviderInterface, usage *types.Usage) {
+	if !usage.PromptEstimated || !config.ClaudeSettingsInstance.CountTokensFallback {
+		return
+	}
+
+	if usage.PromptTokensDetails.CachedReadTokens > 0 || usage.PromptTokensDetails.CachedWriteTokens > 0 {
+		return
+	}
+
+	counter, ok := provider.(providersBase.PromptTokenCounter)
+	if !ok {
+		return
+	}
+
+	// 客户端可能已断开，不跟随请求的上下文
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ClaudeSettingsInstance.CountTokensTimeout)*time.Millisecond)
+	defer cancel()
+
+	promptTokens, err := counter.CountPromptTokens(ctx)
+	if err != nil || promptTokens <= 0 {
+		logger.LogWarn(c.Request.Context(), fmt.Sprintf("count_tokens failed, using estimated prompt tokens %d: %v", usage.PromptTokens, err))
+		return
+	}
+
+	usage.PromptTokens = promptTokens
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	usage.PromptEstimated = false
+}
+
+// acquireStreamSlot 流式请求占用用户和令牌的并发名额，非流式请求不受限制
+func acquireStreamSlot(c *gin.Context, modelName string) (release func(), errWithCode *types.OpenAIErrorWithStatusCode) {
+	if !c.GetBool("is_stream") {
+		return func() {}, nil
+	}
+
+	return relay_util.AcquireStreamSlot(c.GetInt("id"), c.GetInt("token_id"), modelName)
+}
+
+// 客户端通过该请求头要求本次请求不使用提示词缓存，优先于全局和渠道的缓存配置
+const disablePromptCacheHeader = "X-Disable-Prompt-Cache"
+
+func isPromptCacheDisabled(c *gin.Context) bool {
+	disabled, err := strconv.ParseBool(c.GetHeader(disablePromptCacheHeader))
+	return err == nil && disabled
+}
+
+// getRequestGroup 获取本次请求生效的分组，令牌指定的分组优先于用户分组
+func getRequestGroup(c *gin.Context) string {
+	group := c.GetString("token_group")
+	if group == "" {
+		group = c.GetString("group")
+	}
+	return group
+}
+
+// getResponseWatermark 分组开启响应水印时，返回编码了令牌 ID 的水印，否则返回空字符串
+func getResponseWatermark(c *gin.Context) string {
+	if !config.ResponseWatermarkEnabled {
+		return ""
+	}
+
+	tokenId := c.GetInt("token_id")
+	if tokenId <= 0 || !model.GlobalUserGroupRatio.IsWatermarkEnabled(getRequestGroup(c)) {
+		return ""
+	}
+
+	return watermark.Encode(tokenId)
+}
+
+// isJsonResponseFormat 要求 JSON 输出时不能追加水印，否则会破坏结构化输出
+func isJsonResponseFormat(format *types.ChatCompletionResponseFormat) bool {
+	return format != nil && (format.Type == "json_object" || format.Type == "json_schema")
+}
+
+// streamWrapper 支持以非流式请求上游，再将完整响应包装为单个流式块返回
+type streamWrapper interface {
+	wrapNonStream()
+}
+
+// checkGroupStream 分组禁用流式时，按配置将流式请求转为非流式处理或直接拒绝
+func checkGroupStream(c *gin.Context, relay RelayBaseInterface) *types.OpenAIErrorWithStatusCode {
+	if !relay.IsStream() {
+		return nil
+	}
+
+	group := getRequestGroup(c)
+	if !model.GlobalUserGroupRatio.IsStreamDisabled(group) {
+		return nil
+	}
+
+	wrapper, ok := relay.(streamWrapper)
+	if !ok || config.GroupStreamDisabledPolicy == config.GroupStreamDisabledReject {
+		return common.StringErrorWrapperLocal(fmt.Sprintf("streaming is disabled for group %s", group), "stream_disabled", http.StatusBadRequest)
+	}
+
+	logger.LogInfo(c.Request.Context(), fmt.Sprintf("streaming is disabled for group %s, serving non-streaming", group))
+	wrapper.wrapNonStream()
+	return nil
+}
+
+// removeCachedBodyStream 以非流式请求上游时同步去掉缓存的原始请求体中的流式参数，
+// 避免允许额外字段的渠道以原始请求为基础合并时还原 stream
+func removeCachedBodyStream(c *gin.Context) {
+	requestBody, ok := utils.GetGinValue[[]byte](c, config.GinRequestBodyKey)
+	if !ok {
+		return
+	}
+
+	var requestMap map[string]any
+	if err := json.Unmarshal(requestBody, &requestMap); err != nil {
+		return
+	}
+	delete(requestMap, "stream")
+	delete(requestMap, "stream_options")
+
+	if requestBody, err := json.Marshal(requestMap); err == nil {
+		c.Set(config.GinRequestBodyKey, requestBody)
+	}
+}
+
+// streamCostLimitRelay 能按费用上限中止流式输出的中继
+type streamCostLimitRelay interface {
+	supportsStreamCostLimit() bool
+}
+
+// checkStreamCostLimit 无法中止输出的中继不能保证费用上限，配置了上限的流式请求直接拒绝，避免上限被静默忽略
+func checkStreamCostLimit(relay RelayBaseInterface) *types.OpenAIErrorWithStatusCode {
+	if !relay.IsStream() || relay_util.GetStreamCostCeiling(relay.getContext()) <= 0 {
+		return nil
+	}
+
+	if limitRelay, ok := relay.(streamCostLimitRelay); ok && limitRelay.supportsStreamCostLimit() {
+		return nil
+	}
+
+	return common.StringErrorWrapperLocal("max stream cost is only supported on /v1/chat/completions, remove the token's max_stream_cost or the "+relay_util.StreamCostCeilingHeader+" header", "stream_cost_limit_not_supported", http.StatusBadRequest)
+}
+
+// setStreamCostLimit 流式请求配置了费用上限时，记录到上下文中供输出时限制
+// 只有包装了输出流的中继会中止输出，中止后才限制结算费用
+func setStreamCostLimit(c *gin.Context, quota *relay_util.Quota, isStream bool, modelName string) *relay_util.StreamCostLimit {
+	if !isStream {
+		return nil
+	}
+
+	ceiling := relay_util.GetStreamCostCeiling(c)
+	if ceiling <= 0 {
+		return nil
+	}
+
+	costLimit := relay_util.NewStreamCostLimit(c.Request.Context(), quota, modelName, ceiling)
+	c.Set(config.GinStreamCostLimitKey, costLimit)
+	return costLimit
+}
+
+var requestPriorities = map[string]int{
+	"low":    relay_util.PriorityLow,
+	"normal": relay_util.PriorityNormal,
+	"high":   relay_util.PriorityHigh,
+}
+
+// getRequestPriority 从请求头 X-Priority 获取优先级（high/normal/low），不能超过令牌允许的最高优先级
+func getRequestPriority(c *gin.Context) int {
+	priority, ok := requestPriorities[strings.ToLower(c.GetHeader("X-Priority"))]
+	if !ok {
+		priority = relay_util.PriorityNormal
+	}
+
+	maxPriority := relay_util.PriorityNormal
+	if tokenSetting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting"); ok && tokenSetting != nil {
+		if tokenMaxPriority, ok := requestPriorities[strings.ToLower(tokenSetting.MaxPriority)]; ok {
+			maxPriority = tokenMaxPriority
+		}
+	}
+
+	return min(priority, maxPriority)
+}
+
+// setRequestMetadata 解析请求中客户端自定义的 metadata，记录到 context 中用于写入消费日志
+func setRequestMetadata(c *gin.Context) {
+	requestBody, ok := utils.GetGinValue[[]byte](c, config.GinRequestBodyKey)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	if err := json.Unmarshal(requestBody, &body); err != nil || len(body.Metadata) == 0 {
+		return
+	}
+
+	metadata, err := limitRequestMetadata(body.Metadata)
+	if err != nil {
+		logger.LogWarn(c.Request.Context(), "request metadata is not recorded: "+err.Error())
+		return
+	}
+
+	if len(metadata) > 0 {
+		c.Set(config.GinRequestMetadataKey, metadata)
+	}
+}
+
+// limitRequestMetadata 校验 metadata 为对象，且 key 数量和大小不超过上限
+func limitRequestMetadata(raw json.RawMessage) (map[string]any, error) {
+	if len(raw) > config.RequestMetadataMaxBytes {
+		return nil, fmt.Errorf("metadata size %d exceeds the limit of %d bytes", len(raw), config.RequestMetadataMaxBytes)
+	}
+
+	var metadata map[string]any
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return nil, errors.New("metadata must be a JSON object")
+	}
+
+	if len(metadata) > config.RequestMetadataMaxKeys {
+		return nil, fmt.Errorf("metadata has %d keys, exceeds the limit of %d", len(metadata), config.RequestMetadataMaxKeys)
+	}
+
+	return metadata, nil
+}
+
 func GetProvider(c *gin.Context, modelName string) (provider providersBase.ProviderInterface, newModelName string, fail error) {
 	// 检查模型限制
 	if modelName != "" {
@@ -107,12 +387,23 @@ func GetProvider(c *gin.Context, modelName string) (provider providersBase.Provi
 			return nil, "", err
 		}
 	}
+
+	return getChannelProvider(c, modelName)
+}
+
+// getChannelProvider 按模型选择渠道并创建 provider，调用方需自行完成模型限制检查
+func getChannelProvider(c *gin.Context, modelName string) (provider providersBase.ProviderInterface, newModelName string, fail error) {
 	channel, fail := fetchChannel(c, modelName)
 	if fail != nil {
 		return
 	}
+	if endpoint := model.GetRequestEndpoint(c.Request.URL.Path); !channel.AllowEndpoint(endpoint) {
+		fail = fmt.Errorf("endpoint %s not available on this channel", endpoint)
+		return
+	}
 	c.Set("channel_id", channel.Id)
 	c.Set("channel_type", channel.Type)
+	c.Set("skip_verbose_log", !channel.SampleVerboseLog())
 
 	provider = providers.GetProvider(channel, c)
 	if provider == nil {
@@ -180,12 +471,14 @@ func NewGroupManager(c *gin.Context) *GroupManager {
 
 // TryWithGroups 尝试使用主分组和备用分组
 func (gm *GroupManager) TryWithGroups(modelName string, filters []model.ChannelsFilterFunc, operation func(group string) (*model.Channel, error)) (*model.Channel, error) {
+	var lastErr error
 	// 首先尝试主分组
 	if gm.primaryGroup != "" {
 		channel, err := gm.tryGroup(gm.primaryGroup, modelName, filters, operation)
 		if err == nil {
 			return channel, nil
 		}
+		lastErr = err
 		logger.LogError(gm.context.Request.Context(), fmt.Sprintf("主分组 %s 失败: %v", gm.primaryGroup, err))
 	}
 
@@ -202,9 +495,9 @@ func (gm *GroupManager) TryWithGroups(modelName string, filters []model.Channels
 			return channel, nil
 		}
 		logger.LogError(gm.context.Request.Context(), fmt.Sprintf("备用分组 %s 也失败: %v", gm.backupGroup, err))
-		return nil, gm.createGroupError(gm.backupGroup, modelName, channel)
+		return nil, gm.createGroupError(gm.backupGroup, modelName, channel, err)
 	}
-	return nil, gm.createGroupError(gm.primaryGroup, modelName, nil)
+	return nil, gm.createGroupError(gm.primaryGroup, modelName, nil, lastErr)
 }
 
 // tryGroup 尝试使用指定分组
@@ -225,15 +518,38 @@ func (gm *GroupManager) setGroupRatio(group string) error {
 	return nil
 }
 
-// createGroupError 创建统一的分组错误信息
-func (gm *GroupManager) createGroupError(group string, modelName string, channel *model.Channel) error {
+// createGroupError 创建统一的分组错误信息，保留未配置模型与渠道暂不可用的错误类型
+func (gm *GroupManager) createGroupError(group string, modelName string, channel *model.Channel, err error) error {
 	if channel != nil {
 		logger.SysError(fmt.Sprintf("渠道不存在：%d", channel.Id))
 		return errors.New("数据库一致性已被破坏，请联系管理员")
 	}
+
+	var unavailableErr *model.NoAvailableChannelError
+	if errors.As(err, &unavailableErr) || errors.Is(err, model.ErrModelNotFound) {
+		return fmt.Errorf("当前分组 %s 下对于模型 %s 无可用渠道: %w", group, modelName, err)
+	}
+
 	return fmt.Errorf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelName)
 }
 
+// channelUnavailableError 将选择渠道失败的错误转换为响应：未配置模型返回 404，渠道暂不可用返回 503 并给出重试时间
+func channelUnavailableError(c *gin.Context, err error) *types.OpenAIErrorWithStatusCode {
+	if errors.Is(err, model.ErrModelNotFound) {
+		return common.StringErrorWrapperLocal(err.Error(), "model_not_found", http.StatusNotFound)
+	}
+
+	var unavailableErr *model.NoAvailableChannelError
+	if errors.As(err, &unavailableErr) {
+		if unavailableErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.FormatInt(unavailableErr.RetryAfter, 10))
+		}
+		return common.StringErrorWrapperLocal(err.Error(), "no_available_channel", http.StatusServiceUnavailable)
+	}
+
+	return common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable)
+}
+
 func fetchChannelByModel(c *gin.Context, modelName string) (*model.Channel, error) {
 	skipOnlyChat := c.GetBool("skip_only_chat")
 	isStream := c.GetBool("is_stream")
@@ -258,6 +574,14 @@ func fetchChannelByModel(c *gin.Context, modelName string) (*model.Channel, erro
 		filters = append(filters, model.FilterDisabledStream(modelName))
 	}
 
+	if endpoint := model.GetRequestEndpoint(c.Request.URL.Path); endpoint != "" {
+		filters = append(filters, model.FilterDisabledEndpoint(endpoint))
+	}
+
+	if setting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting"); ok && setting != nil && len(setting.ChannelLabels) > 0 {
+		filters = append(filters, model.FilterChannelLabels(setting.ChannelLabels))
+	}
+
 	// 使用统一的分组管理器
 	groupManager := NewGroupManager(c)
 	return groupManager.TryWithGroups(modelName, filters, func(group string) (*model.Channel, error) {
@@ -273,6 +597,7 @@ func responseJsonClient(c *gin.Context, data interface{}) *types.OpenAIErrorWith
 		logger.LogError(c.Request.Context(), "marshal_response_body_failed:"+err.Error())
 		return nil
 	}
+	responseBody = []byte(maskResponseModel(c, string(responseBody)))
 
 	c.Writer.Header().Set("Content-Type", "application/json")
 	c.Writer.WriteHeader(http.StatusOK)
@@ -286,6 +611,29 @@ func responseJsonClient(c *gin.Context, data interface{}) *types.OpenAIErrorWith
 
 type StreamEndHandler func() string
 
+// StreamErrorHandler 在已开始输出的流中写入错误
+type StreamErrorHandler func(err *types.OpenAIErrorWithStatusCode)
+
+// responseWrappedStreamClient 将完整的响应作为单个流式块返回给请求流式的客户端
+func responseWrappedStreamClient(c *gin.Context, data any, endHandler StreamEndHandler) *types.OpenAIErrorWithStatusCode {
+	responseBody, err := json.Marshal(data)
+	if err != nil {
+		return common.ErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError)
+	}
+
+	requester.SetEventStreamHeaders(c)
+	c.Writer.Write([]byte("data: " + maskResponseModel(c, string(responseBody)) + "\n\n"))
+	if endHandler != nil {
+		if streamData := endHandler(); streamData != "" {
+			c.Writer.Write([]byte("data: " + maskResponseModel(c, streamData) + "\n\n"))
+		}
+	}
+	c.Writer.Write([]byte("data: [DONE]\n\n"))
+	c.Writer.Flush()
+
+	return nil
+}
+
 func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string], endHandler StreamEndHandler) (firstResponseTime time.Time, errWithOP *types.OpenAIErrorWithStatusCode) {
 	requester.SetEventStreamHeaders(c)
 	dataChan, errChan := stream.Recv()
@@ -297,6 +645,7 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 	defer stream.Close()
 
 	var isFirstResponse bool
+	var resetErr *types.OpenAIErrorWithStatusCode
 
 	// 在新的goroutine中处理stream数据
 	go func() {
@@ -308,7 +657,7 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 				if !ok {
 					return
 				}
-				streamData := "data: " + data + "\n\n"
+				streamData := "data: " + maskResponseModel(c, data) + "\n\n"
 
 				if !isFirstResponse {
 					firstResponseTime = time.Now()
@@ -326,6 +675,30 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 				}
 
 			case err := <-errChan:
+				if requester.IsStreamResetError(err) {
+					if !isFirstResponse && config.StreamResetRetryTimes > 0 {
+						// 尚未向客户端输出任何内容，交由上层重新发起请求
+						resetErr = streamResetError(err)
+						logger.LogError(c.Request.Context(), "Stream reset before first byte:"+err.Error())
+						return
+					}
+
+					if isFirstResponse {
+						// 已经向客户端输出内容，为避免重复内容不再重试，在流中写入错误后结束，按已输出内容计费
+						logger.LogError(c.Request.Context(), "Stream reset after first byte, terminate:"+err.Error())
+						select {
+						case <-c.Request.Context().Done():
+						default:
+							if errorBody, jsonErr := json.Marshal(types.OpenAIErrorResponse{Error: streamResetError(err).OpenAIError}); jsonErr == nil {
+								c.Writer.Write([]byte("data: " + string(errorBody) + "\n\n"))
+							}
+							c.Writer.Write([]byte("data: [DONE]\n\n"))
+							c.Writer.Flush()
+						}
+						return
+					}
+				}
+
 				if !errors.Is(err, io.EOF) {
 					// 处理错误情况
 					errMsg := "data: " + err.Error() + "\n\n"
@@ -350,7 +723,7 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 								// 客户端已断开，不执行任何操作，直接跳过
 							default:
 								// 客户端正常，发送数据
-								c.Writer.Write([]byte("data: " + streamData + "\n\n"))
+								c.Writer.Write([]byte("data: " + maskResponseModel(c, streamData) + "\n\n"))
 								c.Writer.Flush()
 							}
 						}
@@ -373,10 +746,11 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 
 	// 等待处理完成
 	<-done
-	return firstResponseTime, nil
+	return firstResponseTime, resetErr
 }
 
-func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string], endHandler StreamEndHandler) (firstResponseTime time.Time) {
+// responseGeneralStreamClient 原样转发上游的流，errorHandler 用于按各接口的格式在流中写入错误
+func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string], endHandler StreamEndHandler, errorHandler StreamErrorHandler) (firstResponseTime time.Time, resetErr *types.OpenAIErrorWithStatusCode) {
 	requester.SetEventStreamHeaders(c)
 	dataChan, errChan := stream.Recv()
 
@@ -407,11 +781,31 @@ func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderIn
 					// 客户端已断开，不执行任何操作，直接跳过
 				default:
 					// 客户端正常，发送数据
-					fmt.Fprint(c.Writer, data)
+					fmt.Fprint(c.Writer, maskResponseModel(c, data))
 					c.Writer.Flush()
 				}
 
 			case err := <-errChan:
+				if requester.IsStreamResetError(err) {
+					if !isFirstResponse && config.StreamResetRetryTimes > 0 {
+						// 尚未向客户端输出任何内容，交由上层重新发起请求
+						resetErr = streamResetError(err)
+						logger.LogError(c.Request.Context(), "Stream reset before first byte:"+err.Error())
+						return
+					}
+
+					if isFirstResponse {
+						// 已经向客户端输出内容，为避免重复内容不再重试，在流中写入错误后结束，按已输出内容计费
+						logger.LogError(c.Request.Context(), "Stream reset after first byte, terminate:"+err.Error())
+						select {
+						case <-c.Request.Context().Done():
+						default:
+							errorHandler(streamResetError(err))
+						}
+						return
+					}
+				}
+
 				if !errors.Is(err, io.EOF) {
 					// 处理错误情况
 					select {
@@ -434,7 +828,7 @@ func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderIn
 								// 客户端已断开，只记录数据
 							default:
 								// 客户端正常，发送数据
-								fmt.Fprint(c.Writer, streamData)
+								fmt.Fprint(c.Writer, maskResponseModel(c, streamData))
 								c.Writer.Flush()
 							}
 						}
@@ -448,7 +842,22 @@ func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderIn
 	// 等待处理完成
 	<-done
 
-	return firstResponseTime
+	return firstResponseTime, resetErr
+}
+
+// streamResetError 上游流在首字节前被网络中断时返回的错误
+func streamResetError(err error) *types.OpenAIErrorWithStatusCode {
+	return common.StringErrorWrapper(err.Error(), "stream_reset", http.StatusBadGateway)
+}
+
+// shouldRetryStreamReset 判断是否需要对首字节前被中断的流重新发起请求
+func shouldRetryStreamReset(c *gin.Context, err *types.OpenAIErrorWithStatusCode, attempt int) bool {
+	if err == nil || err.Code != "stream_reset" || attempt >= config.StreamResetRetryTimes || !retry.Acquire(c) {
+		return false
+	}
+
+	logger.LogWarn(c.Request.Context(), fmt.Sprintf("stream reset before first byte, retry (attempt %d)", attempt+1))
+	return true
 }
 
 func responseMultipart(c *gin.Context, resp *http.Response) *types.OpenAIErrorWithStatusCode {
@@ -545,12 +954,41 @@ func shouldRetryBadRequest(channelType int, apiErr *types.OpenAIErrorWithStatusC
 }
 
 func processChannelRelayError(ctx context.Context, channelId int, channelName string, err *types.OpenAIErrorWithStatusCode, channelType int) {
-	logger.LogError(ctx, fmt.Sprintf("relay error (channel #%d(%s)): %s", channelId, channelName, err.Message))
+	// 渠道 RPM 排队超时是本地限流，不是上游故障
+	if isChannelRPMLimited(err) {
+		logger.LogWarn(ctx, fmt.Sprintf("channel #%d(%s) rpm queue timeout: %s", channelId, channelName, err.Message))
+		return
+	}
+
+	message := err.Message
+	if err.RawUpstreamRequestId != "" {
+		message = fmt.Sprintf("%s (upstream request id: %s)", message, err.RawUpstreamRequestId)
+	}
+	logger.LogError(ctx, fmt.Sprintf("relay error (channel #%d(%s)): %s", channelId, channelName, message))
 	if controller.ShouldDisableChannel(channelType, err) {
 		controller.DisableChannel(channelId, channelName, err.Message, true)
 	}
 }
 
+func isChannelRPMLimited(err *types.OpenAIErrorWithStatusCode) bool {
+	code, ok := err.Code.(string)
+	return ok && code == relay_util.ChannelRPMLimitedCode
+}
+
+// getPrivilegedUpstreamRequestId 特权令牌的错误信息中附带上游请求 ID，其他令牌不返回
+func getPrivilegedUpstreamRequestId(c *gin.Context, err *types.OpenAIErrorWithStatusCode) string {
+	tokenSetting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting")
+	if !ok || tokenSetting == nil || !tokenSetting.Privileged {
+		return ""
+	}
+
+	if err.RawUpstreamRequestId != "" {
+		return err.RawUpstreamRequestId
+	}
+
+	return c.GetString(config.GinUpstreamRequestIdKey)
+}
+
 var (
 	requestIdRegex = regexp.MustCompile(`\(request id: [^\)]+\)`)
 	quotaKeywords  = []string{"余额", "额度", "quota", "无可用渠道", "令牌"}
@@ -572,7 +1010,8 @@ func FilterOpenAIErr(c *gin.Context, err *types.OpenAIErrorWithStatusCode) (errW
 	}
 
 	requestId := c.GetString(logger.RequestIdKey)
-	newErr.OpenAIError.Message = utils.MessageWithRequestId(newErr.OpenAIError.Message, requestId)
+	newErr.OpenAIError.Message = utils.MessageWithRequestId(maskErrorMessage(c, newErr.OpenAIError.Message), requestId)
+	newErr.OpenAIError.UpstreamRequestId = getPrivilegedUpstreamRequestId(c, &newErr)
 
 	if !newErr.LocalError && newErr.OpenAIError.Type == "one_hub_error" || strings.HasSuffix(newErr.OpenAIError.Type, "_api_error") {
 		newErr.OpenAIError.Type = "system_error"
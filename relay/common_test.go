@@ -0,0 +1,519 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/retry"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/relay/relay_util"
+	"one-api/types"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	logger.Logger = zap.NewNop()
+}
+
+type mockStream struct {
+	dataChan chan string
+	errChan  chan error
+}
+
+func newMockStream(data []string, err error) *mockStream {
+	stream := &mockStream{
+		dataChan: make(chan string),
+		errChan:  make(chan error),
+	}
+
+	go func() {
+		for _, item := range data {
+			stream.dataChan <- item
+		}
+		stream.errChan <- err
+	}()
+
+	return stream
+}
+
+func (s *mockStream) Recv() (<-chan string, <-chan error) {
+	return s.dataChan, s.errChan
+}
+
+func (s *mockStream) Close() {}
+
+func TestResponseStreamClientResetBeforeFirstByte(t *testing.T) {
+	config.StreamResetRetryTimes = 1
+	defer func() { config.StreamResetRetryTimes = 0 }()
+
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	stream := newMockStream(nil, syscall.ECONNRESET)
+
+	_, err := responseStreamClient(c, stream, nil)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "stream_reset", err.Code)
+	assert.Equal(t, http.StatusBadGateway, err.StatusCode)
+	assert.Empty(t, w.Body.String())
+	assert.True(t, shouldRetryStreamReset(c, err, 0))
+	assert.False(t, shouldRetryStreamReset(c, err, 1))
+}
+
+func TestResponseStreamClientResetAfterFirstByte(t *testing.T) {
+	config.StreamResetRetryTimes = 1
+	defer func() { config.StreamResetRetryTimes = 0 }()
+
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	stream := newMockStream([]string{`{"id":"1"}`}, io.ErrUnexpectedEOF)
+
+	_, err := responseStreamClient(c, stream, nil)
+
+	assert.Nil(t, err)
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, `data: {"id":"1"}`))
+	assert.Contains(t, body, `"code":"stream_reset"`)
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"))
+}
+
+func TestResponseGeneralStreamClientResetAfterFirstByte(t *testing.T) {
+	config.StreamResetRetryTimes = 1
+	defer func() { config.StreamResetRetryTimes = 0 }()
+
+	c, w := test.GetContext("POST", "/v1/messages", test.RequestJSONConfig(), nil)
+	stream := newMockStream([]string{"data: {\"type\":\"message_start\"}\n\n"}, syscall.ECONNRESET)
+
+	_, err := responseGeneralStreamClient(c, stream, nil, NewRelayClaudeOnly(c).HandleStreamError)
+
+	assert.Nil(t, err)
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, `data: {"type":"message_start"}`))
+	assert.Contains(t, body, "event: error\ndata: ")
+}
+
+func TestIsStreamResetError(t *testing.T) {
+	assert.True(t, requester.IsStreamResetError(syscall.ECONNRESET))
+	assert.True(t, requester.IsStreamResetError(io.ErrUnexpectedEOF))
+	assert.True(t, requester.IsStreamResetError(errors.New("stream error: stream ID 3; INTERNAL_ERROR")))
+
+	assert.False(t, requester.IsStreamResetError(io.EOF))
+	assert.False(t, requester.IsStreamResetError(context.Canceled))
+	assert.False(t, requester.IsStreamResetError(&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}))
+	assert.False(t, requester.IsStreamResetError(&net.DNSError{Err: "no such host", Name: "api.example.com"}))
+	assert.False(t, requester.IsStreamResetError(errors.New("upstream stream error event")))
+}
+
+func TestResponseStreamClientResetRetryDisabled(t *testing.T) {
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	stream := newMockStream(nil, syscall.ECONNRESET)
+
+	_, err := responseStreamClient(c, stream, nil)
+
+	assert.Nil(t, err)
+	assert.Contains(t, w.Body.String(), "connection reset")
+}
+
+func TestSetRequestMetadata(t *testing.T) {
+	body := `{"model":"claude-3-5-sonnet","max_tokens":10,"messages":[{"role":"user","content":"hi"}],"metadata":{"project":"x","user_id":"u-1"}}`
+	c, _ := test.GetContext("POST", "/claude/v1/messages", test.RequestJSONConfig(), strings.NewReader(body))
+
+	relay := NewRelayClaudeOnly(c)
+	assert.Nil(t, relay.setRequest())
+	setRequestMetadata(c)
+
+	metadata, ok := c.Get(config.GinRequestMetadataKey)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]any{"project": "x", "user_id": "u-1"}, metadata)
+
+	// 仅转发 Claude 原生的 user_id
+	upstream, _ := json.Marshal(relay.claudeRequest)
+	assert.Contains(t, string(upstream), `"metadata":{"user_id":"u-1"}`)
+	assert.NotContains(t, string(upstream), "project")
+}
+
+func TestSetRequestMetadataNotForwarded(t *testing.T) {
+	body := `{"model":"claude-3-5-sonnet","max_tokens":10,"messages":[{"role":"user","content":"hi"}],"metadata":{"project":"x"}}`
+	c, _ := test.GetContext("POST", "/claude/v1/messages", test.RequestJSONConfig(), strings.NewReader(body))
+
+	relay := NewRelayClaudeOnly(c)
+	assert.Nil(t, relay.setRequest())
+
+	upstream, _ := json.Marshal(relay.claudeRequest)
+	assert.NotContains(t, string(upstream), "metadata")
+}
+
+func TestLimitRequestMetadata(t *testing.T) {
+	_, err := limitRequestMetadata(json.RawMessage(`["x"]`))
+	assert.NotNil(t, err)
+
+	keys := make(map[string]int)
+	for i := 0; i <= config.RequestMetadataMaxKeys; i++ {
+		keys[string(rune('a'+i))] = i
+	}
+	raw, _ := json.Marshal(keys)
+	_, err = limitRequestMetadata(raw)
+	assert.NotNil(t, err)
+
+	raw, _ = json.Marshal(map[string]string{"note": strings.Repeat("x", config.RequestMetadataMaxBytes)})
+	_, err = limitRequestMetadata(raw)
+	assert.NotNil(t, err)
+
+	metadata, err := limitRequestMetadata(json.RawMessage(`{"project":"x"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "x", metadata["project"])
+}
+
+func TestApplyModelDowngrade(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.User{}))
+	model.DB = db
+
+	config.ModelDowngradeSettingsInstance.SetRules(`{"claude-sonnet-4":{"model":"claude-3-5-haiku","threshold":1000}}`)
+	defer config.ModelDowngradeSettingsInstance.SetRules("")
+
+	lowUser := &model.User{Username: "low", Quota: 500, AccessToken: "low", AffCode: "low"}
+	richUser := &model.User{Username: "rich", Quota: 50000, AccessToken: "rich", AffCode: "rich"}
+	assert.Nil(t, db.Create(lowUser).Error)
+	assert.Nil(t, db.Create(richUser).Error)
+
+	// 余额不足时降级
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("id", lowUser.Id)
+	assert.Equal(t, "claude-3-5-haiku", applyModelDowngrade(c, "claude-sonnet-4"))
+	assert.Equal(t, "claude-sonnet-4", c.GetString("downgraded_from"))
+	assert.Equal(t, "claude-sonnet-4", w.Header().Get("X-Model-Downgraded-From"))
+
+	// 余额充足时不降级
+	c, w = test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("id", richUser.Id)
+	assert.Equal(t, "claude-sonnet-4", applyModelDowngrade(c, "claude-sonnet-4"))
+	assert.Empty(t, c.GetString("downgraded_from"))
+	assert.Empty(t, w.Header().Get("X-Model-Downgraded-From"))
+
+	// 未配置规则的模型不降级
+	c, _ = test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("id", lowUser.Id)
+	assert.Equal(t, "claude-3-5-haiku", applyModelDowngrade(c, "claude-3-5-haiku"))
+}
+
+func TestSetProviderModelDowngrade(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.User{}, &model.Channel{}))
+	model.DB = db
+
+	config.ModelDowngradeSettingsInstance.SetRules(`{"claude-sonnet-4":{"model":"claude-3-5-haiku","threshold":1000},"claude-3-5-haiku":{"model":"claude-3-haiku","threshold":1000}}`)
+	defer config.ModelDowngradeSettingsInstance.SetRules("")
+
+	user := &model.User{Username: "low", Quota: 500, AccessToken: "low", AffCode: "low"}
+	assert.Nil(t, db.Create(user).Error)
+	baseURL := "http://127.0.0.1"
+	channel := &model.Channel{Type: config.ChannelTypeAnthropic, Key: "sk-test", Status: config.ChannelStatusEnabled, BaseURL: &baseURL}
+	assert.Nil(t, db.Create(channel).Error)
+
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("id", user.Id)
+	c.Set("specific_channel_id", channel.Id)
+	// 令牌只允许请求的模型，降级后的模型不受限制
+	c.Set("token_setting", &model.TokenSetting{Limits: model.LimitsConfig{LimitModelSetting: model.LimitModelSetting{Enabled: true, Models: []string{"claude-sonnet-4"}}}})
+
+	relay := &relayBase{c: c, originalModel: "claude-sonnet-4"}
+	assert.Nil(t, relay.setProvider(relay.getOriginalModel()))
+	assert.Equal(t, "claude-sonnet-4", relay.getOriginalModel())
+	assert.Equal(t, "claude-3-5-haiku", relay.getModelName())
+
+	// 重试时不会从降级后的模型再次降级
+	assert.Nil(t, relay.setProvider(relay.getOriginalModel()))
+	assert.Equal(t, "claude-sonnet-4", relay.getOriginalModel())
+	assert.Equal(t, "claude-3-5-haiku", relay.getModelName())
+
+	// 请求的模型不在令牌允许范围内时拒绝
+	relay = &relayBase{c: c, originalModel: "claude-3-5-haiku"}
+	assert.NotNil(t, relay.setProvider(relay.getOriginalModel()))
+}
+
+func TestAcquireStreamSlot(t *testing.T) {
+	config.StreamConcurrencyPerUser = 2
+	defer func() { config.StreamConcurrencyPerUser = 0 }()
+
+	getContext := func(isStream bool) *gin.Context {
+		c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+		c.Set("id", 2001)
+		c.Set("token_id", 3001)
+		c.Set("is_stream", isStream)
+		return c
+	}
+
+	releases := make([]func(), 0)
+	for i := 0; i < 2; i++ {
+		release, errWithCode := acquireStreamSlot(getContext(true), "gpt-4o")
+		assert.Nil(t, errWithCode)
+		releases = append(releases, release)
+	}
+
+	// 流式请求超出上限
+	_, errWithCode := acquireStreamSlot(getContext(true), "gpt-4o")
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusTooManyRequests, errWithCode.StatusCode)
+	assert.Equal(t, "stream_concurrency_limited", errWithCode.Code)
+
+	// 非流式请求不受影响
+	for i := 0; i < 5; i++ {
+		release, errWithCode := acquireStreamSlot(getContext(false), "gpt-4o")
+		assert.Nil(t, errWithCode)
+		defer release()
+	}
+	assert.Equal(t, 2, relay_util.GetStreamingStatistics().Users[2001])
+
+	// 释放后可以再次发起流式请求
+	releases[0]()
+	releases[0]()
+	release, errWithCode := acquireStreamSlot(getContext(true), "gpt-4o")
+	assert.Nil(t, errWithCode)
+	release()
+	releases[1]()
+	assert.Zero(t, relay_util.GetStreamingStatistics().Users[2001])
+}
+
+func TestAcquireStreamSlotPerToken(t *testing.T) {
+	config.StreamConcurrencyPerToken = 1
+	defer func() { config.StreamConcurrencyPerToken = 0 }()
+
+	release, errWithCode := relay_util.AcquireStreamSlot(2002, 3002, "gpt-4o")
+	assert.Nil(t, errWithCode)
+	defer release()
+
+	_, errWithCode = relay_util.AcquireStreamSlot(2002, 3002, "gpt-4o")
+	assert.NotNil(t, errWithCode)
+
+	// 同一用户的其他令牌不受影响
+	other, errWithCode := relay_util.AcquireStreamSlot(2002, 3003, "gpt-4o")
+	assert.Nil(t, errWithCode)
+	other()
+}
+
+func TestAcquireStreamSlotConcurrencyClass(t *testing.T) {
+	config.StreamConcurrencyPerUser = 2
+	config.ConcurrencyClassSettingsInstance.SetClasses(`{"reasoning":{"models":["o1","o3","deepseek-reasoner"],"per_user":1}}`)
+	defer func() {
+		config.StreamConcurrencyPerUser = 0
+		config.ConcurrencyClassSettingsInstance.SetClasses("")
+	}()
+
+	// 推理模型名额占满
+	release, errWithCode := relay_util.AcquireStreamSlot(2004, 3004, "o3-mini")
+	assert.Nil(t, errWithCode)
+	defer release()
+	_, errWithCode = relay_util.AcquireStreamSlot(2004, 3004, "o1")
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusTooManyRequests, errWithCode.StatusCode)
+	assert.Contains(t, errWithCode.Message, "reasoning")
+
+	// 同一用户的普通模型请求不受影响
+	for i := 0; i < 2; i++ {
+		standard, errWithCode := relay_util.AcquireStreamSlot(2004, 3004, "gpt-4o-mini")
+		assert.Nil(t, errWithCode)
+		defer standard()
+	}
+	_, errWithCode = relay_util.AcquireStreamSlot(2004, 3004, "gpt-4o-mini")
+	assert.NotNil(t, errWithCode)
+
+	assert.Equal(t, 3, relay_util.GetStreamingStatistics().Users[2004])
+}
+
+func TestEstimateCompletionTokens(t *testing.T) {
+	config.ApproximateTokenEnabled = true
+	defer func() { config.ApproximateTokenEnabled = false }()
+
+	// 流在最终用量之前中断，按已输出的文本估算
+	usage := &types.Usage{PromptTokens: 12, TotalTokens: 12}
+	usage.TextBuilder.WriteString("The quick brown fox jumps over the lazy dog.")
+	estimateCompletionTokens(usage, "claude-3-5-sonnet-20241022")
+	assert.True(t, usage.Estimated)
+	assert.Greater(t, usage.CompletionTokens, 1)
+	assert.Equal(t, usage.PromptTokens+usage.CompletionTokens, usage.TotalTokens)
+
+	// 上游返回了最终用量时不估算
+	usage = &types.Usage{PromptTokens: 12, CompletionTokens: 11, TotalTokens: 23}
+	usage.TextBuilder.WriteString("The quick brown fox jumps over the lazy dog.")
+	estimateCompletionTokens(usage, "claude-3-5-sonnet-20241022")
+	assert.False(t, usage.Estimated)
+	assert.Equal(t, 11, usage.CompletionTokens)
+}
+
+func TestGetProviderDisabledEndpoint(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.Channel{}))
+	model.DB = db
+
+	disabled := datatypes.JSONSlice[string]{"batches", "messages"}
+	channel := &model.Channel{Type: config.ChannelTypeAnthropic, Key: "sk-test", Status: config.ChannelStatusEnabled, DisabledEndpoints: &disabled}
+	assert.Nil(t, db.Create(channel).Error)
+
+	getContext := func(path string) *gin.Context {
+		c, _ := test.GetContext("POST", path, test.RequestJSONConfig(), nil)
+		c.Set("specific_channel_id", channel.Id)
+		return c
+	}
+
+	_, _, fail := GetProvider(getContext("/claude/v1/messages"), "claude-3-5-sonnet")
+	assert.EqualError(t, fail, "endpoint messages not available on this channel")
+
+	_, _, fail = GetProvider(getContext("/v1/batches/batch_1"), "")
+	assert.EqualError(t, fail, "endpoint batches not available on this channel")
+
+	provider, _, fail := GetProvider(getContext("/v1/chat/completions"), "claude-3-5-sonnet")
+	assert.Nil(t, fail)
+	assert.Equal(t, channel.Id, provider.GetChannel().Id)
+}
+
+func TestChannelUnavailableError(t *testing.T) {
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	gm := NewGroupManager(c)
+
+	errWithCode := channelUnavailableError(c, gm.createGroupError("default", "gpt-4o", nil, model.ErrModelNotFound))
+	assert.Equal(t, http.StatusNotFound, errWithCode.StatusCode)
+	assert.Equal(t, "model_not_found", errWithCode.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	c, w = test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	unavailableErr := &model.NoAvailableChannelError{Model: "claude-3-5-sonnet", RetryAfter: 12}
+	errWithCode = channelUnavailableError(c, gm.createGroupError("default", "claude-3-5-sonnet", nil, unavailableErr))
+	assert.Equal(t, http.StatusServiceUnavailable, errWithCode.StatusCode)
+	assert.Equal(t, "no_available_channel", errWithCode.Code)
+	assert.Contains(t, errWithCode.Message, "no available upstream for model claude-3-5-sonnet, retry after 12 seconds")
+	assert.Equal(t, "12", w.Header().Get("Retry-After"))
+}
+
+func TestRetryBudgetAttempts(t *testing.T) {
+	config.StreamResetRetryTimes = 5
+	config.RetryBudgetAttempts = 2
+	defer func() {
+		config.StreamResetRetryTimes = 0
+		config.RetryBudgetAttempts = 0
+	}()
+
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	resetErr := streamResetError(syscall.ECONNRESET)
+
+	// 流重置重试与渠道重试共用预算
+	assert.True(t, shouldRetryStreamReset(c, resetErr, 0))
+	assert.True(t, retry.Acquire(c))
+	assert.False(t, shouldRetryStreamReset(c, resetErr, 1))
+	assert.False(t, retry.Acquire(c))
+	assert.Equal(t, 2, retry.Attempts(c))
+}
+
+func TestResponseJsonClientContentType(t *testing.T) {
+	c, w := test.GetContext("POST", "/v1/chat/completions", map[string]string{"Accept": "text/plain"}, nil)
+
+	assert.Nil(t, responseJsonClient(c, map[string]string{"id": "msg_1"}))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"msg_1"}`, w.Body.String())
+}
+
+type fakeQuota struct {
+	consumed *types.Usage
+	undone   bool
+}
+
+func (q *fakeQuota) Consume(c *gin.Context, usage *types.Usage, isStream bool) {
+	q.consumed = usage
+}
+
+func (q *fakeQuota) Undo(c *gin.Context) {
+	q.undone = true
+}
+
+func TestClientDisconnectCancelsUpstream(t *testing.T) {
+	requester.InitHttpClient()
+	canceled := make(chan struct{})
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+		close(canceled)
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Request = c.Request.WithContext(ctx)
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	chatProvider := providers.GetProvider(&channel, c).(providersBase.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+	bindUpstreamToClient(c, chatProvider)
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, errWithCode := chatProvider.CreateChatCompletion(&types.ChatCompletionRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Messages:  []types.ChatCompletionMessage{{Role: types.ChatMessageRoleUser, Content: "hi"}},
+	})
+	assert.NotNil(t, errWithCode)
+	assert.True(t, clientDisconnected(c))
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("upstream request was not canceled")
+	}
+
+	// 默认不计费，退回预扣额度
+	quota := &fakeQuota{}
+	apiErr := handleClientDisconnect(c, quota, &types.Usage{PromptTokens: 10, CompletionTokens: 5})
+	assert.True(t, quota.undone)
+	assert.Nil(t, quota.consumed)
+	assert.Equal(t, "client_disconnected", apiErr.Code)
+	assert.True(t, apiErr.LocalError)
+
+	config.DisconnectBilling = config.DisconnectBillingPrompt
+	defer func() { config.DisconnectBilling = config.DisconnectBillingNone }()
+	quota = &fakeQuota{}
+	handleClientDisconnect(c, quota, &types.Usage{PromptTokens: 10, CompletionTokens: 5})
+	assert.False(t, quota.undone)
+	assert.Equal(t, 10, quota.consumed.TotalTokens)
+	assert.Equal(t, 0, quota.consumed.CompletionTokens)
+}
+
+func TestShouldCooldownsChannelRPMLimited(t *testing.T) {
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("new_model", "claude-3-5-sonnet-20241022")
+
+	// 本地排队超时只跳过该渠道，不冻结
+	rpmErr := common.StringErrorWrapper("channel #901 exceeds the rpm limit of 1", relay_util.ChannelRPMLimitedCode, http.StatusTooManyRequests)
+	shouldCooldowns(c, &model.Channel{Id: 901}, rpmErr)
+	assert.False(t, model.ChannelGroup.IsInCooldown(901, "claude-3-5-sonnet-20241022"))
+	skipChannelIds, _ := c.Get("skip_channel_ids")
+	assert.Equal(t, []int{901}, skipChannelIds)
+	// 仍然换渠道重试
+	assert.True(t, shouldRetry(c, rpmErr, config.ChannelTypeAnthropic))
+
+	// 上游的频率限制冻结渠道
+	shouldCooldowns(c, &model.Channel{Id: 902}, common.StringErrorWrapper("rate limited", "rate_limit_error", http.StatusTooManyRequests))
+	assert.True(t, model.ChannelGroup.IsInCooldown(902, "claude-3-5-sonnet-20241022"))
+}
@@ -0,0 +1,154 @@
+package relay
+
+import (
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCountTokensProvider 上游响应不返回 input_tokens，count_tokens 由 countHandler 处理
+func newCountTokensProvider(t *testing.T, stream bool, countHandler http.HandlerFunc) (*gin.Context, providersBase.ChatInterface, *int, func()) {
+	requester.InitHttpClient()
+	config.ApproximateTokenEnabled = true
+	t.Cleanup(func() { config.ApproximateTokenEnabled = false })
+	countCalls := 0
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		if stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, event := range []string{
+				`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"usage":{}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+				`{"type":"message_stop"}`,
+			} {
+				w.Write([]byte("event: message\ndata: " + event + "\n\n"))
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"output_tokens":5}}`))
+	})
+	server.RegisterHandler("/v1/messages/count_tokens", func(w http.ResponseWriter, r *http.Request) {
+		countCalls++
+		countHandler(w, r)
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider := providers.GetProvider(&channel, c).(providersBase.ChatInterface)
+	// 本地估算的提示 token
+	chatProvider.SetUsage(&types.Usage{PromptTokens: 10})
+
+	request := &types.ChatCompletionRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 100,
+		Stream:    stream,
+		Messages:  []types.ChatCompletionMessage{{Role: types.ChatMessageRoleUser, Content: "hi"}},
+	}
+	if stream {
+		response, errWithCode := chatProvider.CreateChatCompletionStream(request)
+		assert.Nil(t, errWithCode)
+		_, errWithCode = responseStreamClient(c, response, func() string { return "" })
+		assert.Nil(t, errWithCode)
+	} else {
+		_, errWithCode := chatProvider.CreateChatCompletion(request)
+		assert.Nil(t, errWithCode)
+	}
+
+	return c, chatProvider, &countCalls, ts.Close
+}
+
+func countTokensOK(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"input_tokens":42}`))
+}
+
+func TestRecountPromptTokens(t *testing.T) {
+	config.ClaudeSettingsInstance.CountTokensFallback = true
+	defer func() { config.ClaudeSettingsInstance.CountTokensFallback = false }()
+
+	for _, stream := range []bool{false, true} {
+		c, chatProvider, countCalls, closeServer := newCountTokensProvider(t, stream, countTokensOK)
+		usage := chatProvider.GetUsage()
+		assert.True(t, usage.PromptEstimated)
+
+		recountPromptTokens(c, chatProvider, usage)
+		assert.Equal(t, 1, *countCalls)
+		assert.Equal(t, 42, usage.PromptTokens)
+		assert.Equal(t, 42+usage.CompletionTokens, usage.TotalTokens)
+		assert.False(t, usage.PromptEstimated)
+		closeServer()
+	}
+}
+
+func TestRecountPromptTokensFallbackToEstimate(t *testing.T) {
+	config.ClaudeSettingsInstance.CountTokensFallback = true
+	config.ClaudeSettingsInstance.CountTokensTimeout = 50
+	defer func() {
+		config.ClaudeSettingsInstance.CountTokensFallback = false
+		config.ClaudeSettingsInstance.CountTokensTimeout = 2000
+	}()
+
+	handlers := map[string]http.HandlerFunc{
+		"error": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"type":"error","error":{"type":"not_found_error","message":"not found"}}`))
+		},
+		"timeout": func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			countTokensOK(w, r)
+		},
+	}
+
+	for name, handler := range handlers {
+		c, chatProvider, countCalls, closeServer := newCountTokensProvider(t, false, handler)
+		usage := chatProvider.GetUsage()
+
+		recountPromptTokens(c, chatProvider, usage)
+		assert.Equal(t, 1, *countCalls, name)
+		// 本地估算是最后的手段
+		assert.Equal(t, 10, usage.PromptTokens, name)
+		assert.True(t, usage.PromptEstimated, name)
+		closeServer()
+	}
+}
+
+func TestRecountPromptTokensDisabled(t *testing.T) {
+	c, chatProvider, countCalls, closeServer := newCountTokensProvider(t, false, countTokensOK)
+	defer closeServer()
+
+	usage := chatProvider.GetUsage()
+	recountPromptTokens(c, chatProvider, usage)
+	assert.Equal(t, 0, *countCalls)
+	assert.Equal(t, 10, usage.PromptTokens)
+}
+
+func TestRecountPromptTokensKeepCacheUsage(t *testing.T) {
+	config.ClaudeSettingsInstance.CountTokensFallback = true
+	defer func() { config.ClaudeSettingsInstance.CountTokensFallback = false }()
+
+	c, chatProvider, countCalls, closeServer := newCountTokensProvider(t, false, countTokensOK)
+	defer closeServer()
+
+	// 已有缓存用量时 count_tokens 的总数会把缓存部分按完整输入价格计费
+	usage := chatProvider.GetUsage()
+	usage.PromptEstimated = true
+	usage.PromptTokensDetails.CachedReadTokens = 30
+	recountPromptTokens(c, chatProvider, usage)
+	assert.Equal(t, 0, *countCalls)
+	assert.Equal(t, 10, usage.PromptTokens)
+	assert.Equal(t, 30, usage.PromptTokensDetails.CachedReadTokens)
+}
@@ -0,0 +1,46 @@
+package relay
+
+import (
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// nginx 约定的客户端主动关闭连接的状态码
+const statusClientClosedRequest = 499
+
+// quotaSettler 请求结束时结算预扣的额度
+type quotaSettler interface {
+	Consume(c *gin.Context, usage *types.Usage, isStream bool)
+	Undo(c *gin.Context)
+}
+
+// bindUpstreamToClient 上游调用跟随客户端请求的生命周期，客户端断开时取消上游请求
+func bindUpstreamToClient(c *gin.Context, provider providersBase.ProviderInterface) {
+	if requester := provider.GetRequester(); requester != nil {
+		requester.Context = c.Request.Context()
+	}
+}
+
+func clientDisconnected(c *gin.Context) bool {
+	return c.Request.Context().Err() != nil
+}
+
+// handleClientDisconnect 客户端已断开，按 DisconnectBilling 结算并返回不会重试的错误
+func handleClientDisconnect(c *gin.Context, quota quotaSettler, usage *types.Usage) *types.OpenAIErrorWithStatusCode {
+	if config.DisconnectBilling == config.DisconnectBillingPrompt {
+		usage.CompletionTokens = 0
+		usage.TotalTokens = usage.PromptTokens
+		quota.Consume(c, usage, false)
+	} else {
+		quota.Undo(c)
+	}
+
+	logger.LogWarn(c.Request.Context(), "client disconnected before upstream responded, upstream request canceled, billing: "+config.DisconnectBilling)
+
+	return common.StringErrorWrapperLocal("client disconnected", "client_disconnected", statusClientClosedRequest)
+}
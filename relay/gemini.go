@@ -11,6 +11,7 @@ import (
 	"one-api/safty"
 	"one-api/types"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -98,21 +99,27 @@ func (r *relayGeminiOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 	r.geminiRequest.Model = r.modelName
 
 	if r.geminiRequest.Stream {
-		var response requester.StreamReaderInterface[string]
-		response, err = chatProvider.CreateGeminiChatStream(r.geminiRequest)
-		if err != nil {
-			return
-		}
+		for attempt := 0; ; attempt++ {
+			var response requester.StreamReaderInterface[string]
+			response, err = chatProvider.CreateGeminiChatStream(r.geminiRequest)
+			if err != nil {
+				return
+			}
 
-		if r.heartbeat != nil {
-			r.heartbeat.Stop()
-		}
+			if r.heartbeat != nil {
+				r.heartbeat.Stop()
+			}
 
-		doneStr := func() string {
-			return ""
+			doneStr := func() string {
+				return ""
+			}
+			var firstResponseTime time.Time
+			firstResponseTime, err = responseGeneralStreamClient(r.c, response, doneStr, r.HandleStreamError)
+			r.SetFirstResponseTime(firstResponseTime)
+			if !shouldRetryStreamReset(r.c, err, attempt) {
+				break
+			}
 		}
-		firstResponseTime := responseGeneralStreamClient(r.c, response, doneStr)
-		r.SetFirstResponseTime(firstResponseTime)
 	} else {
 		var response *gemini.GeminiChatResponse
 		response, err = chatProvider.CreateGeminiChat(r.geminiRequest)
@@ -0,0 +1,165 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupGroupStreamDB(t *testing.T, upstreamURL string) *model.Channel {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.Channel{}, &model.UserGroup{}))
+	model.DB = db
+
+	assert.Nil(t, db.Create(&model.UserGroup{Symbol: "trial", Name: "trial", Ratio: 1, DisableStream: true}).Error)
+	assert.Nil(t, db.Create(&model.UserGroup{Symbol: "pro", Name: "pro", Ratio: 1}).Error)
+	model.GlobalUserGroupRatio.Load()
+	t.Cleanup(func() { model.GlobalUserGroupRatio = model.UserGroupRatio{} })
+
+	channel := &model.Channel{Type: config.ChannelTypeAnthropic, Key: "sk-test", Status: config.ChannelStatusEnabled, BaseURL: &upstreamURL}
+	assert.Nil(t, db.Create(channel).Error)
+	return channel
+}
+
+func TestGroupStreamDisabled(t *testing.T) {
+	requester.InitHttpClient()
+	upstreamStream := make([]bool, 0)
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "text/event-stream" {
+			upstreamStream = append(upstreamStream, true)
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, event := range []string{
+				`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"usage":{"input_tokens":10}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+				`{"type":"message_stop"}`,
+			} {
+				w.Write([]byte("data: " + event + "\n\n"))
+			}
+			return
+		}
+		upstreamStream = append(upstreamStream, false)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+	channel := setupGroupStreamDB(t, ts.URL)
+
+	body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`
+	send := func(group string) (*relayChat, string, *types.OpenAIErrorWithStatusCode) {
+		c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), strings.NewReader(body))
+		c.Set("token_group", group)
+		c.Set("specific_channel_id", channel.Id)
+
+		relay := NewRelayChat(c)
+		assert.Nil(t, relay.setRequest())
+		if errWithCode := checkGroupStream(c, relay); errWithCode != nil {
+			return relay, "", errWithCode
+		}
+		assert.Nil(t, relay.setProvider(relay.getOriginalModel()))
+		relay.getProvider().SetUsage(&types.Usage{})
+		errWithCode, _ := relay.send()
+		assert.Nil(t, errWithCode)
+		return relay, w.Body.String(), nil
+	}
+
+	// 禁用流式的分组以非流式请求上游，包装为单个流式块返回
+	relay, response, errWithCode := send("trial")
+	assert.Nil(t, errWithCode)
+	assert.False(t, relay.IsStream())
+	assert.Equal(t, []bool{false}, upstreamStream)
+	assert.Equal(t, 3, strings.Count(response, "data: "))
+	assert.Contains(t, response, `"object":"chat.completion.chunk"`)
+	assert.Contains(t, response, `"content":"Hello"`)
+	assert.Contains(t, response, `"finish_reason":"stop"`)
+	assert.Contains(t, response, `"total_tokens":15`)
+	assert.True(t, strings.HasSuffix(response, "data: [DONE]\n\n"))
+
+	// 正常分组仍然流式请求
+	relay, response, errWithCode = send("pro")
+	assert.Nil(t, errWithCode)
+	assert.True(t, relay.IsStream())
+	assert.Equal(t, []bool{false, true}, upstreamStream)
+	assert.Contains(t, response, "data: [DONE]")
+
+	// 配置为拒绝时直接返回错误，不请求上游
+	config.GroupStreamDisabledPolicy = config.GroupStreamDisabledReject
+	defer func() { config.GroupStreamDisabledPolicy = config.GroupStreamDisabledNonStream }()
+	_, _, errWithCode = send("trial")
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	assert.Equal(t, "stream_disabled", errWithCode.Code)
+	assert.Len(t, upstreamStream, 2)
+}
+
+func TestGroupStreamDisabledAllowExtraBody(t *testing.T) {
+	requester.InitHttpClient()
+	var upstreamBody map[string]any
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		upstreamBody = nil
+		json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	setupGroupStreamDB(t, ts.URL)
+	channel := &model.Channel{Type: config.ChannelTypeOpenAI, Key: "sk-test", Status: config.ChannelStatusEnabled, BaseURL: &ts.URL, AllowExtraBody: true}
+	assert.Nil(t, model.DB.Create(channel).Error)
+
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), strings.NewReader(`{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true},"custom_field":"kept","messages":[{"role":"user","content":"hi"}]}`))
+	c.Set("token_group", "trial")
+	c.Set("specific_channel_id", channel.Id)
+
+	relay := NewRelayChat(c)
+	assert.Nil(t, relay.setRequest())
+	assert.Nil(t, checkGroupStream(c, relay))
+	assert.Nil(t, relay.setProvider(relay.getOriginalModel()))
+	relay.getProvider().SetUsage(&types.Usage{})
+	errWithCode, _ := relay.send()
+	assert.Nil(t, errWithCode)
+
+	// 合并原始请求的额外字段时不还原流式参数
+	assert.NotContains(t, upstreamBody, "stream")
+	assert.NotContains(t, upstreamBody, "stream_options")
+	assert.Equal(t, "kept", upstreamBody["custom_field"])
+	assert.Contains(t, w.Body.String(), `"content":"Hello"`)
+	assert.True(t, strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n"))
+}
+
+func TestGroupStreamDisabledUnsupportedRelay(t *testing.T) {
+	setupGroupStreamDB(t, "")
+
+	// 不支持包装的接口直接拒绝
+	c, _ := test.GetContext("POST", "/v1/messages", test.RequestJSONConfig(), strings.NewReader(`{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	c.Set("token_group", "trial")
+	relay := NewRelayClaudeOnly(c)
+	assert.Nil(t, relay.setRequest())
+	errWithCode := checkGroupStream(c, relay)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, "stream_disabled", errWithCode.Code)
+
+	// 非流式请求不受影响
+	c, _ = test.GetContext("POST", "/v1/messages", test.RequestJSONConfig(), strings.NewReader(`{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`))
+	c.Set("token_group", "trial")
+	relay = NewRelayClaudeOnly(c)
+	assert.Nil(t, relay.setRequest())
+	assert.Nil(t, checkGroupStream(c, relay))
+}
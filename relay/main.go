@@ -9,6 +9,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/retry"
 	"one-api/common/utils"
 	"one-api/metrics"
 	"one-api/model"
@@ -36,12 +37,24 @@ func Relay(c *gin.Context) {
 		return
 	}
 
+	if openaiErr := checkGroupStream(c, relay); openaiErr != nil {
+		relay.HandleJsonError(openaiErr)
+		return
+	}
+
 	c.Set("is_stream", relay.IsStream())
-	if err := relay.setProvider(relay.getOriginalModel()); err != nil {
-		openaiErr := common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable)
+	releaseStreamSlot, openaiErr := acquireStreamSlot(c, relay.getOriginalModel())
+	if openaiErr != nil {
 		relay.HandleJsonError(openaiErr)
 		return
 	}
+	defer releaseStreamSlot()
+
+	setRequestMetadata(c)
+	if err := relay.setProvider(relay.getOriginalModel()); err != nil {
+		relay.HandleJsonError(channelUnavailableError(c, err))
+		return
+	}
 
 	heartbeat := relay.SetHeartbeat(relay.IsStream())
 	if heartbeat != nil {
@@ -75,6 +88,11 @@ func Relay(c *gin.Context) {
 			break
 		}
 
+		// 预算耗尽时返回最后一次上游错误
+		if !retry.Acquire(c) {
+			break
+		}
+
 		if err := relay.setProvider(relay.getOriginalModel()); err != nil {
 			break
 		}
@@ -93,6 +111,10 @@ func Relay(c *gin.Context) {
 	}
 
 	if apiErr != nil {
+		if attempts := retry.Attempts(c); attempts > 0 {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("relay failed after %d retry attempts, status code is %d", attempts, apiErr.StatusCode))
+		}
+		relay_util.SendCompletionWebhookError(c, relay.getOriginalModel(), apiErr)
 		if heartbeat != nil && heartbeat.IsSafeWriteStream() {
 			relay.HandleStreamError(apiErr)
 			return
@@ -110,29 +132,54 @@ func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCod
 		return
 	}
 
+	if err = checkStreamCostLimit(relay); err != nil {
+		done = true
+		return
+	}
+
 	usage := &types.Usage{
 		PromptTokens: promptTokens,
 	}
 
 	relay.getProvider().SetUsage(usage)
 
+	if err = relay_util.WaitChannelRPM(relay.getContext().Request.Context(), relay.getProvider().GetChannel(), getRequestPriority(relay.getContext())); err != nil {
+		return
+	}
+
 	quota := relay_util.NewQuota(relay.getContext(), relay.getModelName(), promptTokens)
 	if err = quota.PreQuotaConsumption(); err != nil {
 		done = true
 		return
 	}
 
+	// 非流式请求在客户端断开后没有继续的必要
+	if !relay.IsStream() {
+		bindUpstreamToClient(relay.getContext(), relay.getProvider())
+	}
+
+	costLimit := setStreamCostLimit(relay.getContext(), quota, relay.IsStream(), relay.getModelName())
 	err, done = relay.send()
-	// 最后处理流式中断时计算tokens
-	if usage.CompletionTokens == 0 && usage.TextBuilder.Len() > 0 {
-		usage.CompletionTokens = common.CountTokenText(usage.TextBuilder.String(), relay.getModelName())
-		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	// 超出费用上限中止时按已输出的内容计费
+	if costLimit == nil || !costLimit.ApplyUsage(usage) {
+		// 最后处理流式中断时计算tokens
+		estimateCompletionTokens(usage, relay.getModelName())
 	}
 	if err != nil {
+		if !relay.IsStream() && clientDisconnected(relay.getContext()) {
+			return handleClientDisconnect(relay.getContext(), quota, usage), true
+		}
+		// 上游已产生消耗的本地错误按实际用量计费
+		if err.Billed {
+			quota.Consume(relay.getContext(), usage, relay.IsStream())
+			return
+		}
 		quota.Undo(relay.getContext())
 		return
 	}
 
+	recountPromptTokens(relay.getContext(), relay.getProvider(), usage)
+
 	quota.SetFirstResponseTime(relay.GetFirstResponseTime())
 
 	quota.Consume(relay.getContext(), usage, relay.IsStream())
@@ -144,8 +191,8 @@ func shouldCooldowns(c *gin.Context, channel *model.Channel, apiErr *types.OpenA
 	modelName := c.GetString("new_model")
 	channelId := channel.Id
 
-	// 如果是频率限制，冻结通道
-	if apiErr.StatusCode == http.StatusTooManyRequests {
+	// 如果是上游的频率限制，冻结通道；本地排队超时只换渠道
+	if apiErr.StatusCode == http.StatusTooManyRequests && !isChannelRPMLimited(apiErr) {
 		model.ChannelGroup.SetCooldowns(channelId, modelName)
 	}
 
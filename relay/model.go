@@ -50,7 +50,7 @@ func ListModelsByToken(c *gin.Context) {
 
 	var groupOpenAIModels []*OpenAIModels
 	for _, modelName := range models {
-		groupOpenAIModels = append(groupOpenAIModels, getOpenAIModelWithName(modelName))
+		groupOpenAIModels = append(groupOpenAIModels, getDisplayOpenAIModel(c, modelName))
 	}
 
 	// 根据 OwnedBy 排序
@@ -136,8 +136,12 @@ func ListClaudeModelsByToken(c *gin.Context) {
 		// Get the price to check if it's a Gemini model (channel_type=25)
 		price := model.PricingInstance.GetPrice(modelName)
 		if price.ChannelType == config.ChannelTypeAnthropic {
+			modelId := modelName
+			if displayName := getModelDisplayName(c, modelName); displayName != "" {
+				modelId = displayName
+			}
 			claudeModelsData = append(claudeModelsData, claude.Model{
-				ID:   modelName,
+				ID:   modelId,
 				Type: "model",
 			})
 		}
@@ -178,7 +182,7 @@ func ListModelsForAdmin(c *gin.Context) {
 
 func RetrieveModel(c *gin.Context) {
 	modelName := c.Param("model")
-	openaiModel := getOpenAIModelWithName(modelName)
+	openaiModel := getDisplayOpenAIModel(c, resolveModelDisplayName(c, modelName))
 	if *openaiModel.OwnedBy != model.UnknownOwnedBy {
 		c.JSON(200, openaiModel)
 	} else {
@@ -214,6 +218,19 @@ func getOpenAIModelWithName(modelName string) *OpenAIModels {
 	}
 }
 
+// getDisplayOpenAIModel 配置了展示名称的模型使用展示名称，并以系统名称作为 owned_by，不暴露真实模型及其提供商
+func getDisplayOpenAIModel(c *gin.Context, modelName string) *OpenAIModels {
+	openaiModel := getOpenAIModelWithName(modelName)
+
+	if displayName := getModelDisplayName(c, modelName); displayName != "" {
+		ownedBy := config.SystemName
+		openaiModel.Id = displayName
+		openaiModel.OwnedBy = &ownedBy
+	}
+
+	return openaiModel
+}
+
 func GetModelOwnedBy(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
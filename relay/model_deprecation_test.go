@@ -0,0 +1,119 @@
+package relay
+
+import (
+	"io"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestApplyModelDeprecation(t *testing.T) {
+	config.ModelDeprecationSettingsInstance.SetRules(`{"claude-3-sonnet-20240229":"claude-sonnet-4-20250514"}`)
+	defer config.ModelDeprecationSettingsInstance.SetRules("")
+
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	assert.Equal(t, "claude-sonnet-4-20250514", applyModelDeprecation(c, "claude-3-sonnet-20240229"))
+	assert.Equal(t, "claude-3-sonnet-20240229", c.GetString("deprecated_model"))
+	assert.Equal(t, "claude-3-sonnet-20240229", w.Header().Get("X-Model-Deprecated"))
+	assert.Contains(t, w.Header().Get("Warning"), "claude-sonnet-4-20250514")
+
+	// 未废弃的模型保持不变
+	c, w = test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	assert.Equal(t, "claude-sonnet-4-20250514", applyModelDeprecation(c, "claude-sonnet-4-20250514"))
+	assert.Empty(t, c.GetString("deprecated_model"))
+	assert.Empty(t, w.Header().Get("X-Model-Deprecated"))
+
+	// 关闭提示时仍然替换
+	config.ModelDeprecationSettingsInstance.WarningHeader = false
+	defer func() { config.ModelDeprecationSettingsInstance.WarningHeader = true }()
+	c, w = test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	assert.Equal(t, "claude-sonnet-4-20250514", applyModelDeprecation(c, "claude-3-sonnet-20240229"))
+	assert.Equal(t, "claude-3-sonnet-20240229", c.GetString("deprecated_model"))
+	assert.Empty(t, w.Header().Get("X-Model-Deprecated"))
+	assert.Empty(t, w.Header().Get("Warning"))
+}
+
+func TestApplyModelDeprecationDisplayName(t *testing.T) {
+	config.ModelDeprecationSettingsInstance.SetRules(`{"claude-3-sonnet-20240229":"claude-sonnet-4-20250514"}`)
+	defer config.ModelDeprecationSettingsInstance.SetRules("")
+
+	// 配置了展示名称时响应头不暴露真实模型
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("token_setting", &model.TokenSetting{ModelDisplayNames: map[string]string{
+		"claude-3-sonnet-20240229": "reseller-old",
+		"claude-sonnet-4-20250514": "reseller-new",
+	}})
+	assert.Equal(t, "claude-sonnet-4-20250514", applyModelDeprecation(c, "claude-3-sonnet-20240229"))
+	assert.Equal(t, "reseller-old", w.Header().Get("X-Model-Deprecated"))
+	assert.Equal(t, `299 - "model reseller-old is deprecated, use reseller-new instead"`, w.Header().Get("Warning"))
+}
+
+func TestModelDeprecationLimitModel(t *testing.T) {
+	config.ModelDeprecationSettingsInstance.SetRules(`{"claude-3-sonnet-20240229":"claude-sonnet-4-20250514"}`)
+	defer config.ModelDeprecationSettingsInstance.SetRules("")
+
+	// 令牌只允许废弃的模型，替换后仍可使用
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), strings.NewReader(`{"model":"claude-3-sonnet-20240229","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`))
+	setting := &model.TokenSetting{}
+	setting.Limits.LimitModelSetting = model.LimitModelSetting{Enabled: true, Models: []string{"claude-3-sonnet-20240229"}}
+	c.Set("token_setting", setting)
+
+	relay := NewRelayChat(c)
+	assert.Nil(t, relay.setRequest())
+	assert.Equal(t, "claude-sonnet-4-20250514", relay.getOriginalModel())
+	assert.Nil(t, relay.checkLimitModel(relay.getOriginalModel()))
+
+	// 其他模型仍受限制
+	setting.Limits.LimitModelSetting.Models = []string{"claude-3-5-haiku-20241022"}
+	assert.NotNil(t, relay.checkLimitModel(relay.getOriginalModel()))
+}
+
+func TestModelDeprecationChat(t *testing.T) {
+	config.ModelDeprecationSettingsInstance.SetRules(`{"claude-3-sonnet-20240229":"claude-sonnet-4-20250514"}`)
+	defer config.ModelDeprecationSettingsInstance.SetRules("")
+	requester.InitHttpClient()
+
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// 上游收到替代模型
+		assert.Contains(t, string(body), `"model":"claude-sonnet-4-20250514"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.Channel{}))
+	model.DB = db
+	baseURL := ts.URL
+	channel := &model.Channel{Type: config.ChannelTypeAnthropic, Key: "sk-test", Status: config.ChannelStatusEnabled, BaseURL: &baseURL}
+	assert.Nil(t, db.Create(channel).Error)
+
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), strings.NewReader(`{"model":"claude-3-sonnet-20240229","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`))
+	c.Set("specific_channel_id", channel.Id)
+
+	relay := NewRelayChat(c)
+	assert.Nil(t, relay.setRequest())
+	assert.Equal(t, "claude-sonnet-4-20250514", relay.getOriginalModel())
+	assert.Nil(t, relay.setProvider(relay.getOriginalModel()))
+	relay.getProvider().SetUsage(&types.Usage{})
+
+	errWithCode, _ := relay.send()
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "claude-3-sonnet-20240229", w.Header().Get("X-Model-Deprecated"))
+	assert.Contains(t, w.Header().Get("Warning"), "deprecated")
+	assert.Contains(t, w.Body.String(), "Hello")
+}
@@ -0,0 +1,110 @@
+package relay
+
+import (
+	"one-api/common/config"
+	"one-api/common/utils"
+	"one-api/model"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 响应中的第一个 model 字段，流式块与 Claude 的 message_start 中都只出现一次
+var responseModelRegex = regexp.MustCompile(`"model"\s*:\s*"(?:[^"\\]|\\.)*"`)
+
+// getModelDisplayNames 获取当前请求可用的模型展示名称，令牌配置优先于分组配置
+func getModelDisplayNames(c *gin.Context) map[string]string {
+	if c == nil {
+		return nil
+	}
+
+	groupName := c.GetString("token_group")
+	if groupName == "" {
+		groupName = c.GetString("group")
+	}
+	groupNames := config.ModelDisplayNameSettingsInstance.GetDisplayNames(groupName)
+
+	tokenSetting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting")
+	if !ok || tokenSetting == nil || len(tokenSetting.ModelDisplayNames) == 0 {
+		return groupNames
+	}
+
+	displayNames := make(map[string]string, len(groupNames)+len(tokenSetting.ModelDisplayNames))
+	for realModel, displayName := range groupNames {
+		displayNames[realModel] = displayName
+	}
+	for realModel, displayName := range tokenSetting.ModelDisplayNames {
+		displayNames[realModel] = displayName
+	}
+
+	return displayNames
+}
+
+// getModelDisplayName 获取模型对外展示的名称，未配置时返回空字符串
+func getModelDisplayName(c *gin.Context, modelName string) string {
+	return getModelDisplayNames(c)[modelName]
+}
+
+// displayModelName 返回对客户端展示的模型名称，未配置展示名称时返回真实模型
+func displayModelName(c *gin.Context, modelName string) string {
+	if displayName := getModelDisplayName(c, modelName); displayName != "" {
+		return displayName
+	}
+
+	return modelName
+}
+
+// resolveModelDisplayName 将客户端请求中的展示名称还原为真实模型，真实模型名称仍可直接使用
+func resolveModelDisplayName(c *gin.Context, modelName string) string {
+	for realModel, displayName := range getModelDisplayNames(c) {
+		if displayName == modelName {
+			return realModel
+		}
+	}
+
+	return modelName
+}
+
+// maskResponseModel 将响应中的 model 字段替换为请求模型的展示名称
+func maskResponseModel(c *gin.Context, data string) string {
+	displayName := c.GetString(config.GinModelDisplayNameKey)
+	if displayName == "" {
+		return data
+	}
+
+	replaced := false
+	return responseModelRegex.ReplaceAllStringFunc(data, func(match string) string {
+		if replaced {
+			return match
+		}
+		replaced = true
+		return `"model":` + strconv.Quote(displayName)
+	})
+}
+
+// maskErrorMessage 将错误信息中出现的真实模型替换为展示名称
+func maskErrorMessage(c *gin.Context, message string) string {
+	displayNames := getModelDisplayNames(c)
+	if len(displayNames) == 0 {
+		return message
+	}
+
+	// 先替换较长的名称，避免前缀相同的模型被部分替换
+	realModels := make([]string, 0, len(displayNames))
+	for realModel, displayName := range displayNames {
+		if realModel != "" && displayName != "" {
+			realModels = append(realModels, realModel)
+		}
+	}
+	sort.Slice(realModels, func(i, j int) bool { return len(realModels[i]) > len(realModels[j]) })
+
+	replacements := make([]string, 0, len(realModels)*2)
+	for _, realModel := range realModels {
+		replacements = append(replacements, realModel, displayNames[realModel])
+	}
+
+	return strings.NewReplacer(replacements...).Replace(message)
+}
@@ -0,0 +1,151 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setModelDisplayNames(t *testing.T) {
+	config.ModelDisplayNameSettingsInstance.SetGroups(`{"default":{"claude-3-5-sonnet-20241022":"acme-pro","claude-3-5-haiku-20241022":"acme-lite"}}`)
+	t.Cleanup(func() { config.ModelDisplayNameSettingsInstance.SetGroups("") })
+}
+
+func newDisplayNameContext(body string) (*gin.Context, *bytes.Buffer) {
+	c, w := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), strings.NewReader(body))
+	c.Set("group", "default")
+	return c, w.Body
+}
+
+func TestModelDisplayNameResolve(t *testing.T) {
+	setModelDisplayNames(t)
+
+	c, _ := newDisplayNameContext("")
+	assert.Equal(t, "claude-3-5-sonnet-20241022", resolveModelDisplayName(c, "acme-pro"))
+	assert.Equal(t, "claude-3-5-sonnet-20241022", resolveModelDisplayName(c, "claude-3-5-sonnet-20241022"))
+	assert.Equal(t, "gpt-4o", resolveModelDisplayName(c, "gpt-4o"))
+
+	// 令牌配置优先于分组配置
+	c.Set("token_setting", &model.TokenSetting{ModelDisplayNames: map[string]string{"claude-3-5-haiku-20241022": "reseller-mini"}})
+	assert.Equal(t, "reseller-mini", getModelDisplayName(c, "claude-3-5-haiku-20241022"))
+	assert.Equal(t, "acme-pro", getModelDisplayName(c, "claude-3-5-sonnet-20241022"))
+	assert.Equal(t, "claude-3-5-haiku-20241022", resolveModelDisplayName(c, "reseller-mini"))
+
+	// 其他分组不受影响
+	other, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	other.Set("group", "vip")
+	assert.Empty(t, getModelDisplayName(other, "claude-3-5-sonnet-20241022"))
+	assert.Equal(t, "acme-pro", resolveModelDisplayName(other, "acme-pro"))
+}
+
+func TestModelDisplayNameChat(t *testing.T) {
+	setModelDisplayNames(t)
+	requester.InitHttpClient()
+
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// 上游收到真实模型
+		assert.Contains(t, string(body), `"model":"claude-3-5-sonnet-20241022"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	defer ts.Close()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.Channel{}))
+	model.DB = db
+	baseURL := ts.URL
+	channel := &model.Channel{Type: config.ChannelTypeAnthropic, Key: "sk-test", Status: config.ChannelStatusEnabled, BaseURL: &baseURL}
+	assert.Nil(t, db.Create(channel).Error)
+
+	c, body := newDisplayNameContext(`{"model":"acme-pro","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	c.Set("specific_channel_id", channel.Id)
+
+	relay := NewRelayChat(c)
+	assert.Nil(t, relay.setRequest())
+	assert.Equal(t, "claude-3-5-sonnet-20241022", relay.getOriginalModel())
+	assert.Nil(t, relay.setProvider(relay.getOriginalModel()))
+	relay.getProvider().SetUsage(&types.Usage{})
+
+	errWithCode, _ := relay.send()
+	assert.Nil(t, errWithCode)
+
+	// 客户端看到展示名称，日志中记录真实模型
+	var response types.ChatCompletionResponse
+	assert.Nil(t, json.Unmarshal(body.Bytes(), &response))
+	assert.Equal(t, "acme-pro", response.Model)
+	assert.NotContains(t, body.String(), "claude")
+	assert.Equal(t, "claude-3-5-sonnet-20241022", c.GetString("original_model"))
+	assert.Equal(t, "claude-3-5-sonnet-20241022", relay.getModelName())
+}
+
+func TestModelDisplayNameStream(t *testing.T) {
+	setModelDisplayNames(t)
+
+	c, body := newDisplayNameContext("")
+	c.Set(config.GinModelDisplayNameKey, "acme-pro")
+	stream := newMockStream([]string{
+		`{"id":"1","object":"chat.completion.chunk","model":"claude-3-5-sonnet-20241022","choices":[{"index":0,"delta":{"content":"\"model\":\"x\""}}]}`,
+	}, io.EOF)
+
+	_, errWithCode := responseStreamClient(c, stream, func() string {
+		return `{"id":"1","object":"chat.completion.chunk","model":"claude-3-5-sonnet-20241022","choices":[]}`
+	})
+	assert.Nil(t, errWithCode)
+	assert.NotContains(t, body.String(), "claude")
+	assert.Equal(t, 2, strings.Count(body.String(), `"model":"acme-pro"`))
+	// 内容中的文本不受影响
+	assert.Contains(t, body.String(), `\"model\":\"x\"`)
+}
+
+func TestModelDisplayNameError(t *testing.T) {
+	setModelDisplayNames(t)
+
+	c, _ := newDisplayNameContext("")
+	errWithCode := FilterOpenAIErr(c, &types.OpenAIErrorWithStatusCode{
+		OpenAIError: types.OpenAIError{Message: "Model claude-3-5-sonnet-20241022 is not supported for current token", Type: "invalid_request_error"},
+		StatusCode:  http.StatusNotFound,
+		LocalError:  true,
+	})
+	assert.Contains(t, errWithCode.Message, "Model acme-pro is not supported for current token")
+	assert.NotContains(t, errWithCode.Message, "claude")
+}
+
+func TestModelDisplayNameListModels(t *testing.T) {
+	setModelDisplayNames(t)
+	model.PricingInstance = &model.Pricing{Prices: map[string]*model.Price{
+		"claude-3-5-sonnet-20241022": {Model: "claude-3-5-sonnet-20241022", Type: model.TokensPriceType, ChannelType: config.ChannelTypeAnthropic},
+	}}
+	model.ModelOwnedBysInstance = &model.ModelOwnedBys{}
+	defer func() {
+		model.PricingInstance = nil
+		model.ModelOwnedBysInstance = nil
+	}()
+
+	c, body := newDisplayNameContext("")
+	openaiModel := getDisplayOpenAIModel(c, "claude-3-5-sonnet-20241022")
+	assert.Equal(t, "acme-pro", openaiModel.Id)
+	assert.Equal(t, config.SystemName, *openaiModel.OwnedBy)
+
+	c.Params = gin.Params{{Key: "model", Value: "acme-pro"}}
+	RetrieveModel(c)
+	assert.Contains(t, body.String(), `"id":"acme-pro"`)
+	assert.NotContains(t, body.String(), "claude")
+}
@@ -0,0 +1,37 @@
+package relay
+
+import (
+	"encoding/json"
+	"one-api/common/test"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromptCacheDisabledHeader(t *testing.T) {
+	headers := test.RequestJSONConfig()
+	headers[disablePromptCacheHeader] = "true"
+
+	c, _ := test.GetContext("POST", "/v1/chat/completions", headers, strings.NewReader(`{"model":"claude-3-5-sonnet-20241022","messages":[{"role":"user","content":"hi"}]}`))
+	chat := NewRelayChat(c)
+	assert.Nil(t, chat.setRequest())
+	assert.True(t, chat.chatRequest.DisablePromptCache)
+
+	// 原生 Claude 请求中客户端自带的缓存标记也被移除
+	body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"system":[{"type":"text","text":"rules","cache_control":{"type":"ephemeral"}}],"messages":[{"role":"user","content":[{"type":"text","text":"hi","cache_control":{"type":"ephemeral"}}]}]}`
+	c, _ = test.GetContext("POST", "/v1/messages", headers, strings.NewReader(body))
+	native := NewRelayClaudeOnly(c)
+	assert.Nil(t, native.setRequest())
+	assert.True(t, native.claudeRequest.DisablePromptCache)
+	requestBody, _ := json.Marshal(native.claudeRequest)
+	assert.NotContains(t, string(requestBody), "cache_control")
+
+	// 未设置请求头时保持原样
+	c, _ = test.GetContext("POST", "/v1/messages", test.RequestJSONConfig(), strings.NewReader(body))
+	native = NewRelayClaudeOnly(c)
+	assert.Nil(t, native.setRequest())
+	assert.False(t, native.claudeRequest.DisablePromptCache)
+	requestBody, _ = json.Marshal(native.claudeRequest)
+	assert.Equal(t, 2, strings.Count(string(requestBody), "cache_control"))
+}
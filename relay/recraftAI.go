@@ -7,6 +7,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/retry"
 	"one-api/metrics"
 	"one-api/providers/recraftAI"
 	"one-api/relay/relay_util"
@@ -59,6 +60,9 @@ func RelayRecraftAI(c *gin.Context) {
 
 	for i := retryTimes; i > 0; i-- {
 		shouldCooldowns(c, channel, apiErr)
+		if !retry.Acquire(c) {
+			break
+		}
 		if recraftProvider, err = getRecraftProvider(c, model); err != nil {
 			continue
 		}
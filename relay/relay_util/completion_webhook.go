@@ -0,0 +1,99 @@
+package relay_util
+
+import (
+	"context"
+	"encoding/json"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/retry"
+	"one-api/common/utils"
+	"one-api/common/webhook"
+	"one-api/model"
+	"one-api/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompletionSummary 请求完成后发送给令牌回调地址的摘要
+type CompletionSummary struct {
+	RequestId        string          `json:"request_id"`
+	TokenId          int             `json:"token_id"`
+	Model            string          `json:"model"`
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	Quota            int             `json:"quota"`
+	Cost             float64         `json:"cost"`
+	Success          bool            `json:"success"`
+	Error            string          `json:"error,omitempty"`
+	RetryAttempts    int             `json:"retry_attempts,omitempty"`
+	Request          json.RawMessage `json:"request,omitempty"`
+	CreatedAt        int64           `json:"created_at"`
+}
+
+// completionWebhook 在创建计费时从上下文中取出回调配置，计费完成后使用
+type completionWebhook struct {
+	url       string
+	requestId string
+	request   json.RawMessage
+}
+
+func newCompletionWebhook(c *gin.Context) *completionWebhook {
+	setting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting")
+	if !ok || setting == nil || setting.CompletionWebhook == nil || setting.CompletionWebhook.URL == "" {
+		return nil
+	}
+
+	hook := &completionWebhook{
+		url:       setting.CompletionWebhook.URL,
+		requestId: c.GetString(logger.RequestIdKey),
+	}
+	if setting.CompletionWebhook.IncludeContent {
+		if body, ok := utils.GetGinValue[[]byte](c, config.GinRequestBodyKey); ok && json.Valid(body) {
+			hook.request = body
+		}
+	}
+
+	return hook
+}
+
+func (h *completionWebhook) newSummary(tokenId int, modelName string) *CompletionSummary {
+	return &CompletionSummary{
+		RequestId: h.requestId,
+		TokenId:   tokenId,
+		Model:     modelName,
+		Request:   h.request,
+		CreatedAt: time.Now().Unix(),
+	}
+}
+
+// sendCompletionWebhook 计费完成后发送成功的摘要
+func (q *Quota) sendCompletionWebhook(ctx context.Context, usage *types.Usage, quota int) {
+	if q.webhook == nil {
+		return
+	}
+
+	summary := q.webhook.newSummary(q.tokenId, q.modelName)
+	summary.PromptTokens = usage.PromptTokens
+	summary.CompletionTokens = usage.CompletionTokens
+	summary.Quota = quota
+	summary.Cost = float64(quota) / config.QuotaPerUnit
+	summary.Success = true
+	summary.RetryAttempts = q.retryAttempts
+
+	webhook.Deliver(ctx, q.webhook.url, summary)
+}
+
+// SendCompletionWebhookError 请求最终失败时发送错误摘要，失败的请求不计费
+func SendCompletionWebhookError(c *gin.Context, modelName string, apiErr *types.OpenAIErrorWithStatusCode) {
+	hook := newCompletionWebhook(c)
+	if hook == nil || apiErr == nil {
+		return
+	}
+
+	summary := hook.newSummary(c.GetInt("token_id"), modelName)
+	summary.Error = apiErr.Message
+	summary.RetryAttempts = retry.Attempts(c)
+
+	webhook.Deliver(c.Request.Context(), hook.url, summary)
+}
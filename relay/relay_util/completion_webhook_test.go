@@ -0,0 +1,99 @@
+package relay_util
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Logger = zap.NewNop()
+}
+
+func newWebhookServer(t *testing.T) (*httptest.Server, chan map[string]any) {
+	// 测试服务监听在回环地址
+	config.WebhookAllowPrivateNetwork = true
+	t.Cleanup(func() { config.WebhookAllowPrivateNetwork = false })
+
+	received := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var summary map[string]any
+		json.NewDecoder(r.Body).Decode(&summary)
+		received <- summary
+	}))
+	return server, received
+}
+
+func waitWebhook(t *testing.T, received chan map[string]any) map[string]any {
+	select {
+	case summary := <-received:
+		return summary
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+		return nil
+	}
+}
+
+func TestCompletionWebhookSummary(t *testing.T) {
+	server, received := newWebhookServer(t)
+	defer server.Close()
+
+	body := `{"model":"claude-3-5-sonnet","messages":[{"role":"user","content":"secret prompt"}]}`
+	newQuota := func(includeContent bool) *Quota {
+		c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), strings.NewReader(body))
+		c.Set(config.GinRequestBodyKey, []byte(body))
+		c.Set(logger.RequestIdKey, "req-1")
+		c.Set("token_id", 7)
+		c.Set("token_setting", &model.TokenSetting{
+			CompletionWebhook: &model.CompletionWebhookSetting{URL: server.URL, IncludeContent: includeContent},
+		})
+		return &Quota{modelName: "claude-3-5-sonnet", tokenId: c.GetInt("token_id"), webhook: newCompletionWebhook(c)}
+	}
+
+	// 默认只发送摘要，不包含内容
+	newQuota(false).sendCompletionWebhook(context.Background(), &types.Usage{PromptTokens: 10, CompletionTokens: 5}, 1000)
+	summary := waitWebhook(t, received)
+	assert.Equal(t, "req-1", summary["request_id"])
+	assert.Equal(t, float64(7), summary["token_id"])
+	assert.Equal(t, "claude-3-5-sonnet", summary["model"])
+	assert.Equal(t, float64(10), summary["prompt_tokens"])
+	assert.Equal(t, float64(5), summary["completion_tokens"])
+	assert.Equal(t, float64(1000), summary["quota"])
+	assert.Equal(t, 1000/config.QuotaPerUnit, summary["cost"])
+	assert.Equal(t, true, summary["success"])
+	assert.NotContains(t, summary, "request")
+
+	// 开启后包含请求内容
+	newQuota(true).sendCompletionWebhook(context.Background(), &types.Usage{PromptTokens: 10, CompletionTokens: 5}, 1000)
+	summary = waitWebhook(t, received)
+	assert.Contains(t, summary["request"], "messages")
+
+	// 请求失败时发送错误摘要
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("token_setting", &model.TokenSetting{CompletionWebhook: &model.CompletionWebhookSetting{URL: server.URL}})
+	SendCompletionWebhookError(c, "claude-3-5-sonnet", &types.OpenAIErrorWithStatusCode{
+		OpenAIError: types.OpenAIError{Message: "upstream error"},
+		StatusCode:  http.StatusBadGateway,
+	})
+	summary = waitWebhook(t, received)
+	assert.Equal(t, false, summary["success"])
+	assert.Equal(t, "upstream error", summary["error"])
+}
+
+func TestCompletionWebhookDisabled(t *testing.T) {
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	c.Set("token_setting", &model.TokenSetting{})
+	assert.Nil(t, newCompletionWebhook(c))
+}
@@ -13,6 +13,8 @@ type ExtraServicePriceConfig struct {
 	FileSearch float64 `json:"file_search"`
 	// Code Interpreter 价格
 	CodeInterpreter float64 `json:"code_interpreter"`
+	// Claude 代码执行容器价格，按每次执行的最低计费时长（5 分钟）计算
+	CodeExecution float64 `json:"code_execution"`
 
 	ImageGeneration map[string]map[string]float64 `json:"image_generation"`
 }
@@ -24,6 +26,7 @@ var defaultExtraServicePrices = ExtraServicePriceConfig{
 	},
 	FileSearch:      0.0025,
 	CodeInterpreter: 0.03,
+	CodeExecution:   0.05 / 12,
 	ImageGeneration: map[string]map[string]float64{
 		"low": {
 			"1024x1024": 0.011,
@@ -61,6 +64,8 @@ func getDefaultExtraServicePrice(serviceType, modelName, extraType string) float
 		return defaultExtraServicePrices.FileSearch
 	case types.APITollTypeCodeInterpreter:
 		return defaultExtraServicePrices.CodeInterpreter
+	case types.APITollTypeCodeExecution:
+		return defaultExtraServicePrices.CodeExecution
 
 	case types.APITollTypeImageGeneration:
 		if extraType == "" {
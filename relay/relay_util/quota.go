@@ -8,6 +8,8 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/retry"
+	"one-api/common/utils"
 	"one-api/model"
 	"one-api/types"
 	"time"
@@ -27,6 +29,7 @@ type Quota struct {
 	outputRatio      float64
 	preConsumedQuota int
 	cacheQuota       int
+	userQuota        int // 预扣费时读取的用户余额，-1 为未读取
 	userId           int
 	channelId        int
 	tokenId          int
@@ -36,8 +39,20 @@ type Quota struct {
 	startTime         time.Time
 	firstResponseTime time.Time
 	extraBillingData  map[string]ExtraBillingData
+	requestMetadata   map[string]any
+	downgradedFrom    string
+	deprecatedModel   string
+	retryAttempts     int
+	webhook           *completionWebhook
+	costCeiling       int // 流式输出因费用上限中止时的上限，0 为未中止
+	upstreamRequestId string
+	skipVerboseLog    bool // 未被渠道采样，日志只保留计费相关信息
+	channelTest       bool // 管理员测试渠道产生的计费，没有令牌
 }
 
+// 未采样的请求不记录的详细日志字段
+var verboseLogMetaKeys = []string{"first_response", "requested_model", "upstream_request_id", "retry_attempts", "request_metadata"}
+
 func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 	isBackupGroup := c.GetBool("is_backupGroup")
 
@@ -49,9 +64,16 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 		tokenId:        c.GetInt("token_id"),
 		unlimitedQuota: c.GetBool("token_unlimited_quota"),
 		HandelStatus:   false,
+		userQuota:      -1,
 		isBackupGroup:  isBackupGroup, // 记录是否使用备用分组
+		skipVerboseLog: c.GetBool("skip_verbose_log"),
+		channelTest:    c.GetBool("channel_test"),
 	}
 
+	quota.requestMetadata, _ = utils.GetGinValue[map[string]any](c, config.GinRequestMetadataKey)
+	quota.downgradedFrom = c.GetString("downgraded_from")
+	quota.deprecatedModel = c.GetString("deprecated_model")
+	quota.webhook = newCompletionWebhook(c)
 	quota.price = *model.PricingInstance.GetPrice(quota.modelName)
 	quota.groupName = c.GetString("token_group")
 	quota.backupGroupName = c.GetString("token_backup_group")
@@ -79,6 +101,8 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 		return common.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 	}
 
+	q.userQuota = userQuota
+
 	if userQuota < q.preConsumedQuota {
 		return common.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusPaymentRequired)
 	}
@@ -156,6 +180,9 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 			return errors.New("error consuming token remain quota: " + err.Error())
 		}
 		model.UpdateChannelUsedQuota(q.channelId, quota)
+		if q.userQuota >= 0 {
+			model.CheckUserQuotaRemind(q.userId, q.userQuota-quota)
+		}
 	}
 
 	model.RecordConsumeLog(
@@ -174,6 +201,7 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 		sourceIp,
 	)
 	model.UpdateUserUsedQuotaAndRequestCount(q.userId, quota)
+	q.sendCompletionWebhook(ctx, usage, quota)
 
 	return nil
 }
@@ -193,6 +221,10 @@ func (q *Quota) Undo(c *gin.Context) {
 func (q *Quota) Consume(c *gin.Context, usage *types.Usage, isStream bool) {
 	tokenName := c.GetString("token_name")
 	q.startTime = c.GetTime("requestStartTime")
+	q.retryAttempts = retry.Attempts(c)
+	if config.UpstreamRequestIdLogEnabled {
+		q.upstreamRequestId = c.GetString(config.GinUpstreamRequestIdKey)
+	}
 	// 如果没有报错，则消费配额
 	go func(ctx context.Context) {
 		err := q.completedQuotaConsumption(usage, tokenName, isStream, c.ClientIP(), ctx)
@@ -223,6 +255,16 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 	}
 
 	if usage != nil {
+		if usage.Estimated {
+			meta["usage_estimated"] = true
+		}
+		if usage.PromptEstimated {
+			meta["prompt_tokens_estimated"] = true
+		}
+		if q.costCeiling > 0 {
+			meta["cost_ceiling"] = q.costCeiling
+		}
+
 		extraTokens := usage.GetExtraTokens()
 
 		for key, value := range extraTokens {
@@ -230,12 +272,48 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 			extraRatio := q.price.GetExtraRatio(key)
 			meta[key+"_ratio"] = extraRatio
 		}
+
+		if q.price.Type != model.TimesPriceType && usage.PromptTokens+usage.CompletionTokens > 0 {
+			if billingTokens := getBillingCompletionTokens(usage.CompletionTokens); billingTokens != usage.CompletionTokens {
+				meta["billing_completion_tokens"] = billingTokens
+			}
+		}
 	}
 
 	if q.extraBillingData != nil {
 		meta["extra_billing"] = q.extraBillingData
 	}
 
+	if q.downgradedFrom != "" {
+		meta["requested_model"] = q.downgradedFrom
+	}
+
+	if q.deprecatedModel != "" {
+		meta["deprecated_model"] = q.deprecatedModel
+	}
+
+	if q.upstreamRequestId != "" {
+		meta["upstream_request_id"] = q.upstreamRequestId
+	}
+
+	if q.retryAttempts > 0 {
+		meta["retry_attempts"] = q.retryAttempts
+	}
+
+	if q.channelTest {
+		meta["channel_test"] = true
+	}
+
+	if q.requestMetadata != nil {
+		meta["request_metadata"] = q.requestMetadata
+	}
+
+	if q.skipVerboseLog {
+		for _, key := range verboseLogMetaKeys {
+			delete(meta, key)
+		}
+	}
+
 	return meta
 }
 
@@ -319,7 +397,43 @@ func (q *Quota) getComputeTokensByUsageEvent(usage *types.UsageEvent) (promptTok
 // 通过 usage 获取消费配额
 func (q *Quota) GetTotalQuotaByUsage(usage *types.Usage) (quota int) {
 	promptTokens, completionTokens := q.getComputeTokensByUsage(usage)
-	return q.GetTotalQuota(promptTokens, completionTokens, usage.ExtraBilling)
+	if usage.PromptTokens+usage.CompletionTokens > 0 {
+		completionTokens = getBillingCompletionTokens(completionTokens)
+	}
+	quota = q.GetTotalQuota(promptTokens, completionTokens, usage.ExtraBilling)
+	if q.costCeiling > 0 {
+		quota = q.capCompletionQuota(quota, completionTokens)
+	}
+	return quota
+}
+
+// capCompletionQuota 只限制补全部分的费用，提示和附加计费不受上限影响
+func (q *Quota) capCompletionQuota(quota, completionTokens int) int {
+	if q.price.Type == model.TimesPriceType {
+		return quota
+	}
+
+	completionQuota := int(math.Ceil(float64(completionTokens) * q.outputRatio))
+	allowed := max(q.costCeiling-(quota-completionQuota), 0)
+	if completionQuota > allowed {
+		quota -= completionQuota - allowed
+	}
+
+	return quota
+}
+
+// getBillingCompletionTokens 按最低数量和块大小调整计费用的补全 token 数
+func getBillingCompletionTokens(completionTokens int) int {
+	if completionTokens < config.BillingMinCompletionTokens {
+		completionTokens = config.BillingMinCompletionTokens
+	}
+
+	block := config.BillingCompletionTokenBlock
+	if block > 1 && completionTokens%block != 0 {
+		completionTokens = (completionTokens/block + 1) * block
+	}
+
+	return completionTokens
 }
 
 func (q *Quota) GetFirstResponseTime() int64 {
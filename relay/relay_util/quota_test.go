@@ -0,0 +1,120 @@
+package relay_util
+
+import (
+	"context"
+	"math"
+	"one-api/common/config"
+	"one-api/model"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetTotalQuotaByUsageBillingCompletionTokens(t *testing.T) {
+	defer func() {
+		config.BillingMinCompletionTokens = 0
+		config.BillingCompletionTokenBlock = 0
+	}()
+
+	quota := &Quota{
+		price:       model.Price{Type: model.TokensPriceType, Input: 1, Output: 2},
+		groupRatio:  1,
+		inputRatio:  1,
+		outputRatio: 2,
+	}
+	usage := &types.Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13}
+
+	// 未配置时按实际用量计费
+	assert.Equal(t, 16, quota.GetTotalQuotaByUsage(usage))
+
+	// 最低补全 token 数
+	config.BillingMinCompletionTokens = 50
+	assert.Equal(t, 110, quota.GetTotalQuotaByUsage(usage))
+	assert.Equal(t, 50, quota.GetLogMeta(usage)["billing_completion_tokens"])
+	assert.Equal(t, 3, usage.CompletionTokens)
+
+	// 按块向上取整
+	config.BillingMinCompletionTokens = 0
+	config.BillingCompletionTokenBlock = 64
+	usage.CompletionTokens = 70
+	assert.Equal(t, 10+128*2, quota.GetTotalQuotaByUsage(usage))
+	assert.Equal(t, 70, usage.CompletionTokens)
+
+	assert.Nil(t, quota.GetLogMeta(usage)["usage_estimated"])
+	usage.Estimated = true
+	assert.Equal(t, true, quota.GetLogMeta(usage)["usage_estimated"])
+	assert.Nil(t, quota.GetLogMeta(usage)["prompt_tokens_estimated"])
+	usage.PromptEstimated = true
+	assert.Equal(t, true, quota.GetLogMeta(usage)["prompt_tokens_estimated"])
+
+	assert.Nil(t, quota.GetLogMeta(usage)["upstream_request_id"])
+	quota.upstreamRequestId = "req_upstream_1"
+	assert.Equal(t, "req_upstream_1", quota.GetLogMeta(usage)["upstream_request_id"])
+
+	// 没有任何用量的请求不计费
+	config.BillingMinCompletionTokens = 50
+	assert.Equal(t, 0, quota.GetTotalQuotaByUsage(&types.Usage{}))
+}
+
+func TestGetTotalQuotaCodeExecutionBilling(t *testing.T) {
+	quota := &Quota{
+		modelName:   "claude-sonnet-4-20250514",
+		price:       model.Price{Type: model.TokensPriceType, Input: 1, Output: 1},
+		groupRatio:  1,
+		inputRatio:  1,
+		outputRatio: 1,
+	}
+	usage := &types.Usage{PromptTokens: 20, CompletionTokens: 30, TotalTokens: 50}
+	usage.IncExtraBilling(types.APITollTypeCodeExecution, "")
+
+	executionQuota := int(math.Ceil(defaultExtraServicePrices.CodeExecution * config.QuotaPerUnit))
+	assert.Equal(t, 50+executionQuota, quota.GetTotalQuotaByUsage(usage))
+	assert.Contains(t, quota.GetLogMeta(usage)["extra_billing"], types.APITollTypeCodeExecution)
+}
+
+func TestVerboseLogSampling(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.Log{}, &model.User{}, &model.Channel{}))
+	model.DB = db
+	assert.Nil(t, db.Create(&model.User{Id: 1, Username: "sampling", Quota: 100000}).Error)
+
+	channel := &model.Channel{Id: 1, LogSampleRate: 0.2}
+	usage := &types.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	requests := 2000
+	for i := 0; i < requests; i++ {
+		quota := &Quota{
+			modelName:        "gpt-4o",
+			userId:           1,
+			channelId:        channel.Id,
+			price:            model.Price{Type: model.TokensPriceType, Input: 1, Output: 1},
+			groupRatio:       1,
+			inputRatio:       1,
+			outputRatio:      1,
+			preConsumedQuota: 15,
+			requestMetadata:  map[string]any{"trace": "abc"},
+			skipVerboseLog:   !channel.SampleVerboseLog(),
+		}
+		assert.Nil(t, quota.completedQuotaConsumption(usage, "token", false, "127.0.0.1", context.Background()))
+	}
+
+	// 所有请求都有计费记录
+	var logs []model.Log
+	assert.Nil(t, db.Find(&logs).Error)
+	assert.Len(t, logs, requests)
+
+	verbose := 0
+	for _, log := range logs {
+		assert.Equal(t, 15, log.Quota)
+		meta := log.Metadata.Data()
+		assert.Equal(t, "gpt-4o", log.ModelName)
+		assert.NotNil(t, meta["input_ratio"])
+		if meta["request_metadata"] != nil {
+			verbose++
+		}
+	}
+	assert.InDelta(t, requests/5, verbose, 100)
+}
@@ -0,0 +1,204 @@
+package relay_util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/metrics"
+	"one-api/model"
+	"one-api/types"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// 请求优先级，数值越大越先发送
+const (
+	PriorityLow = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+type rpmWaiter struct {
+	priority   int
+	enqueued   time.Time
+	ready      chan struct{}
+	dispatched bool
+}
+
+// effectivePriority 排队时间每超过一个老化周期，优先级提升一级，避免低优先级请求被无限期饿死
+func (w *rpmWaiter) effectivePriority(now time.Time) int {
+	period := agingPeriod()
+	if period <= 0 {
+		return w.priority
+	}
+
+	return w.priority + int(now.Sub(w.enqueued)/period)
+}
+
+// agingPeriod 老化周期不超过最长等待时间按优先级级数均分的时长，保证最低优先级的请求在等待超时前能提升到最高优先级
+func agingPeriod() time.Duration {
+	if config.RequestPriorityAgingSeconds <= 0 {
+		return 0
+	}
+
+	period := time.Duration(config.RequestPriorityAgingSeconds) * time.Second
+	if maxWait := time.Duration(config.ChannelRPMMaxWaitSeconds) * time.Second; maxWait > 0 {
+		period = min(period, maxWait/(PriorityHigh-PriorityLow+1))
+	}
+
+	return period
+}
+
+type channelRPMLimiter struct {
+	sync.Mutex
+	rpm     int
+	limiter *rate.Limiter
+	waiters []*rpmWaiter
+	running bool
+}
+
+// 每个渠道（多 key 渠道的每个 key 都是独立渠道）一个令牌桶，突发为 1，按 RPM 均匀放行
+var channelRPMLimiters sync.Map
+
+func getChannelRPMLimiter(channelId, rpm int) *channelRPMLimiter {
+	if value, ok := channelRPMLimiters.Load(channelId); ok {
+		if limiter := value.(*channelRPMLimiter); limiter.rpm == rpm {
+			return limiter
+		}
+	}
+
+	limiter := newChannelRPMLimiter(rpm)
+	for {
+		value, loaded := channelRPMLimiters.LoadOrStore(channelId, limiter)
+		if !loaded {
+			return limiter
+		}
+
+		current := value.(*channelRPMLimiter)
+		if current.rpm == rpm {
+			return current
+		}
+
+		// RPM 配置变化时替换，并发替换时以先成功的为准
+		if channelRPMLimiters.CompareAndSwap(channelId, current, limiter) {
+			return limiter
+		}
+	}
+}
+
+func newChannelRPMLimiter(rpm int) *channelRPMLimiter {
+	return &channelRPMLimiter{
+		rpm:     rpm,
+		limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(rpm)), 1),
+	}
+}
+
+// acquire 没有排队的请求且令牌桶有余量时直接放行，否则按优先级排队
+func (l *channelRPMLimiter) acquire(priority int) (*rpmWaiter, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.waiters) == 0 && l.limiter.Allow() {
+		return nil, true
+	}
+
+	waiter := &rpmWaiter{
+		priority: priority,
+		enqueued: time.Now(),
+		ready:    make(chan struct{}),
+	}
+	l.waiters = append(l.waiters, waiter)
+
+	if !l.running {
+		l.running = true
+		go l.dispatch()
+	}
+
+	return waiter, false
+}
+
+// cancel 等待超时时移出队列，若已被放行则返回 false
+func (l *channelRPMLimiter) cancel(waiter *rpmWaiter) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if waiter.dispatched {
+		return false
+	}
+
+	for i, item := range l.waiters {
+		if item == waiter {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// dispatch 按 RPM 速率依次放行队列中有效优先级最高的请求，同优先级先到先得
+func (l *channelRPMLimiter) dispatch() {
+	for {
+		l.limiter.Wait(context.Background())
+
+		l.Lock()
+		if len(l.waiters) == 0 {
+			l.running = false
+			l.Unlock()
+			return
+		}
+
+		now := time.Now()
+		next := 0
+		for i, waiter := range l.waiters {
+			current := l.waiters[next]
+			if waiter.effectivePriority(now) > current.effectivePriority(now) {
+				next = i
+			}
+		}
+
+		waiter := l.waiters[next]
+		l.waiters = append(l.waiters[:next], l.waiters[next+1:]...)
+		waiter.dispatched = true
+		close(waiter.ready)
+		l.Unlock()
+	}
+}
+
+// ChannelRPMLimitedCode 渠道排队等待超时的错误码，属于本地限流，不应冻结或禁用渠道
+const ChannelRPMLimitedCode = "channel_rpm_limited"
+
+// WaitChannelRPM 按渠道配置的 RPM 平滑发送请求，超出速率时按优先级排队等待，等待超时返回 429 以便换渠道重试
+func WaitChannelRPM(ctx context.Context, channel *model.Channel, priority int) *types.OpenAIErrorWithStatusCode {
+	if channel == nil || channel.RPM <= 0 {
+		return nil
+	}
+
+	limiter := getChannelRPMLimiter(channel.Id, channel.RPM)
+	waiter, ok := limiter.acquire(priority)
+	if ok {
+		return nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(config.ChannelRPMMaxWaitSeconds)*time.Second)
+	defer cancel()
+
+	startTime := time.Now()
+	defer func() {
+		metrics.RecordChannelQueueWait(channel.Id, time.Since(startTime))
+	}()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-waitCtx.Done():
+		if !limiter.cancel(waiter) {
+			return nil
+		}
+		return common.StringErrorWrapper(fmt.Sprintf("channel #%d exceeds the rpm limit of %d", channel.Id, channel.RPM), ChannelRPMLimitedCode, http.StatusTooManyRequests)
+	}
+}
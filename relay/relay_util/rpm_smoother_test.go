@@ -0,0 +1,175 @@
+package relay_util
+
+import (
+	"context"
+	"net/http"
+	"one-api/common/config"
+	"one-api/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitChannelRPMPacesDispatch(t *testing.T) {
+	// 1200 RPM 即每 50ms 放行一个请求
+	channel := &model.Channel{Id: 1001, RPM: 1200}
+
+	startTime := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, WaitChannelRPM(context.Background(), channel, PriorityNormal))
+	}
+
+	assert.GreaterOrEqual(t, time.Since(startTime), 190*time.Millisecond)
+}
+
+func TestGetChannelRPMLimiterConcurrent(t *testing.T) {
+	// 并发的首个请求共用同一个令牌桶
+	limiters := make([]*channelRPMLimiter, 20)
+	var wg sync.WaitGroup
+	for i := range limiters {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			limiters[i] = getChannelRPMLimiter(1007, 60)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, limiter := range limiters {
+		assert.Same(t, limiters[0], limiter)
+	}
+
+	// RPM 变化时替换为新的令牌桶
+	limiter := getChannelRPMLimiter(1007, 120)
+	assert.NotSame(t, limiters[0], limiter)
+	assert.Equal(t, 120, limiter.rpm)
+	assert.Same(t, limiter, getChannelRPMLimiter(1007, 120))
+}
+
+func TestWaitChannelRPMQueuesConcurrentRequests(t *testing.T) {
+	channel := &model.Channel{Id: 1002, RPM: 1200}
+
+	var wg sync.WaitGroup
+	dispatched := make(chan time.Time, 4)
+	startTime := time.Now()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, WaitChannelRPM(context.Background(), channel, PriorityNormal))
+			dispatched <- time.Now()
+		}()
+	}
+	wg.Wait()
+	close(dispatched)
+
+	// 请求排队后依次发送，而不是一次性突发
+	var last time.Time
+	for dispatchTime := range dispatched {
+		if dispatchTime.After(last) {
+			last = dispatchTime
+		}
+	}
+	assert.GreaterOrEqual(t, last.Sub(startTime), 140*time.Millisecond)
+}
+
+func TestWaitChannelRPMTimeout(t *testing.T) {
+	config.ChannelRPMMaxWaitSeconds = 1
+	defer func() { config.ChannelRPMMaxWaitSeconds = 10 }()
+
+	channel := &model.Channel{Id: 1003, RPM: 1}
+
+	assert.Nil(t, WaitChannelRPM(context.Background(), channel, PriorityNormal))
+	err := WaitChannelRPM(context.Background(), channel, PriorityNormal)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, err.StatusCode)
+	assert.Equal(t, "channel_rpm_limited", err.Code)
+}
+
+func TestWaitChannelRPMDisabled(t *testing.T) {
+	channel := &model.Channel{Id: 1004}
+
+	startTime := time.Now()
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, WaitChannelRPM(context.Background(), channel, PriorityNormal))
+	}
+	assert.Less(t, time.Since(startTime), 50*time.Millisecond)
+}
+
+func TestWaitChannelRPMPriorityOrder(t *testing.T) {
+	// 600 RPM 即每 100ms 放行一个请求
+	channel := &model.Channel{Id: 1005, RPM: 600}
+	assert.Nil(t, WaitChannelRPM(context.Background(), channel, PriorityNormal))
+
+	order := make(chan int, 3)
+	var wg sync.WaitGroup
+	for _, priority := range []int{PriorityLow, PriorityNormal, PriorityHigh} {
+		wg.Add(1)
+		go func(priority int) {
+			defer wg.Done()
+			assert.Nil(t, WaitChannelRPM(context.Background(), channel, priority))
+			order <- priority
+		}(priority)
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+	close(order)
+
+	dispatched := make([]int, 0, 3)
+	for priority := range order {
+		dispatched = append(dispatched, priority)
+	}
+	assert.Equal(t, []int{PriorityHigh, PriorityNormal, PriorityLow}, dispatched)
+}
+
+func TestWaitChannelRPMPriorityAging(t *testing.T) {
+	config.RequestPriorityAgingSeconds = 1
+	defer func() { config.RequestPriorityAgingSeconds = 5 }()
+
+	waiter := &rpmWaiter{priority: PriorityLow, enqueued: time.Now().Add(-2 * time.Second)}
+	assert.Equal(t, PriorityHigh, waiter.effectivePriority(time.Now()))
+
+	// 老化周期超过最长等待时间的均分时按均分计算，低优先级请求在超时前能提升到最高优先级
+	config.RequestPriorityAgingSeconds = 5
+	assert.Equal(t, time.Duration(config.ChannelRPMMaxWaitSeconds)*time.Second/3, agingPeriod())
+	waiter = &rpmWaiter{priority: PriorityLow, enqueued: time.Now().Add(-time.Duration(config.ChannelRPMMaxWaitSeconds) * time.Second * 7 / 10)}
+	assert.Equal(t, PriorityHigh, waiter.effectivePriority(time.Now()))
+	config.RequestPriorityAgingSeconds = 1
+
+	// 高优先级请求持续到达时，低优先级请求仍能在老化后发送
+	channel := &model.Channel{Id: 1006, RPM: 1200}
+	assert.Nil(t, WaitChannelRPM(context.Background(), channel, PriorityHigh))
+
+	lowDone := make(chan struct{})
+	go func() {
+		assert.Nil(t, WaitChannelRPM(context.Background(), channel, PriorityLow))
+		close(lowDone)
+	}()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					WaitChannelRPM(context.Background(), channel, PriorityHigh)
+				}
+			}
+		}()
+	}
+
+	select {
+	case <-lowDone:
+	case <-time.After(5 * time.Second):
+		t.Error("low priority request was starved")
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,207 @@
+package relay_util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"one-api/common"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/types"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamCostCeilingHeader 特权令牌指定单次流式请求的费用上限（额度），只能收紧令牌配置的上限
+const StreamCostCeilingHeader = "X-Max-Stream-Cost"
+
+// GetStreamCostCeiling 获取流式请求的费用上限，令牌配置与请求头同时存在时取较小值，0 为不限制
+// 上限会降低结算费用，请求头只对特权令牌生效
+func GetStreamCostCeiling(c *gin.Context) int {
+	tokenSetting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting")
+	if !ok || tokenSetting == nil {
+		return 0
+	}
+
+	ceiling := tokenSetting.MaxStreamCost
+	if ceiling < 0 {
+		ceiling = 0
+	}
+
+	if header := c.Request.Header.Get(StreamCostCeilingHeader); header != "" && tokenSetting.Privileged {
+		if value, err := strconv.Atoi(header); err == nil && value > 0 && (ceiling == 0 || value < ceiling) {
+			ceiling = value
+		}
+	}
+
+	return ceiling
+}
+
+// SetCostCeiling 流式输出因费用上限中止后设置，结算时补全部分的费用不超过该上限
+func (q *Quota) SetCostCeiling(ceiling int) {
+	q.costCeiling = ceiling
+}
+
+// estimateStreamQuota 按当前已输出的补全 token 估算费用
+func (q *Quota) estimateStreamQuota(completionTokens int) int {
+	if q.price.Type == model.TimesPriceType {
+		return int(1000 * q.inputRatio)
+	}
+
+	return int(math.Ceil(float64(q.promptTokens)*q.inputRatio + float64(completionTokens)*q.outputRatio))
+}
+
+// StreamCostLimit 流式输出累计的费用将超过上限时，不再输出后续内容，补发 finish_reason 为 length 的结束块
+// 只有经过 Wrap 包装的输出流才会中止，未包装时不影响结算
+type StreamCostLimit struct {
+	ctx       context.Context
+	quota     *Quota
+	modelName string
+	ceiling   int
+
+	completionTokens int
+	lastChunk        *types.ChatCompletionStreamResponse
+	limited          bool
+}
+
+func NewStreamCostLimit(ctx context.Context, quota *Quota, modelName string, ceiling int) *StreamCostLimit {
+	return &StreamCostLimit{
+		ctx:       ctx,
+		quota:     quota,
+		modelName: modelName,
+		ceiling:   ceiling,
+	}
+}
+
+// ApplyUsage 因超出费用上限中止时，按已输出给客户端的内容记录补全用量，需在流式输出结束后调用
+func (l *StreamCostLimit) ApplyUsage(usage *types.Usage) bool {
+	if !l.limited {
+		return false
+	}
+
+	usage.CompletionTokens = l.completionTokens
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	usage.Estimated = true
+	return true
+}
+
+// Wrap 包装 OpenAI 格式的流式响应
+func (l *StreamCostLimit) Wrap(stream requester.StreamReaderInterface[string]) requester.StreamReaderInterface[string] {
+	return &costLimitStream{stream: stream, limit: l}
+}
+
+type costLimitStream struct {
+	stream requester.StreamReaderInterface[string]
+	limit  *StreamCostLimit
+}
+
+func (s *costLimitStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+	sourceData, sourceErr := s.stream.Recv()
+
+	go func() {
+		for {
+			select {
+			case data, ok := <-sourceData:
+				if !ok {
+					close(dataChan)
+					return
+				}
+				if !s.limit.allow(data) {
+					dataChan <- s.limit.finishChunk()
+					s.stream.Close()
+					go drainStream(sourceData, sourceErr)
+					errChan <- io.EOF
+					return
+				}
+				dataChan <- data
+			case err := <-sourceErr:
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *costLimitStream) Close() {
+	s.stream.Close()
+}
+
+// allow 计算数据块输出后的累计费用，超过上限时返回 false
+func (l *StreamCostLimit) allow(data string) bool {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return true
+	}
+	l.lastChunk = &chunk
+
+	tokens := common.CountTokenText(chunkText(&chunk), l.modelName)
+	if l.quota.estimateStreamQuota(l.completionTokens+tokens) <= l.ceiling {
+		l.completionTokens += tokens
+		return true
+	}
+
+	l.limited = true
+	l.quota.SetCostCeiling(l.ceiling)
+	logger.LogWarn(l.ctx, fmt.Sprintf("stream aborted at %d completion tokens, cost ceiling %d reached", l.completionTokens, l.ceiling))
+
+	return false
+}
+
+func (l *StreamCostLimit) finishChunk() string {
+	finishReason := types.FinishReasonLength
+	chunk := types.ChatCompletionStreamResponse{
+		ID:      fmt.Sprintf("chatcmpl-%s", utils.GetUUID()),
+		Object:  "chat.completion.chunk",
+		Created: utils.GetTimestamp(),
+		Model:   l.modelName,
+		Choices: []types.ChatCompletionStreamChoice{{Index: 0, FinishReason: &finishReason}},
+	}
+	if l.lastChunk != nil {
+		chunk.ID, chunk.Created, chunk.Model = l.lastChunk.ID, l.lastChunk.Created, l.lastChunk.Model
+	}
+
+	responseBody, _ := json.Marshal(chunk)
+	return string(responseBody)
+}
+
+// chunkText 数据块中需要计费的输出内容
+func chunkText(chunk *types.ChatCompletionStreamResponse) string {
+	var text strings.Builder
+	for _, choice := range chunk.Choices {
+		text.WriteString(choice.Delta.Content)
+		text.WriteString(choice.Delta.ReasoningContent)
+		text.WriteString(choice.Delta.Reasoning)
+		for _, toolCall := range choice.Delta.ToolCalls {
+			if toolCall.Function != nil {
+				text.WriteString(toolCall.Function.Name)
+				text.WriteString(toolCall.Function.Arguments)
+			}
+		}
+	}
+
+	return text.String()
+}
+
+// drainStream 中止后继续读取上游剩余的数据，避免上游处理协程阻塞
+func drainStream(dataChan <-chan string, errChan <-chan error) {
+	for {
+		select {
+		case _, ok := <-dataChan:
+			if !ok {
+				return
+			}
+		case <-errChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package relay_util
+
+import (
+	"context"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closeTrackingStream struct {
+	fixtureStream
+	closed bool
+}
+
+func (s *closeTrackingStream) Close() {
+	s.closed = true
+}
+
+func TestStreamCostLimitAbort(t *testing.T) {
+	config.ApproximateTokenEnabled = true
+	defer func() { config.ApproximateTokenEnabled = false }()
+
+	chunk := `{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"hello"},"finish_reason":null}]}`
+	source := &closeTrackingStream{fixtureStream: fixtureStream{data: []string{chunk, chunk, chunk, chunk, chunk, chunk}}}
+
+	chunkTokens := common.CountTokenText("hello", "gpt-4o")
+	quota := &Quota{
+		promptTokens: 10,
+		price:        model.Price{Type: model.TokensPriceType, Input: 1, Output: 2},
+		groupRatio:   1,
+		inputRatio:   1,
+		outputRatio:  2,
+	}
+	// 只够输出三个数据块
+	ceiling := 10 + 3*chunkTokens*2 + 1
+
+	costLimit := NewStreamCostLimit(context.Background(), quota, "gpt-4o", ceiling)
+	chunks := readStream(t, costLimit.Wrap(source))
+
+	assert.Len(t, chunks, 4)
+	for _, chunk := range chunks[:3] {
+		assert.Equal(t, "hello", chunk.Choices[0].Delta.Content)
+		assert.Nil(t, chunk.Choices[0].FinishReason)
+	}
+	finalChunk := chunks[3]
+	assert.Equal(t, "chatcmpl-1", finalChunk.ID)
+	assert.Equal(t, "gpt-4o", finalChunk.Model)
+	assert.Equal(t, types.FinishReasonLength, finalChunk.Choices[0].FinishReason)
+	assert.True(t, source.closed)
+
+	// 按已输出的内容计费，不超过上限
+	usage := &types.Usage{PromptTokens: 10, CompletionTokens: 100}
+	assert.True(t, costLimit.ApplyUsage(usage))
+	assert.Equal(t, 3*chunkTokens, usage.CompletionTokens)
+	assert.Equal(t, 10+3*chunkTokens, usage.TotalTokens)
+	assert.True(t, usage.Estimated)
+	assert.Equal(t, 10+3*chunkTokens*2, quota.GetTotalQuotaByUsage(usage))
+	assert.Equal(t, ceiling, quota.GetLogMeta(usage)["cost_ceiling"])
+
+	// 上游报告的用量超出上限时按上限结算
+	assert.Equal(t, ceiling, quota.GetTotalQuotaByUsage(&types.Usage{PromptTokens: 10, CompletionTokens: 100}))
+}
+
+func TestStreamCostLimitWithinCeiling(t *testing.T) {
+	config.ApproximateTokenEnabled = true
+	defer func() { config.ApproximateTokenEnabled = false }()
+
+	source := &closeTrackingStream{fixtureStream: fixtureStream{data: multiToolStreamFixture}}
+	quota := &Quota{
+		price:       model.Price{Type: model.TokensPriceType, Input: 1, Output: 1},
+		groupRatio:  1,
+		inputRatio:  1,
+		outputRatio: 1,
+	}
+	costLimit := NewStreamCostLimit(context.Background(), quota, "gpt-4o", 10000)
+	chunks := readStream(t, costLimit.Wrap(source))
+
+	assert.Len(t, chunks, len(multiToolStreamFixture))
+	assert.False(t, source.closed)
+
+	usage := &types.Usage{PromptTokens: 10, CompletionTokens: 20}
+	assert.False(t, costLimit.ApplyUsage(usage))
+	assert.Equal(t, 20, usage.CompletionTokens)
+
+	// 未中止时不按上限结算
+	assert.Equal(t, 20010, quota.GetTotalQuotaByUsage(&types.Usage{PromptTokens: 10, CompletionTokens: 20000}))
+}
+
+func TestStreamCostLimitPromptExceedsCeiling(t *testing.T) {
+	config.ApproximateTokenEnabled = true
+	defer func() { config.ApproximateTokenEnabled = false }()
+
+	chunk := `{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"hello"},"finish_reason":null}]}`
+	source := &closeTrackingStream{fixtureStream: fixtureStream{data: []string{chunk, chunk}}}
+	quota := &Quota{
+		promptTokens: 100,
+		price:        model.Price{Type: model.TokensPriceType, Input: 1, Output: 2},
+		groupRatio:   1,
+		inputRatio:   1,
+		outputRatio:  2,
+	}
+
+	// 提示的费用已超过上限，第一个数据块前中止
+	costLimit := NewStreamCostLimit(context.Background(), quota, "gpt-4o", 50)
+	chunks := readStream(t, costLimit.Wrap(source))
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, types.FinishReasonLength, chunks[0].Choices[0].FinishReason)
+
+	usage := &types.Usage{PromptTokens: 100, CompletionTokens: 30}
+	assert.True(t, costLimit.ApplyUsage(usage))
+	assert.Equal(t, 0, usage.CompletionTokens)
+	// 提示部分照常计费，不受上限影响
+	assert.Equal(t, 100, quota.GetTotalQuotaByUsage(usage))
+	assert.Equal(t, 100, quota.GetTotalQuotaByUsage(&types.Usage{PromptTokens: 100, CompletionTokens: 30}))
+}
+
+func TestGetStreamCostCeiling(t *testing.T) {
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	assert.Equal(t, 0, GetStreamCostCeiling(c))
+
+	// 非特权令牌的请求头不生效
+	c.Request.Header.Set(StreamCostCeilingHeader, "500")
+	assert.Equal(t, 0, GetStreamCostCeiling(c))
+	c.Set("token_setting", &model.TokenSetting{MaxStreamCost: 300})
+	assert.Equal(t, 300, GetStreamCostCeiling(c))
+	c.Set("token_setting", &model.TokenSetting{})
+	assert.Equal(t, 0, GetStreamCostCeiling(c))
+
+	c.Set("token_setting", &model.TokenSetting{Privileged: true})
+	assert.Equal(t, 500, GetStreamCostCeiling(c))
+
+	// 请求头只能收紧令牌配置的上限
+	c.Set("token_setting", &model.TokenSetting{MaxStreamCost: 300, Privileged: true})
+	assert.Equal(t, 300, GetStreamCostCeiling(c))
+	c.Request.Header.Set(StreamCostCeilingHeader, "200")
+	assert.Equal(t, 200, GetStreamCostCeiling(c))
+
+	c.Request.Header.Set(StreamCostCeilingHeader, "invalid")
+	assert.Equal(t, 300, GetStreamCostCeiling(c))
+}
@@ -0,0 +1,102 @@
+package relay_util
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/types"
+	"sync"
+)
+
+// 用户和令牌当前的流式请求数，按模型并发分类分别统计，仅统计本实例
+var streamLimiter = &streamCounter{
+	users:  make(map[streamSlotKey]int),
+	tokens: make(map[streamSlotKey]int),
+}
+
+type streamCounter struct {
+	sync.Mutex
+	users  map[streamSlotKey]int
+	tokens map[streamSlotKey]int
+}
+
+// streamSlotKey class 为空表示未命中任何分类的普通模型
+type streamSlotKey struct {
+	class string
+	id    int
+}
+
+type StreamingStatistics struct {
+	Total  int         `json:"total"`
+	Users  map[int]int `json:"users"`
+	Tokens map[int]int `json:"tokens"`
+}
+
+// AcquireStreamSlot 占用一个流式请求名额，超出用户或令牌的并发上限时返回 429，成功时需调用 release 释放
+// 模型命中并发分类时使用该分类独立的名额和上限，否则使用全局上限
+func AcquireStreamSlot(userId, tokenId int, modelName string) (release func(), errWithCode *types.OpenAIErrorWithStatusCode) {
+	perUser, perToken := config.StreamConcurrencyPerUser, config.StreamConcurrencyPerToken
+	className, class := config.ConcurrencyClassSettingsInstance.GetClass(modelName)
+	scope := ""
+	if className != "" {
+		perUser, perToken = class.PerUser, class.PerToken
+		scope = fmt.Sprintf(" (%s models)", className)
+	}
+	userKey := streamSlotKey{class: className, id: userId}
+	tokenKey := streamSlotKey{class: className, id: tokenId}
+
+	streamLimiter.Lock()
+	defer streamLimiter.Unlock()
+
+	if perUser > 0 && streamLimiter.users[userKey] >= perUser {
+		return nil, common.StringErrorWrapperLocal("too many concurrent streaming requests for this user"+scope, "stream_concurrency_limited", http.StatusTooManyRequests)
+	}
+
+	if perToken > 0 && streamLimiter.tokens[tokenKey] >= perToken {
+		return nil, common.StringErrorWrapperLocal("too many concurrent streaming requests for this token"+scope, "stream_concurrency_limited", http.StatusTooManyRequests)
+	}
+
+	streamLimiter.users[userKey]++
+	streamLimiter.tokens[tokenKey]++
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			streamLimiter.Lock()
+			defer streamLimiter.Unlock()
+
+			decreaseCount(streamLimiter.users, userKey)
+			decreaseCount(streamLimiter.tokens, tokenKey)
+		})
+	}
+
+	return release, nil
+}
+
+func decreaseCount(counts map[streamSlotKey]int, key streamSlotKey) {
+	counts[key]--
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// GetStreamingStatistics 获取当前的流式请求数，各分类的请求数合并统计
+func GetStreamingStatistics() *StreamingStatistics {
+	streamLimiter.Lock()
+	defer streamLimiter.Unlock()
+
+	statistics := &StreamingStatistics{
+		Users:  make(map[int]int, len(streamLimiter.users)),
+		Tokens: make(map[int]int, len(streamLimiter.tokens)),
+	}
+	for key, count := range streamLimiter.users {
+		statistics.Users[key.id] += count
+		statistics.Total += count
+	}
+	for key, count := range streamLimiter.tokens {
+		statistics.Tokens[key.id] += count
+	}
+
+	return statistics
+}
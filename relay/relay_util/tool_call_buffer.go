@@ -0,0 +1,187 @@
+package relay_util
+
+import (
+	"encoding/json"
+	"one-api/common/requester"
+	"one-api/types"
+	"sort"
+)
+
+// ToolCallBufferStream 缓冲流式响应中的工具调用参数，在 finish_reason 出现时一次性输出完整的 tool_calls
+type ToolCallBufferStream struct {
+	stream requester.StreamReaderInterface[string]
+
+	buffers   map[int][]*types.ChatCompletionToolCalls
+	lastChunk *types.ChatCompletionStreamResponse
+}
+
+func NewToolCallBufferStream(stream requester.StreamReaderInterface[string]) *ToolCallBufferStream {
+	return &ToolCallBufferStream{
+		stream:  stream,
+		buffers: make(map[int][]*types.ChatCompletionToolCalls),
+	}
+}
+
+func (s *ToolCallBufferStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+	sourceData, sourceErr := s.stream.Recv()
+
+	go func() {
+		for {
+			select {
+			case data, ok := <-sourceData:
+				if !ok {
+					close(dataChan)
+					return
+				}
+				if chunk, ok := s.handle(data); ok {
+					dataChan <- chunk
+				}
+			case err := <-sourceErr:
+				// 上游结束时仍有未输出的工具调用，补发一个包含完整工具调用的块
+				if chunk := s.flushAll(); chunk != "" {
+					dataChan <- chunk
+				}
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *ToolCallBufferStream) Close() {
+	s.stream.Close()
+}
+
+// handle 处理单个数据块，返回需要输出的内容；不含工具调用的块原样输出
+func (s *ToolCallBufferStream) handle(data string) (string, bool) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return data, true
+	}
+
+	hasToolCalls := false
+	for _, choice := range chunk.Choices {
+		if len(choice.Delta.ToolCalls) > 0 {
+			hasToolCalls = true
+			break
+		}
+	}
+	if !hasToolCalls && (len(s.buffers) == 0 || !hasFinishReason(&chunk)) {
+		return data, true
+	}
+
+	s.lastChunk = &chunk
+	emit := chunk.Usage != nil
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		for _, toolCall := range choice.Delta.ToolCalls {
+			s.appendToolCall(choice.Index, toolCall)
+		}
+		choice.Delta.ToolCalls = nil
+
+		if choice.FinishReason != nil {
+			choice.Delta.ToolCalls = s.buffers[choice.Index]
+			delete(s.buffers, choice.Index)
+		}
+
+		if choice.FinishReason != nil || !isEmptyDelta(&choice.Delta) {
+			emit = true
+		}
+	}
+
+	if !emit {
+		return "", false
+	}
+
+	response, err := json.Marshal(chunk)
+	if err != nil {
+		return data, true
+	}
+
+	return string(response), true
+}
+
+func (s *ToolCallBufferStream) appendToolCall(choiceIndex int, toolCall *types.ChatCompletionToolCalls) {
+	if toolCall == nil {
+		return
+	}
+
+	for _, buffered := range s.buffers[choiceIndex] {
+		if buffered.Index != toolCall.Index {
+			continue
+		}
+		if toolCall.Id != "" {
+			buffered.Id = toolCall.Id
+		}
+		if toolCall.Type != "" {
+			buffered.Type = toolCall.Type
+		}
+		if toolCall.Function != nil {
+			if toolCall.Function.Name != "" {
+				buffered.Function.Name = toolCall.Function.Name
+			}
+			buffered.Function.Arguments += toolCall.Function.Arguments
+		}
+		return
+	}
+
+	buffered := &types.ChatCompletionToolCalls{
+		Id:       toolCall.Id,
+		Type:     toolCall.Type,
+		Index:    toolCall.Index,
+		Function: &types.ChatCompletionToolCallsFunction{},
+	}
+	if toolCall.Function != nil {
+		buffered.Function.Name = toolCall.Function.Name
+		buffered.Function.Arguments = toolCall.Function.Arguments
+	}
+	s.buffers[choiceIndex] = append(s.buffers[choiceIndex], buffered)
+}
+
+func (s *ToolCallBufferStream) flushAll() string {
+	if len(s.buffers) == 0 || s.lastChunk == nil {
+		return ""
+	}
+
+	chunk := *s.lastChunk
+	chunk.Usage = nil
+	chunk.Choices = make([]types.ChatCompletionStreamChoice, 0, len(s.buffers))
+	for index, toolCalls := range s.buffers {
+		chunk.Choices = append(chunk.Choices, types.ChatCompletionStreamChoice{
+			Index: index,
+			Delta: types.ChatCompletionStreamChoiceDelta{
+				Role:      types.ChatMessageRoleAssistant,
+				ToolCalls: toolCalls,
+			},
+		})
+	}
+	sort.Slice(chunk.Choices, func(i, j int) bool {
+		return chunk.Choices[i].Index < chunk.Choices[j].Index
+	})
+	s.buffers = make(map[int][]*types.ChatCompletionToolCalls)
+
+	response, err := json.Marshal(chunk)
+	if err != nil {
+		return ""
+	}
+
+	return string(response)
+}
+
+func isEmptyDelta(delta *types.ChatCompletionStreamChoiceDelta) bool {
+	return delta.Content == "" && delta.Role == "" && delta.FunctionCall == nil && len(delta.ToolCalls) == 0 &&
+		delta.ReasoningContent == "" && delta.Reasoning == "" && len(delta.Image) == 0 && len(delta.Images) == 0
+}
+
+func hasFinishReason(chunk *types.ChatCompletionStreamResponse) bool {
+	for _, choice := range chunk.Choices {
+		if choice.FinishReason != nil {
+			return true
+		}
+	}
+	return false
+}
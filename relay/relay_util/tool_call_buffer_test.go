@@ -0,0 +1,101 @@
+package relay_util
+
+import (
+	"encoding/json"
+	"io"
+	"one-api/common/requester"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 两个工具调用交替输出参数的流式响应
+var multiToolStreamFixture = []string{
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"checking"},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{\"tz\":"}}]},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"CET\"}"}}]},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+}
+
+type fixtureStream struct {
+	data []string
+}
+
+func (s *fixtureStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+	go func() {
+		for _, item := range s.data {
+			dataChan <- item
+		}
+		errChan <- io.EOF
+	}()
+	return dataChan, errChan
+}
+
+func (s *fixtureStream) Close() {}
+
+func readStream(t *testing.T, stream requester.StreamReaderInterface[string]) []types.ChatCompletionStreamResponse {
+	dataChan, errChan := stream.Recv()
+	chunks := make([]types.ChatCompletionStreamResponse, 0)
+	for {
+		select {
+		case data := <-dataChan:
+			var chunk types.ChatCompletionStreamResponse
+			assert.Nil(t, json.Unmarshal([]byte(data), &chunk))
+			chunks = append(chunks, chunk)
+		case err := <-errChan:
+			assert.Equal(t, io.EOF, err)
+			return chunks
+		}
+	}
+}
+
+func countToolCallChunks(chunks []types.ChatCompletionStreamResponse) int {
+	count := 0
+	for _, chunk := range chunks {
+		if len(chunk.Choices) > 0 && len(chunk.Choices[0].Delta.ToolCalls) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func TestToolCallIncrementalEmit(t *testing.T) {
+	chunks := readStream(t, &fixtureStream{data: multiToolStreamFixture})
+
+	assert.Len(t, chunks, len(multiToolStreamFixture))
+	assert.Equal(t, 5, countToolCallChunks(chunks))
+}
+
+func TestToolCallBufferedEmit(t *testing.T) {
+	chunks := readStream(t, NewToolCallBufferStream(&fixtureStream{data: multiToolStreamFixture}))
+
+	// 文本块原样输出，工具调用合并到带 finish_reason 的块中
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "checking", chunks[0].Choices[0].Delta.Content)
+	assert.Equal(t, 1, countToolCallChunks(chunks))
+
+	choice := chunks[1].Choices[0]
+	assert.Equal(t, types.FinishReasonToolCalls, choice.FinishReason)
+	assert.Len(t, choice.Delta.ToolCalls, 2)
+	assert.Equal(t, "call_1", choice.Delta.ToolCalls[0].Id)
+	assert.Equal(t, "get_weather", choice.Delta.ToolCalls[0].Function.Name)
+	assert.Equal(t, `{"city":"Paris"}`, choice.Delta.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "call_2", choice.Delta.ToolCalls[1].Id)
+	assert.Equal(t, `{"tz":"CET"}`, choice.Delta.ToolCalls[1].Function.Arguments)
+}
+
+func TestToolCallBufferedFlushOnEOF(t *testing.T) {
+	// 上游未返回 finish_reason 时，结束前补发完整的工具调用
+	data := multiToolStreamFixture[:len(multiToolStreamFixture)-1]
+	chunks := readStream(t, NewToolCallBufferStream(&fixtureStream{data: data}))
+
+	assert.Len(t, chunks, 2)
+	assert.Len(t, chunks[1].Choices[0].Delta.ToolCalls, 2)
+	assert.Equal(t, `{"city":"Paris"}`, chunks[1].Choices[0].Delta.ToolCalls[0].Function.Arguments)
+}
@@ -0,0 +1,128 @@
+package relay_util
+
+import (
+	"encoding/json"
+	"one-api/common/requester"
+	"one-api/types"
+	"strings"
+)
+
+// WatermarkStream 在流式响应的第一段文本后追加水印，之后的数据块原样输出
+type WatermarkStream struct {
+	stream    requester.StreamReaderInterface[string]
+	watermark string
+	inject    func(data, watermark string) (string, bool)
+	injected  bool
+}
+
+// NewWatermarkStream 处理 OpenAI 格式的流式响应
+func NewWatermarkStream(stream requester.StreamReaderInterface[string], watermark string) *WatermarkStream {
+	return &WatermarkStream{
+		stream:    stream,
+		watermark: watermark,
+		inject:    injectChatChunk,
+	}
+}
+
+// NewClaudeWatermarkStream 处理 Claude 原生格式的流式响应，数据为上游的原始行
+func NewClaudeWatermarkStream(stream requester.StreamReaderInterface[string], watermark string) *WatermarkStream {
+	return &WatermarkStream{
+		stream:    stream,
+		watermark: watermark,
+		inject:    injectClaudeLine,
+	}
+}
+
+func (s *WatermarkStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+	sourceData, sourceErr := s.stream.Recv()
+
+	go func() {
+		for {
+			select {
+			case data, ok := <-sourceData:
+				if !ok {
+					close(dataChan)
+					return
+				}
+				if !s.injected {
+					data, s.injected = s.inject(data, s.watermark)
+				}
+				dataChan <- data
+			case err := <-sourceErr:
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *WatermarkStream) Close() {
+	s.stream.Close()
+}
+
+// WatermarkChatResponse 在非流式响应的文本内容末尾追加水印
+func WatermarkChatResponse(response *types.ChatCompletionResponse, watermark string) {
+	for i := range response.Choices {
+		message := &response.Choices[i].Message
+		if content, ok := message.Content.(string); ok && content != "" {
+			message.Content = content + watermark
+			return
+		}
+	}
+}
+
+func injectChatChunk(data, watermark string) (string, bool) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return data, false
+	}
+
+	for i := range chunk.Choices {
+		if chunk.Choices[i].Delta.Content == "" {
+			continue
+		}
+
+		chunk.Choices[i].Delta.Content += watermark
+		responseBody, err := json.Marshal(chunk)
+		if err != nil {
+			return data, false
+		}
+		return string(responseBody), true
+	}
+
+	return data, false
+}
+
+// injectClaudeLine 只修改 text_delta 事件的 data 行，保留行尾的换行符
+func injectClaudeLine(data, watermark string) (string, bool) {
+	line := strings.TrimRight(data, "\r\n")
+	if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, `"text_delta"`) {
+		return data, false
+	}
+
+	var event map[string]any
+	if err := json.Unmarshal([]byte(line[6:]), &event); err != nil {
+		return data, false
+	}
+
+	delta, ok := event["delta"].(map[string]any)
+	if !ok || delta["type"] != "text_delta" {
+		return data, false
+	}
+	text, ok := delta["text"].(string)
+	if !ok || text == "" {
+		return data, false
+	}
+
+	delta["text"] = text + watermark
+	eventBody, err := json.Marshal(event)
+	if err != nil {
+		return data, false
+	}
+
+	return "data: " + string(eventBody) + data[len(line):], true
+}
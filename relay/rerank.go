@@ -6,6 +6,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/retry"
 	providersBase "one-api/providers/base"
 	"one-api/types"
 
@@ -42,6 +43,9 @@ func RelayRerank(c *gin.Context) {
 	for i := retryTimes; i > 0; i-- {
 		// 冻结通道
 		shouldCooldowns(c, channel, apiErr)
+		if !retry.Acquire(c) {
+			break
+		}
 		if err := relay.setProvider(relay.getOriginalModel()); err != nil {
 			continue
 		}
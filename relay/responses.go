@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/requester"
 	providersBase "one-api/providers/base"
@@ -67,18 +68,24 @@ func (r *relayResponses) send() (err *types.OpenAIErrorWithStatusCode, done bool
 	}
 
 	if r.responsesRequest.Stream {
-		var response requester.StreamReaderInterface[string]
-		response, err = responsesProvider.CreateResponsesStream(&r.responsesRequest)
-		if err != nil {
-			return
-		}
+		for attempt := 0; ; attempt++ {
+			var response requester.StreamReaderInterface[string]
+			response, err = responsesProvider.CreateResponsesStream(&r.responsesRequest)
+			if err != nil {
+				return
+			}
 
-		doneStr := func() string {
-			return ""
-		}
+			doneStr := func() string {
+				return ""
+			}
 
-		firstResponseTime := responseGeneralStreamClient(r.c, response, doneStr)
-		r.SetFirstResponseTime(firstResponseTime)
+			var firstResponseTime time.Time
+			firstResponseTime, err = responseGeneralStreamClient(r.c, response, doneStr, r.HandleStreamError)
+			r.SetFirstResponseTime(firstResponseTime)
+			if !shouldRetryStreamReset(r.c, err, attempt) {
+				break
+			}
+		}
 	} else {
 		var response *types.OpenAIResponsesResponses
 		response, err = responsesProvider.CreateResponses(&r.responsesRequest)
@@ -106,13 +113,19 @@ func (r *relayResponses) compatibleSend(chatProvider providersBase.ChatInterface
 	}
 
 	if r.responsesRequest.Stream {
-		var response requester.StreamReaderInterface[string]
-		response, errWithCode = chatProvider.CreateChatCompletionStream(chatReq)
-		if errWithCode != nil {
-			return
+		for attempt := 0; ; attempt++ {
+			var response requester.StreamReaderInterface[string]
+			response, errWithCode = chatProvider.CreateChatCompletionStream(chatReq)
+			if errWithCode != nil {
+				return
+			}
+			var firstResponseTime time.Time
+			firstResponseTime, errWithCode = r.chatToResponseStreamClient(response)
+			r.SetFirstResponseTime(firstResponseTime)
+			if !shouldRetryStreamReset(r.c, errWithCode, attempt) {
+				break
+			}
 		}
-		firstResponseTime := r.chatToResponseStreamClient(response)
-		r.SetFirstResponseTime(firstResponseTime)
 	} else {
 		var response *types.ChatCompletionResponse
 		response, errWithCode = chatProvider.CreateChatCompletion(chatReq)
@@ -131,8 +144,8 @@ func (r *relayResponses) compatibleSend(chatProvider providersBase.ChatInterface
 	return
 }
 
-// 将chat转换成兼容的responses流处理
-func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReaderInterface[string]) (firstResponseTime time.Time) {
+// 将chat转换成兼容的responses流处理，首字节前被网络中断时返回 resetErr 交由上层重试
+func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReaderInterface[string]) (firstResponseTime time.Time, resetErr *types.OpenAIErrorWithStatusCode) {
 	requester.SetEventStreamHeaders(r.c)
 	dataChan, errChan := stream.Recv()
 
@@ -170,8 +183,15 @@ func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReade
 				}
 
 			case err := <-errChan:
+				if !isFirstResponse && config.StreamResetRetryTimes > 0 && requester.IsStreamResetError(err) {
+					// 尚未向客户端输出任何内容，交由上层重新发起请求
+					resetErr = streamResetError(err)
+					logger.LogError(r.c.Request.Context(), "Stream reset before first byte:"+err.Error())
+					return
+				}
+
 				if !errors.Is(err, io.EOF) {
-					// 处理错误情况
+					// 处理错误情况，在流中写入 error 事件
 					select {
 					case <-r.c.Request.Context().Done():
 						// 客户端已断开，不执行任何操作，直接跳过
@@ -192,5 +212,5 @@ func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReade
 
 	// 等待处理完成
 	<-done
-	return firstResponseTime
+	return firstResponseTime, resetErr
 }
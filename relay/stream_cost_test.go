@@ -0,0 +1,57 @@
+package relay
+
+import (
+	"net/http"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/relay/relay_util"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckStreamCostLimit(t *testing.T) {
+	newRelays := map[string]func(path, body string) RelayBaseInterface{
+		"chat": func(path, body string) RelayBaseInterface {
+			c, _ := test.GetContext("POST", path, test.RequestJSONConfig(), strings.NewReader(body))
+			c.Set("token_setting", &model.TokenSetting{MaxStreamCost: 100})
+			relay := NewRelayChat(c)
+			assert.Nil(t, relay.setRequest())
+			return relay
+		},
+		"claude": func(path, body string) RelayBaseInterface {
+			c, _ := test.GetContext("POST", path, test.RequestJSONConfig(), strings.NewReader(body))
+			c.Set("token_setting", &model.TokenSetting{MaxStreamCost: 100})
+			relay := NewRelayClaudeOnly(c)
+			assert.Nil(t, relay.setRequest())
+			return relay
+		},
+		"responses": func(path, body string) RelayBaseInterface {
+			c, _ := test.GetContext("POST", path, test.RequestJSONConfig(), strings.NewReader(body))
+			c.Set("token_setting", &model.TokenSetting{MaxStreamCost: 100})
+			relay := NewRelayResponses(c)
+			assert.Nil(t, relay.setRequest())
+			return relay
+		},
+	}
+	streamBody := map[string]string{
+		"chat":      `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+		"claude":    `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+		"responses": `{"model":"gpt-4o","stream":true,"input":"hi"}`,
+	}
+	paths := map[string]string{"chat": "/v1/chat/completions", "claude": "/v1/messages", "responses": "/v1/responses"}
+
+	// 只有 chat 会包装输出流，其他接口配置了上限的流式请求被拒绝，而不是静默忽略
+	assert.Nil(t, checkStreamCostLimit(newRelays["chat"](paths["chat"], streamBody["chat"])))
+	for _, name := range []string{"claude", "responses"} {
+		errWithCode := checkStreamCostLimit(newRelays[name](paths[name], streamBody[name]))
+		assert.NotNil(t, errWithCode, name)
+		assert.Equal(t, http.StatusBadRequest, errWithCode.StatusCode, name)
+		assert.Contains(t, errWithCode.Message, relay_util.StreamCostCeilingHeader, name)
+
+		// 非流式请求不受影响
+		body := strings.Replace(streamBody[name], `"stream":true`, `"stream":false`, 1)
+		assert.Nil(t, checkStreamCostLimit(newRelays[name](paths[name], body)), name)
+	}
+}
@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newUpstreamRequestIdProvider(t *testing.T, status int, body string) (*gin.Context, providersBase.ChatInterface, func()) {
+	requester.InitHttpClient()
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("request-id", "req_upstream_1")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+
+	channel := test.GetChannel(config.ChannelTypeAnthropic, ts.URL, "", "", "")
+	c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+	chatProvider := providers.GetProvider(&channel, c).(providersBase.ChatInterface)
+	chatProvider.SetUsage(&types.Usage{})
+
+	return c, chatProvider, ts.Close
+}
+
+var upstreamRequestIdChatRequest = &types.ChatCompletionRequest{
+	Model:     "claude-3-5-sonnet-20241022",
+	MaxTokens: 100,
+	Messages:  []types.ChatCompletionMessage{{Role: types.ChatMessageRoleUser, Content: "hi"}},
+}
+
+func TestUpstreamRequestIdSuccess(t *testing.T) {
+	config.UpstreamRequestIdHeaderEnabled = true
+	defer func() { config.UpstreamRequestIdHeaderEnabled = false }()
+
+	c, chatProvider, closeServer := newUpstreamRequestIdProvider(t, http.StatusOK, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":5}}`)
+	defer closeServer()
+
+	_, errWithCode := chatProvider.CreateChatCompletion(upstreamRequestIdChatRequest)
+	assert.Nil(t, errWithCode)
+	assert.Equal(t, "req_upstream_1", c.GetString(config.GinUpstreamRequestIdKey))
+	assert.Equal(t, "req_upstream_1", c.Writer.Header().Get(providersBase.UpstreamRequestIdHeader))
+}
+
+func TestUpstreamRequestIdError(t *testing.T) {
+	c, chatProvider, closeServer := newUpstreamRequestIdProvider(t, http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: too large"}}`)
+	defer closeServer()
+
+	_, errWithCode := chatProvider.CreateChatCompletion(upstreamRequestIdChatRequest)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, "req_upstream_1", errWithCode.RawUpstreamRequestId)
+	assert.Equal(t, "req_upstream_1", c.GetString(config.GinUpstreamRequestIdKey))
+	// 未开启时不返回响应头
+	assert.Empty(t, c.Writer.Header().Get(providersBase.UpstreamRequestIdHeader))
+
+	// 普通令牌的错误信息中不包含上游请求 ID
+	filtered := FilterOpenAIErr(c, errWithCode)
+	assert.Empty(t, filtered.OpenAIError.UpstreamRequestId)
+	body, _ := json.Marshal(filtered.OpenAIError)
+	assert.NotContains(t, string(body), "upstream_request_id")
+
+	// 特权令牌
+	c.Set("token_setting", &model.TokenSetting{Privileged: true})
+	filtered = FilterOpenAIErr(c, errWithCode)
+	assert.Equal(t, "req_upstream_1", filtered.OpenAIError.UpstreamRequestId)
+	body, _ = json.Marshal(filtered.OpenAIError)
+	assert.Contains(t, string(body), `"upstream_request_id":"req_upstream_1"`)
+}
@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/test"
+	"one-api/common/watermark"
+	"one-api/model"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupWatermarkTest(t *testing.T) *model.Channel {
+	requester.InitHttpClient()
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, event := range []string{
+				`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"usage":{"input_tokens":10}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+				`{"type":"message_stop"}`,
+			} {
+				w.Write([]byte("data: " + event + "\n\n"))
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","content":[{"type":"text","text":"{\"answer\":1}"}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	})
+	ts := server.TestServer(nil)
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&model.Channel{}, &model.UserGroup{}))
+	model.DB = db
+
+	assert.Nil(t, db.Create(&model.UserGroup{Symbol: "marked", Name: "marked", Ratio: 1, Watermark: true}).Error)
+	assert.Nil(t, db.Create(&model.UserGroup{Symbol: "plain", Name: "plain", Ratio: 1}).Error)
+	model.GlobalUserGroupRatio.Load()
+	t.Cleanup(func() { model.GlobalUserGroupRatio = model.UserGroupRatio{} })
+
+	config.ResponseWatermarkEnabled = true
+	t.Cleanup(func() { config.ResponseWatermarkEnabled = false })
+
+	channel := &model.Channel{Type: config.ChannelTypeAnthropic, Key: "sk-test", Status: config.ChannelStatusEnabled, BaseURL: &ts.URL}
+	assert.Nil(t, db.Create(channel).Error)
+	return channel
+}
+
+func newWatermarkContext(channel *model.Channel, path, group, body string) (*gin.Context, func() string) {
+	c, w := test.GetContext("POST", path, test.RequestJSONConfig(), strings.NewReader(body))
+	c.Set("token_id", 42)
+	c.Set("token_group", group)
+	c.Set("specific_channel_id", channel.Id)
+	return c, w.Body.String
+}
+
+func sendWatermarkChat(t *testing.T, channel *model.Channel, group, body string) string {
+	c, response := newWatermarkContext(channel, "/v1/chat/completions", group, body)
+	relay := NewRelayChat(c)
+	assert.Nil(t, relay.setRequest())
+	assert.Nil(t, relay.setProvider(relay.getOriginalModel()))
+	relay.getProvider().SetUsage(&types.Usage{})
+	errWithCode, _ := relay.send()
+	assert.Nil(t, errWithCode)
+	return response()
+}
+
+func TestResponseWatermarkChat(t *testing.T) {
+	channel := setupWatermarkTest(t)
+
+	// 流式响应只在第一段文本后追加一次
+	response := sendWatermarkChat(t, channel, "marked", `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	tokenId, ok := watermark.Decode(response)
+	assert.True(t, ok)
+	assert.Equal(t, 42, tokenId)
+	assert.Contains(t, response, `"content":"Hello`+watermark.Encode(42)+`"`)
+	assert.Contains(t, response, `"content":" world"`)
+
+	// 非流式响应
+	response = sendWatermarkChat(t, channel, "marked", `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	tokenId, ok = watermark.Decode(response)
+	assert.True(t, ok)
+	assert.Equal(t, 42, tokenId)
+
+	// 未开启水印的分组
+	response = sendWatermarkChat(t, channel, "plain", `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	_, ok = watermark.Decode(response)
+	assert.False(t, ok)
+
+	// 总开关关闭
+	config.ResponseWatermarkEnabled = false
+	response = sendWatermarkChat(t, channel, "marked", `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	_, ok = watermark.Decode(response)
+	assert.False(t, ok)
+}
+
+func TestResponseWatermarkSkipJsonOutput(t *testing.T) {
+	channel := setupWatermarkTest(t)
+
+	for _, format := range []string{`{"type":"json_object"}`, `{"type":"json_schema","json_schema":{"name":"answer","schema":{"type":"object"}}}`} {
+		response := sendWatermarkChat(t, channel, "marked", `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"response_format":`+format+`,"messages":[{"role":"user","content":"hi"}]}`)
+		_, ok := watermark.Decode(response)
+		assert.False(t, ok, format)
+		assert.Contains(t, response, `"content":"{\"answer\":1}"`, format)
+	}
+}
+
+func TestResponseWatermarkClaude(t *testing.T) {
+	channel := setupWatermarkTest(t)
+
+	for _, stream := range []bool{false, true} {
+		body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`
+		if stream {
+			body = `{"model":"claude-3-5-sonnet-20241022","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+		}
+		c, response := newWatermarkContext(channel, "/v1/messages", "marked", body)
+		relay := NewRelayClaudeOnly(c)
+		assert.Nil(t, relay.setRequest())
+		assert.Nil(t, relay.setProvider(relay.getOriginalModel()))
+		relay.getProvider().SetUsage(&types.Usage{})
+		errWithCode, _ := relay.send()
+		assert.Nil(t, errWithCode)
+
+		tokenId, ok := watermark.Decode(response())
+		assert.True(t, ok, stream)
+		assert.Equal(t, 42, tokenId, stream)
+	}
+}
@@ -80,11 +80,13 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/invoice", controller.GetUserInvoice)
 				selfRoute.GET("/invoice/detail", controller.GetUserInvoiceDetail)
 				selfRoute.GET("/self", controller.GetSelf)
+				selfRoute.GET("/login_history", controller.GetSelfLoginHistory)
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.POST("/unbind", controller.Unbind)
 				// selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
+				selfRoute.GET("/pricing", controller.GetUserEffectivePrices)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.GET("/payment", controller.GetUserPaymentList)
 				selfRoute.POST("/order", controller.CreateOrder)
@@ -96,6 +98,7 @@ func SetApiRouter(router *gin.Engine) {
 			{
 				adminRoute.GET("/", controller.GetUsersList)
 				adminRoute.GET("/:id", controller.GetUser)
+				adminRoute.GET("/:id/login_history", controller.GetUserLoginHistory)
 				adminRoute.POST("/", controller.CreateUser)
 				adminRoute.POST("/manage", controller.ManageUser)
 				adminRoute.POST("/quota/:id", controller.ChangeUserQuota)
@@ -115,6 +118,7 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.GET("/telegram/:id", controller.GetTelegramMenu)
 			optionRoute.DELETE("/telegram/:id", controller.DeleteTelegramMenu)
 			optionRoute.GET("/safe_tools", controller.GetSafeTools)
+			optionRoute.GET("/linuxdo/diagnose", controller.DiagnoseLinuxDoOAuth)
 			optionRoute.POST("/invoice/gen/:time", controller.GenInvoice)
 			optionRoute.POST("/invoice/update/:time", controller.UpdateInvoice)
 			optionRoute.POST("/system_info/log", controller.SystemLog)
@@ -157,9 +161,11 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/", controller.GetChannelsList)
 			channelRoute.GET("/models", relay.ListModelsForAdmin)
 			channelRoute.POST("/provider_models_list", controller.GetModelList)
+			channelRoute.POST("/validate_key", controller.ValidateChannelKey)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
+			channelRoute.GET("/test_prompt/:id", controller.TestChannelPrompt)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
 			channelRoute.POST("/", controller.AddChannel)
@@ -200,6 +206,7 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			tokenAdminRoute.GET("/admin/search", controller.GetTokensListByAdmin)
 			tokenAdminRoute.PUT("/admin", controller.UpdateTokenByAdmin)
+			tokenAdminRoute.POST("/admin/watermark/decode", controller.DecodeWatermark)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
@@ -229,6 +236,8 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			analyticsRoute.GET("/statistics", controller.GetStatisticsDetail)
 			analyticsRoute.GET("/period", controller.GetStatisticsByPeriod)
+			analyticsRoute.GET("/channel_labels", controller.GetChannelLabelStatistics)
+			analyticsRoute.GET("/streaming", controller.GetStreamingStatistics)
 			analyticsRoute.GET("/multi_user_stats", controller.GetMultiUserStatistics)
 			analyticsRoute.GET("/multi_user_stats/export", controller.ExportMultiUserStatisticsCSV)
 		}
@@ -242,6 +251,8 @@ func SetApiRouter(router *gin.Engine) {
 			pricesRoute.POST("/multiple", controller.BatchSetPrices)
 			pricesRoute.PUT("/multiple/delete", controller.BatchDeletePrices)
 			pricesRoute.POST("/sync", controller.SyncPricing)
+			pricesRoute.GET("/export", controller.ExportPricing)
+			pricesRoute.POST("/import", controller.ImportPricing)
 			pricesRoute.GET("/updateService", controller.GetUpdatePriceService)
 
 		}
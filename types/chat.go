@@ -3,8 +3,9 @@ package types
 import "encoding/json"
 
 const (
-	ContentTypeText     = "text"
-	ContentTypeImageURL = "image_url"
+	ContentTypeText       = "text"
+	ContentTypeImageURL   = "image_url"
+	ContentTypeInputAudio = "input_audio"
 )
 
 const (
@@ -220,6 +221,8 @@ type ChatCompletionRequest struct {
   Thinking *interface{} `json:"thinking,omitempty"` // thinking 思考开关，兼容火山引擎
   
 	OneOtherArg string `json:"-"`
+	// 客户端要求本次请求不使用提示词缓存
+	DisablePromptCache bool `json:"-"`
 }
 
 type ChatReasoning struct {
@@ -275,6 +278,68 @@ func (r *ChatCompletionRequest) GetFunctions() []*ChatCompletionFunction {
 	return r.Functions
 }
 
+// NormalizeLegacyFields 返回将旧版字段升级为当前字段后的请求副本，原请求保持不变（响应仍需按旧版格式返回）
+// max_completion_tokens -> max_tokens，functions -> tools，function_call -> tool_choice，
+// 消息中的 function_call 和 function 角色 -> tool_calls 和 tool 角色
+func (r *ChatCompletionRequest) NormalizeLegacyFields() *ChatCompletionRequest {
+	normalized := *r
+
+	if normalized.MaxTokens == 0 && normalized.MaxCompletionTokens > 0 {
+		normalized.MaxTokens = normalized.MaxCompletionTokens
+	}
+	normalized.MaxCompletionTokens = 0
+
+	if len(normalized.Tools) == 0 && len(normalized.Functions) > 0 {
+		normalized.Tools = make([]*ChatCompletionTool, 0, len(normalized.Functions))
+		for _, function := range normalized.Functions {
+			normalized.Tools = append(normalized.Tools, &ChatCompletionTool{
+				Type:     ToolChoiceTypeFunction,
+				Function: *function,
+			})
+		}
+	}
+	normalized.Functions = nil
+
+	if normalized.ToolChoice == nil && normalized.FunctionCall != nil {
+		normalized.ToolChoice = convertFunctionCallToToolChoice(normalized.FunctionCall)
+	}
+	normalized.FunctionCall = nil
+
+	normalized.Messages = make([]ChatCompletionMessage, len(r.Messages))
+	copy(normalized.Messages, r.Messages)
+	for i := range normalized.Messages {
+		msg := &normalized.Messages[i]
+		if msg.FunctionCall != nil {
+			msg.FuncToToolCalls()
+		}
+		if msg.Role == ChatMessageRoleFunction {
+			msg.Role = ChatMessageRoleTool
+			if msg.ToolCallID == "" && msg.Name != nil {
+				msg.ToolCallID = *msg.Name
+			}
+		}
+	}
+
+	return &normalized
+}
+
+// convertFunctionCallToToolChoice 将 "none"、"auto" 或 {"name": "xxx"} 形式的 function_call 转换为 tool_choice
+func convertFunctionCallToToolChoice(functionCall any) any {
+	switch value := functionCall.(type) {
+	case string:
+		return value
+	case map[string]any:
+		if name, ok := value["name"].(string); ok && name != "" {
+			return map[string]any{
+				"type":     ToolChoiceTypeFunction,
+				"function": map[string]any{"name": name},
+			}
+		}
+	}
+
+	return nil
+}
+
 type ChatCompletionFunction struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -324,6 +389,37 @@ func (cc *ChatCompletionResponse) GetContent() string {
 	return content
 }
 
+// ToStreamResponse 将完整的响应转换为单个流式块
+func (cc *ChatCompletionResponse) ToStreamResponse() *ChatCompletionStreamResponse {
+	choices := make([]ChatCompletionStreamChoice, 0, len(cc.Choices))
+	for _, choice := range cc.Choices {
+		for index, toolCall := range choice.Message.ToolCalls {
+			toolCall.Index = index
+		}
+
+		choices = append(choices, ChatCompletionStreamChoice{
+			Index: choice.Index,
+			Delta: ChatCompletionStreamChoiceDelta{
+				Role:             choice.Message.Role,
+				Content:          choice.Message.StringContent(),
+				ReasoningContent: choice.Message.ReasoningContent,
+				FunctionCall:     choice.Message.FunctionCall,
+				ToolCalls:        choice.Message.ToolCalls,
+			},
+			FinishReason: choice.FinishReason,
+		})
+	}
+
+	return &ChatCompletionStreamResponse{
+		ID:                cc.ID,
+		Object:            "chat.completion.chunk",
+		Created:           cc.Created,
+		Model:             cc.Model,
+		Choices:           choices,
+		SystemFingerprint: cc.SystemFingerprint,
+	}
+}
+
 func (c ChatCompletionStreamChoice) ConvertOpenaiStream() []ChatCompletionStreamChoice {
 	var choices []ChatCompletionStreamChoice
 	var stopFinish string
@@ -441,6 +537,7 @@ type ChatCompletionStreamResponse struct {
 	Choices           []ChatCompletionStreamChoice `json:"choices"`
 	PromptAnnotations any                          `json:"prompt_annotations,omitempty"`
 	Usage             *Usage                       `json:"usage,omitempty"`
+	SystemFingerprint string                       `json:"system_fingerprint,omitempty"`
 }
 
 func (c *ChatCompletionStreamResponse) GetResponseText() (responseText string) {
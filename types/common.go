@@ -17,6 +17,9 @@ type Usage struct {
 	ExtraTokens  map[string]int          `json:"-"`
 	ExtraBilling map[string]ExtraBilling `json:"-"`
 	TextBuilder  strings.Builder         `json:"-"`
+	Estimated    bool                    `json:"-"` // 上游未返回用量，补全 token 为本地估算
+
+	PromptEstimated bool `json:"-"` // 上游未返回输入用量，提示 token 为本地估算
 }
 
 type ExtraBilling struct {
@@ -137,6 +140,8 @@ type OpenAIError struct {
 	Param      string `json:"param,omitempty"`
 	Type       string `json:"type,omitempty"`
 	InnerError any    `json:"innererror,omitempty"`
+
+	UpstreamRequestId string `json:"upstream_request_id,omitempty"` // 上游请求 ID，仅特权令牌可见
 }
 
 func (e *OpenAIError) Error() string {
@@ -155,6 +160,9 @@ type OpenAIErrorWithStatusCode struct {
 	OpenAIError
 	StatusCode int  `json:"status_code"`
 	LocalError bool `json:"-"`
+	Billed     bool `json:"-"` // 上游已产生消耗，出错时仍按实际用量计费
+
+	RawUpstreamRequestId string `json:"-"` // 上游返回的请求 ID，仅用于日志；对客户端可见的是 OpenAIError.UpstreamRequestId
 }
 
 type OpenAIErrorResponse struct {
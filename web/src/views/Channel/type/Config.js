@@ -114,8 +114,12 @@ const typeConfig = {
   },
   14: {
     inputLabel: {
+      other: '默认 API 版本',
       provider_models_list: '从Claude获取模型列表'
     },
+    prompt: {
+      other: '可空，客户端未指定 anthropic-version 时使用，例如：2023-06-01'
+    },
     input: {
       models: [
         'claude-instant-1.2',